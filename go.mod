@@ -9,6 +9,7 @@ require (
 	github.com/tealeg/xlsx v1.0.5
 	github.com/xuri/excelize/v2 v2.9.1
 	go.mongodb.org/mongo-driver v1.17.4
+	google.golang.org/protobuf v1.36.9
 )
 
 require (
@@ -57,5 +58,4 @@ require (
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	golang.org/x/tools v0.34.0 // indirect
-	google.golang.org/protobuf v1.36.9 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	_ "time/tzdata" // База часовых поясов внутри бинарника: в образе alpine ее нет
 
 	"log"
 	"project/packages/auth"
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"project/packages/parsing/aerodromes"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// loadAerodromes загружает справочник аэродромов в память и проставляет коды аэродромов
+// ранее загруженным полетам. all=true перепроверяет все полеты с кодами (после импорта справочника).
+// Возвращает число аэродромов, обновленных полетов и полетов с измененными координатами
+func loadAerodromes(collection useTables, all bool) (int, int, int, error) {
+	empty, err := isCollectionEmpty(collection.aerodromesCollection)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if empty {
+		if _, err := aerodromes.LoadFileToMongo(collection.aerodromesCollection); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	directory, err := aerodromes.LoadFromCollection(collection.aerodromesCollection)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if directory.Len() > 0 {
+		aerodromes.SetShared(directory)
+	} else {
+		aerodromes.SetShared(nil)
+	}
+
+	if err := aerodromes.EnsureFlightIndexes(collection.flightDataCollection); err != nil {
+		fmt.Printf("⚠️ Ошибка создания индексов аэродромов: %v\n", err)
+	}
+
+	// Коды проставляются и при пустом справочнике: фильтр по аэродрому работает без координат
+	updated, moved, err := aerodromes.ApplyToFlights(collection.flightDataCollection, directory, all)
+	return directory.Len(), updated, moved, err
+}
+
+// applyAerodromeFilter фильтр по кодам аэродромов через запятую;
+// role=dep - только вылет, arr - только посадка, иначе вылет или посадка
+func applyAerodromeFilter(filter bson.M, codes, role string) {
+	// В сообщениях встречаются и ICAO, и внутренние коды: ищем по всем кодам аэродрома
+	var list []string
+	for _, code := range splitList(codes) {
+		if code = aerodromes.NormalizeCode(code); code == "" {
+			continue
+		}
+		if aerodrome, found := aerodromes.Lookup(code); found {
+			list = append(list, aerodrome.Codes...)
+		} else {
+			list = append(list, code)
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	condition := bson.M{"$in": list}
+	switch role {
+	case "dep":
+		filter["dep.airport"] = condition
+	case "arr":
+		filter["arr.airport"] = condition
+	default:
+		appendAnd(filter, bson.M{"$or": []bson.M{
+			{"dep.airport": condition},
+			{"arr.airport": condition},
+		}})
+	}
+	fmt.Printf("🛫 Фильтр по аэродромам: %v\n", list)
+}
+
+// formatAerodrome код аэродрома с названием из справочника для выгрузки
+func formatAerodrome(value interface{}) string {
+	code, ok := value.(string)
+	if !ok || code == "" {
+		return ""
+	}
+	if aerodrome, found := aerodromes.Lookup(code); found && aerodrome.Name != "" {
+		return code + " (" + aerodrome.Name + ")"
+	}
+	return code
+}
+
+// getAerodromes справочник аэродромов; region - фильтр по региону, q - поиск по коду или названию
+func getAerodromes(c *gin.Context) {
+	list := []aerodromes.Aerodrome{}
+	directory := aerodromes.Shared()
+	if directory == nil {
+		c.JSON(http.StatusOK, list)
+		return
+	}
+
+	region := regionDirectory.resolve(c.Query("region"))
+	query := strings.ToUpper(strings.TrimSpace(c.Query("q")))
+	for _, aerodrome := range directory.List() {
+		if region != "" && aerodrome.Region != region {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToUpper(aerodrome.Name), query) && !hasCodePrefix(aerodrome.Codes, query) {
+			continue
+		}
+		list = append(list, aerodrome)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// hasCodePrefix начинается ли один из кодов с prefix
+func hasCodePrefix(codes []string, prefix string) bool {
+	for _, code := range codes {
+		if strings.HasPrefix(code, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// importAerodromes загружает справочник аэродромов из CSV (поле file) и пересчитывает
+// координаты полетов, у которых они были взяты из справочника или отсутствовали
+func importAerodromes(c *gin.Context, collection useTables) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ожидается CSV файл в поле file"})
+		return
+	}
+	defer file.Close()
+
+	imported, invalid, err := aerodromes.Import(collection.aerodromesCollection, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "invalid": invalid})
+		return
+	}
+
+	_, updated, moved, err := loadAerodromes(collection, true)
+	if err != nil {
+		fmt.Printf("❌ Ошибка применения справочника аэродромов: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tileCache.Reset()
+
+	fmt.Printf("🛫 Справочник аэродромов: %d, обновлено полетов %d, координаты изменены у %d\n", imported, updated, moved)
+	response := gin.H{
+		"imported":       imported,
+		"invalid":        invalid,
+		"flightsUpdated": updated,
+		"flightsMoved":   moved,
+	}
+	if moved > 0 {
+		response["message"] = "Координаты части полетов изменились, для пересчета регионов вызовите POST /subject"
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"project/packages/parsing/geoGet"
+	"project/packages/parsing/geoIndex"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Уровни группировки аналитики (параметр level)
+const (
+	levelRegion     = "region"     // Субъект
+	levelDistrict   = "district"   // Муниципальный район
+	levelFederal    = "federal"    // Федеральный округ
+	levelSettlement = "settlement" // Ближайший населенный пункт
+)
+
+// federalUndefined федеральный округ полетов вне известных регионов
+const federalUndefined = "Округ не определен"
+
+// aggregationLevel группировка для уровня: ключ $group, поля $project с группой
+// и поле сортировки по названию
+type aggregationLevel struct {
+	name      string
+	groupKey  interface{}
+	fields    bson.D
+	sortField string
+}
+
+// parseAggregationLevel читает параметр level (по умолчанию region)
+func parseAggregationLevel(c *gin.Context) (aggregationLevel, error) {
+	switch level := c.DefaultQuery("level", levelRegion); level {
+	case levelRegion:
+		return aggregationLevel{
+			name:      level,
+			groupKey:  "$region",
+			fields:    bson.D{{Key: "_id", Value: 0}, {Key: "region", Value: "$_id"}},
+			sortField: "region",
+		}, nil
+	case levelDistrict:
+		return aggregationLevel{
+			name:     level,
+			groupKey: bson.M{"region": "$region", "district": "$district"},
+			fields: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "region", Value: "$_id.region"},
+				{Key: "district", Value: "$_id.district"},
+			},
+			sortField: "region",
+		}, nil
+	case levelFederal:
+		return aggregationLevel{
+			name:      level,
+			groupKey:  federalDistrictExpression(),
+			fields:    bson.D{{Key: "_id", Value: 0}, {Key: "federalDistrict", Value: "$_id"}},
+			sortField: "federalDistrict",
+		}, nil
+	case levelSettlement:
+		// Одноименные населенные пункты различаются регионом
+		return aggregationLevel{
+			name: level,
+			groupKey: bson.M{
+				"region":     "$region",
+				"settlement": bson.M{"$ifNull": bson.A{"$nearestSettlement.name", settlementUndefined}},
+				"type":       "$nearestSettlement.type",
+			},
+			fields: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "region", Value: "$_id.region"},
+				{Key: "settlement", Value: "$_id.settlement"},
+				{Key: "settlementType", Value: "$_id.type"},
+			},
+			sortField: "region",
+		}, nil
+	default:
+		return aggregationLevel{}, fmt.Errorf("Неверный параметр level. Используйте region, district, federal или settlement")
+	}
+}
+
+// normalized доступны ли для уровня показатели на площадь и население
+func (l aggregationLevel) normalized() bool {
+	return l.name == levelRegion || l.name == levelFederal
+}
+
+// project поля $project уровня с добавленными полями метрик
+func (l aggregationLevel) project(metrics ...bson.E) bson.D {
+	fields := append(bson.D{}, l.fields...)
+	return append(fields, metrics...)
+}
+
+// federalDistrictExpression выражение федерального округа полета: $switch по полю region
+// с округом каждого региона из справочника
+func federalDistrictExpression() interface{} {
+	districtOf := regionDirectory.federalDistricts()
+	if len(districtOf) == 0 {
+		return federalUndefined
+	}
+
+	regions := make([]string, 0, len(districtOf))
+	for region := range districtOf {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	branches := make(bson.A, 0, len(regions))
+	for _, region := range regions {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$eq": bson.A{"$region", region}},
+			"then": districtOf[region],
+		})
+	}
+	return bson.M{"$switch": bson.M{"branches": branches, "default": federalUndefined}}
+}
+
+// federalDistrictRegions регионы федерального округа; district - название или обозначение (ЦФО)
+func federalDistrictRegions(district string) (string, []string, error) {
+	name := geoIndex.NormalizeFederalDistrict(district)
+	if name == "" {
+		return "", nil, fmt.Errorf("неизвестный федеральный округ: %s", district)
+	}
+
+	var regions []string
+	for region, regionDistrict := range regionDirectory.federalDistricts() {
+		if regionDistrict == name {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return "", nil, fmt.Errorf("регионы федерального округа %s не загружены", name)
+	}
+	sort.Strings(regions)
+	return name, regions, nil
+}
+
+// getFederalDistrictsGeo объединенные геометрии федеральных округов для картограмм.
+// Необязательные параметры tolerance или zoom - как у /regions/geojson
+func getFederalDistrictsGeo(c *gin.Context, tables useTables) {
+	tolerance := geoGet.ToleranceForZoom(4)
+	if value := c.Query("tolerance"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр tolerance"})
+			return
+		}
+		tolerance = parsed
+	} else if value := c.Query("zoom"); value != "" {
+		zoom, err := strconv.Atoi(value)
+		if err != nil || zoom < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр zoom"})
+			return
+		}
+		tolerance = geoGet.ToleranceForZoom(zoom)
+	}
+
+	districts, err := geoGet.GetFederalDistrictsGeo(tables.subjectListCollection, tolerance,
+		regionDirectory.federalDistricts(), geoIndex.FederalDistrictCodes)
+	if err != nil {
+		fmt.Printf("❌ Ошибка получения геометрий федеральных округов: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить геометрии федеральных округов"})
+		return
+	}
+	c.JSON(http.StatusOK, districts)
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/packages/parsing/airspace"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// airspaceTopOperators сколько операторов возвращать для каждой зоны в сводке нарушений
+const airspaceTopOperators = 5
+
+// loadAirspace загружает зоны ограничения в индекс и проверяет по ним полеты.
+// reload=true перечитывает GeoJSON файлы и перепроверяет все полеты, иначе файлы
+// читаются только при пустой коллекции и проверяются только еще не проверенные полеты.
+// Возвращает отчет по файлам (если они перечитывались), число зон и проверенных полетов
+func loadAirspace(collection useTables, reload bool) (airspace.Report, int, int, error) {
+	var report airspace.Report
+	if !reload {
+		empty, err := isCollectionEmpty(collection.airspaceCollection)
+		if err != nil {
+			return report, 0, 0, err
+		}
+		reload = empty
+	}
+	if reload {
+		var err error
+		if report, err = airspace.LoadToMongo(collection.airspaceCollection); err != nil {
+			return report, 0, 0, err
+		}
+	}
+
+	index, err := airspace.LoadFromCollection(collection.airspaceCollection)
+	if err != nil {
+		return report, 0, 0, err
+	}
+	if index.Len() == 0 {
+		// Без зон полеты не помечаются проверенными и будут проверены после загрузки зон
+		airspace.SetShared(nil)
+		return report, 0, 0, nil
+	}
+	airspace.SetShared(index)
+
+	if err := airspace.EnsureFlightIndexes(collection.flightDataCollection); err != nil {
+		fmt.Printf("⚠️ Ошибка создания индекса нарушений: %v\n", err)
+	}
+
+	checked, err := airspace.ApplyToFlights(collection.flightDataCollection, index, reload)
+	return report, index.Len(), checked, err
+}
+
+// applyAirspaceFilter фильтр по пересечениям с зонами ограничения: airspace=any (любая зона)
+// или список обозначений через запятую, airspaceType=prohibited,restricted,...
+func applyAirspaceFilter(filter bson.M, designators, types string) {
+	hit := bson.M{}
+	designators = strings.TrimSpace(designators)
+	if designators != "" && !strings.EqualFold(designators, "any") {
+		hit["designator"] = bson.M{"$in": splitList(strings.ToUpper(designators))}
+	}
+	if types = strings.TrimSpace(types); types != "" {
+		hit["type"] = bson.M{"$in": splitList(strings.ToLower(types))}
+	}
+
+	switch {
+	case len(hit) > 0:
+		filter["airspace"] = bson.M{"$elemMatch": hit}
+	case designators != "":
+		filter["airspace.0"] = bson.M{"$exists": true}
+	}
+}
+
+// splitList разбивает список через запятую, отбрасывая пустые элементы
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatAirspace обозначения зон ограничения для выгрузки
+func formatAirspace(value interface{}) string {
+	designators, ok := value.(primitive.A)
+	if !ok {
+		return ""
+	}
+	parts := make([]string, 0, len(designators))
+	for _, designator := range designators {
+		if text, ok := designator.(string); ok {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// getAirspaceAreas список зон ограничения; type - фильтр по типу, geometry=true - с геометрией
+func getAirspaceAreas(c *gin.Context, collection useTables) {
+	filter := bson.M{}
+	if areaType := c.Query("type"); areaType != "" {
+		filter["type"] = bson.M{"$in": splitList(strings.ToLower(areaType))}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "designator", Value: 1}})
+	if c.Query("geometry") != "true" {
+		opts.SetProjection(bson.M{"geometry": 0})
+	}
+
+	ctx := context.Background()
+
+	cursor, err := collection.airspaceCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	areas := []bson.M{}
+	if err := cursor.All(ctx, &areas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+	c.JSON(http.StatusOK, areas)
+}
+
+// reloadAirspace перечитывает зоны ограничения из GeoJSON и перепроверяет все полеты
+func reloadAirspace(c *gin.Context, collection useTables) {
+	start := time.Now()
+	report, areas, checked, err := loadAirspace(collection, true)
+	if report.FailedFiles() > 0 {
+		// Прежние зоны остаются рабочими
+		fmt.Printf("❌ Зоны ограничения не перезагружены: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Ошибка загрузки зон ограничения: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	tileCache.Reset()
+
+	fmt.Printf("🚫 Зоны ограничения перезагружены: %d зон, обновлено полетов %d за %v\n", areas, checked, time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Зоны ограничения перезагружены",
+		"areas":          areas,
+		"flightsUpdated": checked,
+		"report":         report,
+	})
+}
+
+// violationsMatch фильтр полетов с пересечениями за период from..to.
+// Необязательные фильтры: designator, type, source (dep|zone), region
+func violationsMatch(c *gin.Context, designator string) (bson.M, error) {
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("Параметры from и to обязательны")
+	}
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return nil, fmt.Errorf("Неверный формат from")
+	}
+	end, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		return nil, fmt.Errorf("Неверный формат to")
+	}
+
+	hit := bson.M{}
+	if designator != "" {
+		hit["designator"] = strings.ToUpper(designator)
+	}
+	if areaType := c.Query("type"); areaType != "" {
+		hit["type"] = bson.M{"$in": splitList(strings.ToLower(areaType))}
+	}
+	if source := c.Query("source"); source != "" {
+		if source != airspace.SourceDeparture && source != airspace.SourceZone {
+			return nil, fmt.Errorf("Неверный параметр source. Используйте dep или zone")
+		}
+		hit["source"] = source
+	}
+
+	match := bson.M{
+		"searchFields.dateTime": bson.M{"$gte": start, "$lte": end},
+		"airspace.0":            bson.M{"$exists": true},
+	}
+	if len(hit) > 0 {
+		match["airspace"] = bson.M{"$elemMatch": hit}
+	}
+	if region := c.Query("region"); region != "" {
+		match["region"] = regionDirectory.resolve(region)
+	}
+	return match, nil
+}
+
+// getAirspaceViolations потенциальные нарушения по зонам за период: число полетов
+// с вылетом в зоне и с районом полетов, задевающим зону, первый и последний полет, основные операторы.
+// Высоты не сравниваются - это отбор для дальнейшей проверки
+func getAirspaceViolations(c *gin.Context, collection useTables) {
+	match, err := violationsMatch(c, c.Query("designator"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Фильтр пересечений после $unwind повторяет условия $elemMatch
+	hitMatch := bson.M{}
+	if elemMatch, ok := match["airspace"].(bson.M); ok {
+		for key, value := range elemMatch["$elemMatch"].(bson.M) {
+			hitMatch["airspace."+key] = value
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$airspace"}},
+		{{Key: "$match", Value: hitMatch}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$airspace.designator"},
+			{Key: "name", Value: bson.M{"$first": "$airspace.name"}},
+			{Key: "type", Value: bson.M{"$first": "$airspace.type"}},
+			{Key: "flightCount", Value: bson.M{"$sum": 1}},
+			{Key: "departureCount", Value: bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$airspace.source", airspace.SourceDeparture}}, 1, 0,
+			}}}},
+			{Key: "zoneCount", Value: bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$airspace.source", airspace.SourceZone}}, 1, 0,
+			}}}},
+			{Key: "firstFlight", Value: bson.M{"$min": "$searchFields.dateTime"}},
+			{Key: "lastFlight", Value: bson.M{"$max": "$searchFields.dateTime"}},
+			{Key: "operators", Value: bson.M{"$push": "$shr.operator"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "flightCount", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	ctx := context.Background()
+
+	cursor, err := collection.flightDataCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Printf("❌ Ошибка агрегации: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var groups []bson.M
+	if err := cursor.All(ctx, &groups); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	results := []bson.M{}
+	totalFlights := 0
+	for _, group := range groups {
+		group["designator"] = group["_id"]
+		delete(group, "_id")
+		group["operators"] = topOperators(group["operators"], airspaceTopOperators)
+		totalFlights += int(toFloat(group["flightCount"]))
+		results = append(results, group)
+	}
+
+	fmt.Printf("🚫 Потенциальные нарушения: %d зон, %d пересечений\n", len(results), totalFlights)
+	c.JSON(http.StatusOK, gin.H{
+		"areas":         results,
+		"intersections": totalFlights,
+	})
+}
+
+// topOperators самые частые операторы из списка
+func topOperators(value interface{}, limit int) []bson.M {
+	operators, _ := value.(primitive.A)
+	counts := make(map[string]int)
+	var order []string
+	for _, operator := range operators {
+		name, ok := operator.(string)
+		if !ok || name == "" {
+			continue
+		}
+		if counts[name] == 0 {
+			order = append(order, name)
+		}
+		counts[name]++
+	}
+
+	result := []bson.M{}
+	for len(result) < limit && len(order) > 0 {
+		best := 0
+		for i, name := range order {
+			if counts[name] > counts[order[best]] {
+				best = i
+			}
+		}
+		result = append(result, bson.M{"operator": order[best], "count": counts[order[best]]})
+		order = append(order[:best], order[best+1:]...)
+	}
+	return result
+}
+
+// getAirspaceViolationFlights полеты, пересекающие зону designator за период, с пагинацией
+func getAirspaceViolationFlights(c *gin.Context, collection useTables) {
+	match, err := violationsMatch(c, c.Param("designator"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	ctx := context.Background()
+
+	total, err := collection.flightDataCollection.CountDocuments(ctx, match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "searchFields.dateTime", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{
+			"region":         1,
+			"airspace":       1,
+			"sid":            "$shr.sid",
+			"aircraftType":   "$shr.aircraftType",
+			"operator":       "$shr.operator",
+			"operatorType":   "$shr.operatorType",
+			"flightDuration": "$shr.flightDuration",
+			"zone":           "$shr.zone",
+			"dateDep":        "$searchFields.dateTime",
+			"coordinatesDep": bson.M{"$ifNull": bson.A{"$dep.coordinates", "$shr.coordinatesDep"}},
+		})
+
+	cursor, err := collection.flightDataCollection.Find(ctx, match, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	flights := []bson.M{}
+	if err := cursor.All(ctx, &flights); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"designator": strings.ToUpper(c.Param("designator")),
+		"flights":    flights,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": totalPages,
+		},
+	})
+}
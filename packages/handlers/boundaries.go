@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"project/packages/parsing/geoGet"
+	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoSearch"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxBoundaryUploadSize предельный размер загружаемого набора границ
+const maxBoundaryUploadSize = 1 << 30
+
+// refreshBoundaries перечитывает список и индекс регионов и сбрасывает кэши геометрий
+// после смены рабочего набора границ
+func refreshBoundaries(collection useTables) {
+	updateRegionList(collection)
+	loadRegionIndex(collection)
+	geoGet.ResetSimplifiedCache()
+	geoSearch.ResetCaches()
+	tileCache.Reset()
+	go precomputeSimplified(collection)
+}
+
+// precomputeSimplified заранее готовит упрощенные геометрии регионов для всех уровней масштаба
+func precomputeSimplified(collection useTables) {
+	if err := geoGet.PrecomputeSimplified(collection.subjectListCollection); err != nil {
+		fmt.Printf("⚠️ Ошибка подготовки упрощенных геометрий регионов: %v\n", err)
+	}
+}
+
+// getBoundaryVersions список версий наборов границ
+func getBoundaryVersions(c *gin.Context, collection useTables) {
+	versions, err := geoIndex.ListVersions(collection.subjectListCollection.Database())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// getBoundaryVersion версия набора границ с отчетом проверки по файлам
+func getBoundaryVersion(c *gin.Context, collection useTables) {
+	version, err := geoIndex.GetVersion(collection.subjectListCollection.Database(), c.Param("name"))
+	if errors.Is(err, geoIndex.ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// uploadBoundaryVersion загружает набор границ: GeoJSON файлы (поле files, районы -
+// с путем districts/...) и таблицы атрибутов или ZIP архив с той же структурой.
+// Набор проверяется и сохраняется как версия name; activate=true делает ее рабочей,
+// recompute=true после этого пересчитывает регионы полетов
+func uploadBoundaryVersion(c *gin.Context, collection useTables, client *mongo.Client) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = geoIndex.UploadVersionName()
+	}
+	if err := geoIndex.ValidateVersionName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBoundaryUploadSize)
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ожидаются GeoJSON файлы или ZIP архив в поле files"})
+		return
+	}
+
+	var files []geoIndex.SourceFile
+	for _, header := range form.File["files"] {
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Ошибка чтения %s: %v", header.Filename, err)})
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Ошибка чтения %s: %v", header.Filename, err)})
+			return
+		}
+
+		if strings.EqualFold(path.Ext(header.Filename), ".zip") {
+			archived, err := geoIndex.ReadZip(data)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			files = append(files, archived...)
+			continue
+		}
+		files = append(files, geoIndex.SourceFile{Name: header.Filename, Data: data})
+	}
+
+	set := geoIndex.BuildBoundarySet(files)
+	if failed := set.Report.FailedFiles(); failed > 0 || len(set.Regions) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Набор границ не прошел проверку",
+			"report": set.Report,
+		})
+		return
+	}
+
+	db := collection.subjectListCollection.Database()
+	version, err := geoIndex.SaveVersion(db, name, geoIndex.VersionSourceUpload, set)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": set.Report})
+		return
+	}
+
+	response := gin.H{"version": version}
+	if c.PostForm("activate") == "true" {
+		result, err := activateBoundaries(collection, client, name, c.PostForm("recompute") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "version": version})
+			return
+		}
+		for key, value := range result {
+			response[key] = value
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// activateBoundaryVersion делает версию рабочей; recompute=true пересчитывает регионы полетов
+func activateBoundaryVersion(c *gin.Context, collection useTables, client *mongo.Client) {
+	result, err := activateBoundaries(collection, client, c.Param("name"), c.Query("recompute") == "true")
+	if errors.Is(err, geoIndex.ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// activateBoundaries переключает рабочий набор границ и при необходимости пересчитывает регионы полетов
+func activateBoundaries(collection useTables, client *mongo.Client, name string, recompute bool) (gin.H, error) {
+	start := time.Now()
+	if err := geoIndex.ActivateVersion(collection.subjectListCollection, name); err != nil {
+		fmt.Printf("❌ Ошибка активации набора границ %s: %v\n", name, err)
+		return nil, err
+	}
+	// Федеральные округа и население приходят из версии: они сохранены вместе с ней
+	// (таблицы набора и загруженное позже население), таблицы папки geojsonFiles не применяются
+	refreshBoundaries(collection)
+
+	result := gin.H{"message": fmt.Sprintf("Активна версия набора границ %s", name), "active": name}
+	if recompute {
+		if err := geoSearch.UpdateFlightRegions(client); err != nil {
+			return nil, fmt.Errorf("версия %s активна, но регионы полетов не пересчитаны: %v", name, err)
+		}
+		tileCache.Reset()
+		result["recomputed"] = true
+	}
+
+	fmt.Printf("🗺️ Набор границ %s активирован за %v\n", name, time.Since(start))
+	return result, nil
+}
+
+// deleteBoundaryVersion удаляет неактивную версию набора границ
+func deleteBoundaryVersion(c *gin.Context, collection useTables) {
+	name := c.Param("name")
+	err := geoIndex.DeleteVersion(collection.subjectListCollection.Database(), name)
+	if errors.Is(err, geoIndex.ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Версия %s удалена", name)})
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"project/packages/auth"
+	"project/packages/parsing/geoMath"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// customZonesCollectionName коллекция пользовательских зон
+const customZonesCollectionName = "customZones"
+
+// zoneTopLimit сколько операторов и типов ВС возвращать в статистике зоны
+const zoneTopLimit = 10
+
+// customZone пользовательская зона интереса (город, заповедник, промплощадка)
+type customZone struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Timezone    string             `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Geometry    bson.M             `bson:"geometry" json:"geometry"`
+	AreaKm2     float64            `bson:"areaKm2" json:"areaKm2"`
+	CreatedBy   string             `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// customZoneRequest тело запроса создания и изменения зоны
+type customZoneRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Timezone    string          `json:"timezone"`
+	Geometry    json.RawMessage `json:"geometry"`
+}
+
+// zoneDirectory зоны в памяти для фильтров zone=<id>; обновляется при каждом изменении коллекции
+var zoneDirectory = &zoneStore{zones: map[string]customZone{}}
+
+type zoneStore struct {
+	sync.RWMutex
+	zones map[string]customZone
+}
+
+// set заменяет список зон
+func (s *zoneStore) set(zones []customZone) {
+	byID := make(map[string]customZone, len(zones))
+	for _, zone := range zones {
+		byID[zone.ID.Hex()] = zone
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.zones = byID
+}
+
+// get зона по идентификатору
+func (s *zoneStore) get(id string) (customZone, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	zone, ok := s.zones[strings.TrimSpace(id)]
+	return zone, ok
+}
+
+// loadZoneDirectory загружает зоны из коллекции
+func loadZoneDirectory(collection useTables) {
+	ctx := context.Background()
+
+	cursor, err := collection.customZonesCollection.Find(ctx, bson.M{})
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка загрузки пользовательских зон: %v\n", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var zones []customZone
+	if err := cursor.All(ctx, &zones); err != nil {
+		fmt.Printf("⚠️ Ошибка декодирования пользовательских зон: %v\n", err)
+		return
+	}
+	zoneDirectory.set(zones)
+}
+
+// ensureZoneIndexes индексы коллекции зон: 2dsphere по геометрии и имя
+func ensureZoneIndexes(collection useTables) {
+	_, err := collection.customZonesCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "geometry", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка создания индексов пользовательских зон: %v\n", err)
+	}
+}
+
+// zoneCondition условие на точку вылета внутри зоны zone=<id>
+func zoneCondition(id string) (bson.M, customZone, error) {
+	zone, ok := zoneDirectory.get(id)
+	if !ok {
+		return nil, customZone{}, fmt.Errorf("зона %s не найдена", id)
+	}
+	return depPointWithin(bson.M{"$geometry": zone.Geometry}), zone, nil
+}
+
+// resolveZoneTimezone пояс для статистики зоны: local - пояс, указанный у зоны
+func resolveZoneTimezone(collection useTables, tz string, zone customZone) (string, *time.Location, error) {
+	if !strings.EqualFold(tz, timezoneLocal) {
+		return resolveTimezone(collection, tz)
+	}
+	if zone.Timezone == "" {
+		return "", nil, fmt.Errorf("у зоны '%s' не задан часовой пояс, укажите tz явно", zone.Name)
+	}
+	location, err := time.LoadLocation(zone.Timezone)
+	return zone.Timezone, location, err
+}
+
+// parseZoneRequest проверяет тело запроса и заполняет атрибуты зоны
+func parseZoneRequest(c *gin.Context, zone *customZone) error {
+	var request customZoneRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		return fmt.Errorf("неверный JSON в теле запроса: %v", err)
+	}
+
+	zone.Name = strings.TrimSpace(request.Name)
+	if zone.Name == "" {
+		return fmt.Errorf("название зоны обязательно")
+	}
+	zone.Description = strings.TrimSpace(request.Description)
+
+	zone.Timezone = strings.TrimSpace(request.Timezone)
+	if zone.Timezone != "" {
+		if _, err := time.LoadLocation(zone.Timezone); err != nil || zone.Timezone == "Local" {
+			return fmt.Errorf("неизвестный часовой пояс: %s", zone.Timezone)
+		}
+	}
+
+	if len(request.Geometry) == 0 || string(request.Geometry) == "null" {
+		return fmt.Errorf("геометрия зоны обязательна")
+	}
+	geometry, err := parsePolygonGeometry(request.Geometry)
+	if err != nil {
+		return err
+	}
+	zone.Geometry = geometry
+	zone.AreaKm2 = math.Round(geoMath.MultiPolygonAreaKm2(geometry["coordinates"].([][][][]float64))*100) / 100
+	return nil
+}
+
+// zoneWriteError ответ на ошибку записи: геометрию, которую не принял 2dsphere индекс, считаем ошибкой клиента
+func zoneWriteError(c *gin.Context, err error) {
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) && len(writeErr.WriteErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Геометрия зоны отклонена: %v", err)})
+		return
+	}
+	fmt.Printf("❌ Ошибка сохранения зоны: %v\n", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения зоны"})
+}
+
+// listZones список зон; geometry=false - без геометрий
+func listZones(c *gin.Context, collection useTables) {
+	ctx := context.Background()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	if c.Query("geometry") == "false" {
+		opts.SetProjection(bson.M{"geometry": 0})
+	}
+
+	cursor, err := collection.customZonesCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	zones := []customZone{}
+	if err := cursor.All(ctx, &zones); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+	c.JSON(http.StatusOK, zones)
+}
+
+// getZone зона по идентификатору
+func getZone(c *gin.Context, collection useTables) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный идентификатор зоны"})
+		return
+	}
+
+	var zone customZone
+	if err := collection.customZonesCollection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&zone); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Зона не найдена"})
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+// createZone создает зону из GeoJSON Polygon или MultiPolygon
+func createZone(c *gin.Context, collection useTables) {
+	var zone customZone
+	if err := parseZoneRequest(c, &zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	zone.ID = primitive.NewObjectID()
+	zone.CreatedAt = time.Now().UTC()
+	zone.UpdatedAt = zone.CreatedAt
+	if username, ok := auth.GetUsername(c); ok {
+		zone.CreatedBy = username
+	}
+
+	if _, err := collection.customZonesCollection.InsertOne(context.Background(), zone); err != nil {
+		zoneWriteError(c, err)
+		return
+	}
+	loadZoneDirectory(collection)
+	tileCache.Reset()
+
+	fmt.Printf("🔶 Создана зона '%s' (%.2f км²)\n", zone.Name, zone.AreaKm2)
+	c.JSON(http.StatusCreated, zone)
+}
+
+// updateZone заменяет название, описание, пояс и геометрию зоны
+func updateZone(c *gin.Context, collection useTables) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный идентификатор зоны"})
+		return
+	}
+
+	ctx := context.Background()
+
+	var zone customZone
+	if err := collection.customZonesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&zone); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Зона не найдена"})
+		return
+	}
+	if err := parseZoneRequest(c, &zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	zone.UpdatedAt = time.Now().UTC()
+
+	if _, err := collection.customZonesCollection.ReplaceOne(ctx, bson.M{"_id": id}, zone); err != nil {
+		zoneWriteError(c, err)
+		return
+	}
+	loadZoneDirectory(collection)
+	tileCache.Reset()
+
+	c.JSON(http.StatusOK, zone)
+}
+
+// deleteZone удаляет зону
+func deleteZone(c *gin.Context, collection useTables) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный идентификатор зоны"})
+		return
+	}
+
+	result, err := collection.customZonesCollection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления зоны"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Зона не найдена"})
+		return
+	}
+	loadZoneDirectory(collection)
+	tileCache.Reset()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Зона удалена"})
+}
+
+// getZoneStats статистика полетов с вылетом внутри зоны за период from..to:
+// число полетов и дронов, полеты по дням и часам, пиковый час, операторы и типы ВС.
+// tz=local использует часовой пояс зоны
+func getZoneStats(c *gin.Context, collection useTables) {
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры from и to обязательны"})
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат from"})
+		return
+	}
+	end, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат to"})
+		return
+	}
+
+	condition, zone, err := zoneCondition(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	timezone, _, err := resolveZoneTimezone(collection, timezoneParam(c), zone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔶 Статистика зоны '%s' с %s по %s (%s)\n", zone.Name, from, to, timezone)
+
+	match := bson.M{"searchFields.dateTime": bson.M{"$gte": start, "$lte": end}}
+	appendAnd(match, condition)
+
+	topBy := func(field string) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}}},
+			{{Key: "$group", Value: bson.M{"_id": "$" + field, "flightCount": bson.M{"$sum": 1}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "flightCount", Value: -1}, {Key: "_id", Value: 1}}}},
+			{{Key: "$limit", Value: zoneTopLimit}},
+			{{Key: "$project", Value: bson.M{"_id": 0, "name": "$_id", "flightCount": 1}}},
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: bson.M{
+			"totals": mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{
+					"_id":         nil,
+					"flightCount": bson.M{"$sum": 1},
+					"droneCount": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{"$shr.aircraftQuantity", 0}},
+						"$shr.aircraftQuantity",
+						1,
+					}}},
+				}}},
+			},
+			"daily": mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{
+					"_id": bson.M{"$dateToString": bson.M{
+						"format":   "%Y-%m-%d",
+						"date":     "$searchFields.dateTime",
+						"timezone": timezone,
+					}},
+					"flightCount": bson.M{"$sum": 1},
+				}}},
+				{{Key: "$sort", Value: bson.M{"_id": 1}}},
+				{{Key: "$project", Value: bson.M{"_id": 0, "date": "$_id", "flightCount": 1}}},
+			},
+			"hourly": mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{
+					"_id":         bson.M{"$hour": bson.M{"date": "$searchFields.dateTime", "timezone": timezone}},
+					"flightCount": bson.M{"$sum": 1},
+				}}},
+				{{Key: "$sort", Value: bson.M{"_id": 1}}},
+				{{Key: "$project", Value: bson.M{"_id": 0, "hour": "$_id", "flightCount": 1}}},
+			},
+			"operators":     topBy("shr.operator"),
+			"aircraftTypes": topBy("shr.aircraftType"),
+		}}},
+	}
+
+	ctx := context.Background()
+
+	cursor, err := collection.flightDataCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Printf("❌ Ошибка агрегации: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Totals        []bson.M `bson:"totals"`
+		Daily         []bson.M `bson:"daily"`
+		Hourly        []bson.M `bson:"hourly"`
+		Operators     []bson.M `bson:"operators"`
+		AircraftTypes []bson.M `bson:"aircraftTypes"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil || len(facets) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+	result := facets[0]
+
+	flightCount, droneCount := 0.0, 0.0
+	if len(result.Totals) > 0 {
+		flightCount = toFloat(result.Totals[0]["flightCount"])
+		droneCount = toFloat(result.Totals[0]["droneCount"])
+	}
+
+	// Пиковый час - с наибольшим числом полетов, при равенстве более ранний
+	var peakHour interface{}
+	hourly := append([]bson.M{}, result.Hourly...)
+	sort.SliceStable(hourly, func(i, j int) bool {
+		return toFloat(hourly[i]["flightCount"]) > toFloat(hourly[j]["flightCount"])
+	})
+	if len(hourly) > 0 {
+		peakHour = hourly[0]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"zone": gin.H{
+			"id":      zone.ID,
+			"name":    zone.Name,
+			"areaKm2": zone.AreaKm2,
+		},
+		"timezone":      timezone,
+		"flightCount":   int(flightCount),
+		"droneCount":    int(droneCount),
+		"daily":         emptyIfNil(result.Daily),
+		"hourly":        emptyIfNil(result.Hourly),
+		"peakHour":      peakHour,
+		"operators":     emptyIfNil(result.Operators),
+		"aircraftTypes": emptyIfNil(result.AircraftTypes),
+	})
+}
+
+// emptyIfNil пустой массив вместо null в JSON
+func emptyIfNil(rows []bson.M) []bson.M {
+	if rows == nil {
+		return []bson.M{}
+	}
+	return rows
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/packages/parsing/geoMath"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// buildFlightFilter собирает фильтр полетов из параметров запроса.
+// Общий для /flights-table, /flights-table/export, векторных тайлов и тепловой карты.
+// Ошибка возвращается только для некорректных пространственных фильтров и неизвестной зоны
+func buildFlightFilter(c *gin.Context) (bson.M, error) {
+	// Получаем параметры фильтров
+	aircraftType := c.Query("aircraftType")
+	dateDepFrom := c.Query("dateDepFrom")
+	dateDepTo := c.Query("dateDepTo")
+	flightDurationMin := c.Query("flightDurationMin")
+	flightDurationMax := c.Query("flightDurationMax")
+	sid := c.Query("sid")
+	region := c.Query("region")
+	operatorType := c.Query("operatorType")
+	qualityFlag := c.Query("qualityFlag")
+	district := c.Query("district")
+	regionMatch := c.Query("regionMatch")
+	regionConfidence := c.Query("regionConfidence")
+
+	filter := bson.M{}
+
+	// Добавляем фильтры если они переданы
+	if aircraftType != "" {
+		// Разделяем строку по запятым и убираем пробелы
+		aircraftTypes := strings.Split(aircraftType, ",")
+		for i, at := range aircraftTypes {
+			aircraftTypes[i] = strings.TrimSpace(at)
+		}
+		// Если только одно значение - используем обычный фильтр
+		if len(aircraftTypes) == 1 {
+			filter["shr.aircraftType"] = aircraftTypes[0]
+		} else {
+			// Если несколько значений - используем $in
+			filter["shr.aircraftType"] = bson.M{"$in": aircraftTypes}
+		}
+		fmt.Printf("✈️ Фильтр по типам самолетов: %v\n", aircraftTypes)
+	}
+
+	if operatorType != "" {
+		operatorTypes := strings.Split(operatorType, ",")
+		for i, ot := range operatorTypes {
+			operatorTypes[i] = strings.TrimSpace(ot)
+		}
+		if len(operatorTypes) == 1 {
+			filter["shr.operatorType"] = operatorTypes[0]
+		} else {
+			filter["shr.operatorType"] = bson.M{"$in": operatorTypes}
+		}
+		fmt.Printf("🏢 Фильтр по типам операторов: %v\n", operatorTypes)
+	}
+
+	start, _ := time.Parse(time.RFC3339, dateDepFrom)
+	end, _ := time.Parse(time.RFC3339, dateDepTo)
+
+	if dateDepFrom != "" {
+		if dateDepTo != "" {
+			filter["searchFields.dateTime"] = bson.M{
+				"$gte": start,
+				"$lte": end,
+			}
+		} else {
+			filter["searchFields.dateTime"] = bson.M{"$gte": start}
+		}
+	} else if dateDepTo != "" {
+		filter["searchFields.dateTime"] = bson.M{"$lte": end}
+	}
+
+	if sid != "" {
+		if sidInt, err := strconv.Atoi(sid); err == nil {
+			filter["shr.sid"] = sidInt
+		} else {
+			fmt.Printf("⚠️ Некорректный формат sid: %s\n", sid)
+		}
+	}
+
+	if flightDurationMin != "" {
+		durationMin, err := strconv.Atoi(flightDurationMin)
+		if err == nil {
+			if flightDurationMax != "" {
+				durationMax, err := strconv.Atoi(flightDurationMax)
+				if err == nil {
+					// Если минимальное значение = 0, включаем также null значения
+					if durationMin == 0 {
+						filter["$or"] = []bson.M{
+							{"shr.flightDuration": bson.M{"$gte": durationMin, "$lte": durationMax}},
+							{"shr.flightDuration": nil},
+						}
+					} else {
+						filter["shr.flightDuration"] = bson.M{
+							"$gte": durationMin,
+							"$lte": durationMax,
+						}
+					}
+				}
+			} else {
+				// Если минимальное значение = 0, включаем также null значения
+				if durationMin == 0 {
+					filter["$or"] = []bson.M{
+						{"shr.flightDuration": bson.M{"$gte": durationMin}},
+						{"shr.flightDuration": nil},
+					}
+				} else {
+					filter["shr.flightDuration"] = bson.M{"$gte": durationMin}
+				}
+			}
+		}
+	} else if flightDurationMax != "" {
+		durationMax, err := strconv.Atoi(flightDurationMax)
+		if err == nil {
+			filter["shr.flightDuration"] = bson.M{"$lte": durationMax}
+		}
+	}
+
+	if region != "" {
+		filter["region"] = regionDirectory.resolve(region)
+	}
+
+	if district != "" {
+		filter["district"] = district
+	}
+
+	// Способ привязки к региону: inside, nearest, none
+	if regionMatch != "" {
+		filter["regionMatch.method"] = regionMatch
+	}
+
+	// Уверенность в регионе: high, medium, low; conflict - источники координат расходятся
+	if regionConfidence == "conflict" {
+		filter["regionMatch.conflict"] = true
+	} else if regionConfidence != "" {
+		filter["regionMatch.confidence"] = bson.M{"$in": splitList(regionConfidence)}
+	}
+
+	// Расстояние вылет-посадка и средняя скорость
+	applyRangeFilter(filter, "distanceKm", c.Query("distanceKmMin"), c.Query("distanceKmMax"))
+	applyRangeFilter(filter, "avgSpeedKmh", c.Query("avgSpeedKmhMin"), c.Query("avgSpeedKmhMax"))
+
+	applyQualityFlagFilter(filter, qualityFlag)
+
+	// Пересечения с зонами ограничения
+	applyAirspaceFilter(filter, c.Query("airspace"), c.Query("airspaceType"))
+
+	// Аэродромы вылета и посадки
+	applyAerodromeFilter(filter, c.Query("aerodrome"), c.Query("aerodromeRole"))
+
+	// Ближайший населенный пункт
+	applySettlementFilter(c, filter)
+
+	if err := applySpatialFilters(c, filter); err != nil {
+		return nil, err
+	}
+
+	// Пользовательская зона: вылет внутри ее полигона
+	if zoneID := c.Query("zone"); zoneID != "" {
+		condition, zone, err := zoneCondition(zoneID)
+		if err != nil {
+			return nil, err
+		}
+		appendAnd(filter, condition)
+		fmt.Printf("🔶 Фильтр по зоне: %s\n", zone.Name)
+	}
+
+	return filter, nil
+}
+
+// applyRangeFilter добавляет диапазон по числовому полю; некорректные границы игнорируются
+func applyRangeFilter(filter bson.M, field, minValue, maxValue string) {
+	bounds := bson.M{}
+	if value, err := strconv.ParseFloat(minValue, 64); err == nil {
+		bounds["$gte"] = value
+	}
+	if value, err := strconv.ParseFloat(maxValue, 64); err == nil {
+		bounds["$lte"] = value
+	}
+	if len(bounds) > 0 {
+		filter[field] = bounds
+		fmt.Printf("📏 Фильтр по %s: %v\n", field, bounds)
+	}
+}
+
+// flightSortFields поля таблицы полетов, доступные для сортировки
+var flightSortFields = []string{"sid", "dateDep", "flightDuration", "distanceKm", "avgSpeedKmh"}
+
+// buildFlightSort стадия $sort по параметрам sortBy и sortOrder (asc, desc).
+// При равенстве значений порядок определяется по sid
+func buildFlightSort(c *gin.Context) (bson.D, error) {
+	sortBy := c.DefaultQuery("sortBy", "sid")
+	if !slices.Contains(flightSortFields, sortBy) {
+		return nil, fmt.Errorf("сортировка возможна по полям: %s", strings.Join(flightSortFields, ", "))
+	}
+
+	order := 1
+	switch c.DefaultQuery("sortOrder", "asc") {
+	case "asc":
+	case "desc":
+		order = -1
+	default:
+		return nil, fmt.Errorf("sortOrder должен быть asc или desc")
+	}
+
+	sortStage := bson.D{{Key: sortBy, Value: order}}
+	if sortBy != "sid" {
+		sortStage = append(sortStage, bson.E{Key: "sid", Value: 1})
+	}
+	return append(sortStage, bson.E{Key: "_id", Value: 1}), nil
+}
+
+// appendAnd добавляет условие в $and фильтра, не затирая уже добавленные
+func appendAnd(filter bson.M, condition bson.M) {
+	and, _ := filter["$and"].([]bson.M)
+	filter["$and"] = append(and, condition)
+}
+
+// earthRadiusKm радиус Земли для $centerSphere
+const earthRadiusKm = 6378.1
+
+// bboxStepDegrees шаг уплотнения сторон bbox: ребра полигонов в 2dsphere - дуги большого круга,
+// без промежуточных точек северная и южная стороны прямоугольника прогибаются к полюсу
+const bboxStepDegrees = 1.0
+
+// bboxPartWidth максимальная ширина части bbox по долготе: полигон шире полушария
+// MongoDB трактует как дополнение до сферы
+const bboxPartWidth = 90.0
+
+// maxPolygonLat широта, к которой прижимаются стороны bbox у полюсов, чтобы вершины не совпадали
+const maxPolygonLat = 89.99999
+
+// spatialFilterBody тело POST-запроса таблицы полетов
+type spatialFilterBody struct {
+	Polygon json.RawMessage `json:"polygon"`
+}
+
+// applySpatialFilters добавляет фильтры по точке вылета depPoint:
+// bbox=minLon,minLat,maxLon,maxLat, near=lat,lon с radiusKm и GeoJSON polygon из тела POST-запроса
+func applySpatialFilters(c *gin.Context, filter bson.M) error {
+	if bboxParam := c.Query("bbox"); bboxParam != "" {
+		bbox, err := parseBBox(bboxParam)
+		if err != nil {
+			return err
+		}
+		if bbox.MinX == bbox.MaxX || bbox.MinY == bbox.MaxY {
+			return fmt.Errorf("bbox нулевой площади")
+		}
+		appendAnd(filter, depPointWithin(bson.M{"$geometry": bboxGeometry(bbox)}))
+		fmt.Printf("🗺️ Фильтр по bbox: %s\n", bboxParam)
+	}
+
+	if near := c.Query("near"); near != "" {
+		lat, lon, err := parseLatLon(near)
+		if err != nil {
+			return err
+		}
+		radiusKm, err := strconv.ParseFloat(c.Query("radiusKm"), 64)
+		if err != nil || radiusKm <= 0 {
+			return fmt.Errorf("для near требуется положительный radiusKm")
+		}
+		appendAnd(filter, depPointWithin(bson.M{
+			"$centerSphere": bson.A{bson.A{lon, lat}, radiusKm / earthRadiusKm},
+		}))
+		fmt.Printf("📍 Фильтр по радиусу: %.1f км от %.5f,%.5f\n", radiusKm, lat, lon)
+	}
+
+	if c.Request.Method == http.MethodPost && c.Request.Body != nil {
+		var body spatialFilterBody
+		if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil && err != io.EOF {
+			return fmt.Errorf("неверный JSON в теле запроса: %v", err)
+		}
+		if len(body.Polygon) > 0 && string(body.Polygon) != "null" {
+			geometry, err := parsePolygonGeometry(body.Polygon)
+			if err != nil {
+				return err
+			}
+			appendAnd(filter, depPointWithin(bson.M{"$geometry": geometry}))
+			fmt.Printf("🔷 Фильтр по полигону: %s\n", geometry["type"])
+		}
+	}
+
+	return nil
+}
+
+// depPointWithin условие $geoWithin для точки вылета
+func depPointWithin(shape bson.M) bson.M {
+	return bson.M{"depPoint": bson.M{"$geoWithin": shape}}
+}
+
+// parseLatLon разбирает точку "lat,lon"
+func parseLatLon(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("near должен быть в формате lat,lon")
+	}
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, errLon := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLon != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return 0, 0, fmt.Errorf("неверные координаты near: %s", value)
+	}
+	return lat, lon, nil
+}
+
+// bboxGeometry прямоугольник в виде GeoJSON MultiPolygon для $geoWithin:
+// стороны уплотняются, широкий bbox делится на части не шире bboxPartWidth
+func bboxGeometry(bbox geoMath.BBox) bson.M {
+	minLat := math.Max(bbox.MinY, -maxPolygonLat)
+	maxLat := math.Min(bbox.MaxY, maxPolygonLat)
+
+	var polygons [][][][]float64
+	for west := bbox.MinX; west < bbox.MaxX; west += bboxPartWidth {
+		east := math.Min(west+bboxPartWidth, bbox.MaxX)
+
+		var ring [][]float64
+		steps := int(math.Ceil((east - west) / bboxStepDegrees))
+		for i := 0; i <= steps; i++ {
+			ring = append(ring, []float64{west + (east-west)*float64(i)/float64(steps), minLat})
+		}
+		for i := steps; i >= 0; i-- {
+			ring = append(ring, []float64{west + (east-west)*float64(i)/float64(steps), maxLat})
+		}
+		ring = append(ring, ring[0])
+
+		polygons = append(polygons, [][][]float64{ring})
+	}
+
+	return bson.M{"type": "MultiPolygon", "coordinates": polygons}
+}
+
+// parsePolygonGeometry разбирает GeoJSON Polygon или MultiPolygon (геометрию или Feature)
+// и замыкает незамкнутые кольца
+func parsePolygonGeometry(raw json.RawMessage) (bson.M, error) {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		return nil, fmt.Errorf("неверный GeoJSON полигона: %v", err)
+	}
+	if geometry.Type == "Feature" {
+		return parsePolygonGeometry(geometry.Geometry)
+	}
+
+	var polygons [][][][]float64
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("неверные координаты Polygon: %v", err)
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("неверные координаты MultiPolygon: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("поддерживаются только Polygon и MultiPolygon, получено: %q", geometry.Type)
+	}
+
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("полигон не содержит координат")
+	}
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			return nil, fmt.Errorf("полигон не содержит колец")
+		}
+		for r, ring := range polygon {
+			for _, point := range ring {
+				if len(point) < 2 || math.Abs(point[0]) > 180 || math.Abs(point[1]) > 90 {
+					return nil, fmt.Errorf("неверная точка полигона: %v", point)
+				}
+			}
+			if len(ring) > 0 {
+				first, last := ring[0], ring[len(ring)-1]
+				if first[0] != last[0] || first[1] != last[1] {
+					ring = append(ring, first)
+					polygon[r] = ring
+				}
+			}
+			if len(ring) < 4 {
+				return nil, fmt.Errorf("кольцо полигона должно содержать не менее 3 различных точек")
+			}
+		}
+	}
+
+	return bson.M{"type": "MultiPolygon", "coordinates": polygons}, nil
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/packages/parsing/gazetteer"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// settlementUndefined населенный пункт полетов без точки вылета или вдали от населенных пунктов
+const settlementUndefined = "Населенный пункт не определен"
+
+// loadGazetteer загружает справочник населенных пунктов в память и определяет ближайший
+// населенный пункт у полетов. all=true пересчитывает все полеты (после импорта справочника).
+// Возвращает число населенных пунктов и обновленных полетов
+func loadGazetteer(collection useTables, all bool) (int, int, error) {
+	empty, err := isCollectionEmpty(collection.settlementsCollection)
+	if err != nil {
+		return 0, 0, err
+	}
+	if empty {
+		if _, err := gazetteer.LoadDirToMongo(collection.settlementsCollection); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	index, err := gazetteer.LoadFromCollection(collection.settlementsCollection)
+	if err != nil {
+		return 0, 0, err
+	}
+	if index.Len() == 0 {
+		gazetteer.SetShared(nil)
+		return 0, 0, nil
+	}
+	gazetteer.SetShared(index)
+
+	if err := gazetteer.EnsureFlightIndexes(collection.flightDataCollection); err != nil {
+		fmt.Printf("⚠️ Ошибка создания индекса населенных пунктов: %v\n", err)
+	}
+
+	updated, err := gazetteer.ApplyToFlights(collection.flightDataCollection, index, all)
+	return index.Len(), updated, err
+}
+
+// importGazetteer загружает справочник населенных пунктов (поле file): выгрузка OSM
+// в GeoJSON или CSV. Справочник заменяется целиком, населенные пункты полетов пересчитываются
+func importGazetteer(c *gin.Context, collection useTables) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ожидается GeoJSON или CSV файл в поле file"})
+		return
+	}
+	defer file.Close()
+
+	settlements, skipped, err := gazetteer.Parse(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(settlements) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "В файле нет ни одного населенного пункта", "skipped": skipped})
+		return
+	}
+
+	start := time.Now()
+	imported, err := gazetteer.Replace(collection.settlementsCollection, settlements)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, updated, err := loadGazetteer(collection, true)
+	if err != nil {
+		fmt.Printf("❌ Ошибка применения справочника населенных пунктов: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tileCache.Reset()
+
+	fmt.Printf("🏘️ Справочник населенных пунктов: %d (пропущено %d), обновлено полетов %d за %v\n",
+		imported, skipped, updated, time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"imported":       imported,
+		"skipped":        skipped,
+		"flightsUpdated": updated,
+	})
+}
+
+// getNearestSettlement ближайший населенный пункт к точке lat, lon
+func getNearestSettlement(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(c.Query("lon"), 64)
+	if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры lat и lon обязательны"})
+		return
+	}
+
+	index := gazetteer.Shared()
+	if index == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Справочник населенных пунктов не загружен"})
+		return
+	}
+	nearest := index.Nearest(lat, lon)
+	if nearest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Населенных пунктов в радиусе %g км нет", gazetteer.MaxDistanceKm)})
+		return
+	}
+	c.JSON(http.StatusOK, nearest)
+}
+
+// getSettlements поиск по справочнику населенных пунктов: q - начало названия, region, type, limit
+func getSettlements(c *gin.Context, collection useTables) {
+	filter := bson.M{}
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(query), "$options": "i"}
+	}
+	if region := c.Query("region"); region != "" {
+		filter["region"] = regionDirectory.resolve(region)
+	}
+	if placeType := c.Query("type"); placeType != "" {
+		filter["type"] = bson.M{"$in": splitList(strings.ToLower(placeType))}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	ctx := context.Background()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "population", Value: -1}, {Key: "name", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"_id": 0})
+
+	cursor, err := collection.settlementsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	settlements := []gazetteer.Settlement{}
+	if err := cursor.All(ctx, &settlements); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+	c.JSON(http.StatusOK, settlements)
+}
+
+// applySettlementFilter фильтр по ближайшему населенному пункту: названия через запятую,
+// типы (city, town, village...) и расстояние до населенного пункта в км
+func applySettlementFilter(c *gin.Context, filter bson.M) {
+	if names := splitList(c.Query("settlement")); len(names) > 0 {
+		filter["nearestSettlement.name"] = bson.M{"$in": names}
+		fmt.Printf("🏘️ Фильтр по населенным пунктам: %v\n", names)
+	}
+	if types := splitList(strings.ToLower(c.Query("settlementType"))); len(types) > 0 {
+		filter["nearestSettlement.type"] = bson.M{"$in": types}
+	}
+	applyRangeFilter(filter, "nearestSettlement.distanceKm",
+		c.Query("settlementDistanceKmMin"), c.Query("settlementDistanceKmMax"))
+}
+
+// formatSettlement населенный пункт с типом для выгрузки: "Мытищи (город)"
+func formatSettlement(value interface{}) string {
+	settlement, ok := value.(bson.M)
+	if !ok {
+		return ""
+	}
+	name, _ := settlement["name"].(string)
+	if placeType, _ := settlement["type"].(string); placeType != "" {
+		return name + " (" + gazetteer.TypeLabel(placeType) + ")"
+	}
+	return name
+}
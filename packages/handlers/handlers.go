@@ -5,15 +5,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"project/packages/auth"
 	"project/packages/mongodb"
 	"project/packages/parsing"
+	"project/packages/parsing/aerodromes"
+	"project/packages/parsing/airspace"
+	"project/packages/parsing/gazetteer"
 	"project/packages/parsing/geoGet"
+	"project/packages/parsing/geoGrid"
 	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoMath"
 	"project/packages/parsing/geoSearch"
-	"runtime"
+	"project/packages/parsing/geoTree"
+	"project/packages/parsing/launchSites"
+	"project/packages/parsing/plausibility"
+	"sort"
 
 	"strconv"
 	"strings"
@@ -35,6 +45,12 @@ type useTables struct {
 	regionListCollection       *mongo.Collection
 	aircraftTypeListCollection *mongo.Collection
 	subjectListCollection      *mongo.Collection
+	districtListCollection     *mongo.Collection
+	launchSitesCollection      *mongo.Collection
+	customZonesCollection      *mongo.Collection
+	airspaceCollection         *mongo.Collection
+	aerodromesCollection       *mongo.Collection
+	settlementsCollection      *mongo.Collection
 }
 
 var (
@@ -48,6 +64,12 @@ func RegisterRoutes(r *gin.Engine, client *mongo.Client) {
 		mongodb.GetCollection(client, "admin", "regionList"),
 		mongodb.GetCollection(client, "admin", "aircraftTypeList"),
 		mongodb.GetCollection(client, "admin", "regionsGeo"),
+		mongodb.GetCollection(client, "admin", geoIndex.DistrictsCollectionName),
+		mongodb.GetCollection(client, "admin", launchSites.CollectionName),
+		mongodb.GetCollection(client, "admin", customZonesCollectionName),
+		mongodb.GetCollection(client, "admin", airspace.CollectionName),
+		mongodb.GetCollection(client, "admin", aerodromes.CollectionName),
+		mongodb.GetCollection(client, "admin", gazetteer.CollectionName),
 	}
 
 	// Инициализация при старте сервера
@@ -57,19 +79,50 @@ func RegisterRoutes(r *gin.Engine, client *mongo.Client) {
 	r.GET("/exists-data", func(c *gin.Context) { existsData(c, tables) })
 	r.GET("/regions", func(c *gin.Context) { getRegionList(c, tables) })
 	r.GET("/regions/geojson", func(c *gin.Context) { getRegionsGeo(c, tables) })
+	r.GET("/regions/:iso", getRegionByISO)
+	r.GET("/federal-districts/geojson", func(c *gin.Context) { getFederalDistrictsGeo(c, tables) })
 	r.GET("/flights-table", func(c *gin.Context) { getFlightTable(c, tables) })
 	r.GET("/flights-table/export", func(c *gin.Context) { exportFlightTable(c, tables) })
+	// POST-варианты принимают GeoJSON полигон в теле: {"polygon": {...}}
+	r.POST("/flights-table", func(c *gin.Context) { getFlightTable(c, tables) })
+	r.POST("/flights-table/export", func(c *gin.Context) { exportFlightTable(c, tables) })
 	r.GET("/heatmap", func(c *gin.Context) { getHeatmapData(c, tables) })
+	r.GET("/heatmap/bins", func(c *gin.Context) { getHeatmapBins(c, tables) })
 	r.GET("/peak-hour", func(c *gin.Context) { getPeakHour(c, tables) })
 	r.GET("/region/yearly-stats", func(c *gin.Context) { getYearlyStats(c, tables) })
 	r.GET("/avg-flight-duration", func(c *gin.Context) { getAvgFlightDuration(c, tables) })
 	r.GET("/top-10", func(c *gin.Context) { getTop10Regions(c, tables) })
 	r.GET("/flight-count", func(c *gin.Context) { getFlightCount(c, tables) })
-
-	r.POST("/clear-table", func(c *gin.Context) { clearTable(tables) })
+	r.GET("/data-quality", func(c *gin.Context) { getDataQuality(c, tables) })
+	r.GET("/region-flow", func(c *gin.Context) { getRegionFlow(c, tables) })
+	r.GET("/tiles/:layer/:z/:x/:y", func(c *gin.Context) { getVectorTile(c, tables) })
+	r.GET("/launch-sites", func(c *gin.Context) { getLaunchSites(c, tables) })
+	r.GET("/launch-sites/:id/flights", func(c *gin.Context) { getLaunchSiteFlights(c, tables) })
+	r.GET("/zones", func(c *gin.Context) { listZones(c, tables) })
+	r.GET("/zones/:id", func(c *gin.Context) { getZone(c, tables) })
+	r.GET("/zones/:id/stats", func(c *gin.Context) { getZoneStats(c, tables) })
+	r.POST("/zones", func(c *gin.Context) { createZone(c, tables) })
+	r.PUT("/zones/:id", func(c *gin.Context) { updateZone(c, tables) })
+	r.DELETE("/zones/:id", func(c *gin.Context) { deleteZone(c, tables) })
+	r.GET("/aerodromes", getAerodromes)
+	r.GET("/gazetteer/nearest", getNearestSettlement)
+	r.GET("/gazetteer/settlements", func(c *gin.Context) { getSettlements(c, tables) })
+	r.GET("/region-conflicts", func(c *gin.Context) { getRegionConflicts(c, tables) })
+	r.GET("/airspace", func(c *gin.Context) { getAirspaceAreas(c, tables) })
+	r.GET("/airspace/violations", func(c *gin.Context) { getAirspaceViolations(c, tables) })
+	r.GET("/airspace/violations/:designator/flights", func(c *gin.Context) { getAirspaceViolationFlights(c, tables) })
+	r.GET("/boundaries", func(c *gin.Context) { getBoundaryVersions(c, tables) })
+	r.GET("/boundaries/:name", func(c *gin.Context) { getBoundaryVersion(c, tables) })
+	r.GET("/geo-cache/stats", func(c *gin.Context) { c.JSON(http.StatusOK, geoSearch.CacheStatsByCollection()) })
+
+	r.POST("/clear-table", func(c *gin.Context) {
+		clearTable(tables)
+		tileCache.Reset()
+	})
 	r.POST("/upload", auth.RequireRealmRole("admin"), func(c *gin.Context) {
 		uploadFiles(c, tables, client)
 		updateAircraftTypeList(tables)
+		tileCache.Reset()
 		/* 		err := geoSearch.UpdateFlightRegions(client)
 		   		if err != nil {
 		   			fmt.Printf("❌ Ошибка обновления поля регион основной таблицы : %v\n", err)
@@ -81,8 +134,23 @@ func RegisterRoutes(r *gin.Engine, client *mongo.Client) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		tileCache.Reset()
 		c.JSON(http.StatusOK, gin.H{"message": "Субъекты РФ обновлены"})
 	})
+	// Загрузка таблицы населения регионов (CSV)
+	r.POST("/regions/population", auth.RequireRealmRole("admin"), func(c *gin.Context) { importPopulation(c, tables) })
+	// Загрузка справочника аэродромов (CSV)
+	r.POST("/aerodromes/import", auth.RequireRealmRole("admin"), func(c *gin.Context) { importAerodromes(c, tables) })
+	// Загрузка справочника населенных пунктов (GeoJSON выгрузка OSM или CSV)
+	r.POST("/gazetteer/import", auth.RequireRealmRole("admin"), func(c *gin.Context) { importGazetteer(c, tables) })
+	// Перезагрузка зон ограничения из geojsonFiles/airspace и перепроверка полетов
+	r.POST("/airspace/reload", auth.RequireRealmRole("admin"), func(c *gin.Context) { reloadAirspace(c, tables) })
+	// Версии наборов границ: загрузка с проверкой, переключение и удаление
+	r.POST("/boundaries", auth.RequireRealmRole("admin"), func(c *gin.Context) { uploadBoundaryVersion(c, tables, client) })
+	r.POST("/boundaries/:name/activate", auth.RequireRealmRole("admin"), func(c *gin.Context) { activateBoundaryVersion(c, tables, client) })
+	r.DELETE("/boundaries/:name", auth.RequireRealmRole("admin"), func(c *gin.Context) { deleteBoundaryVersion(c, tables) })
+	// Отдельный endpoint для пересчета реестра мест запуска
+	r.POST("/launch-sites/rebuild", auth.RequireRealmRole("admin"), func(c *gin.Context) { rebuildLaunchSites(c, tables) })
 	// Отдельный endpoint для перезагрузки 2dsphere индексов и списка регионов
 	r.POST("/geoindex", func(c *gin.Context) {
 		if err := geoIndex.LoadRegionsToMongo(tables.subjectListCollection); err != nil {
@@ -90,16 +158,68 @@ func RegisterRoutes(r *gin.Engine, client *mongo.Client) {
 			return
 		}
 
-		updateRegionList(tables)
+		refreshBoundaries(tables)
 		c.JSON(http.StatusOK, gin.H{"message": "Гео-индексы загружены"})
 	})
 
 }
 
-// getRegionsGeo handler для получения геоданных регионов
+// getRegionsGeo handler для получения геоданных регионов.
+// Необязательные параметры: tolerance (допуск упрощения в градусах) или zoom (уровень карты),
+// bbox=minLon,minLat,maxLon,maxLat и regions=Регион1,Регион2
 func getRegionsGeo(c *gin.Context, tables useTables) {
+	toleranceParam := c.Query("tolerance")
+	zoomParam := c.Query("zoom")
+	bboxParam := c.Query("bbox")
+	regionsParam := c.Query("regions")
+
+	// Без параметров - полные геометрии, как раньше
+	if toleranceParam == "" && zoomParam == "" && bboxParam == "" && regionsParam == "" {
+		regions, err := geoGet.GetRegionsGeo(tables.subjectListCollection)
+		if err != nil {
+			fmt.Printf("❌ Ошибка get запроса по geojson: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные по geojson"})
+			return
+		}
+		c.JSON(http.StatusOK, regions)
+		return
+	}
+
+	tolerance := 0.0
+	if toleranceParam != "" {
+		value, err := strconv.ParseFloat(toleranceParam, 64)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр tolerance"})
+			return
+		}
+		tolerance = value
+	} else if zoomParam != "" {
+		zoom, err := strconv.Atoi(zoomParam)
+		if err != nil || zoom < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр zoom"})
+			return
+		}
+		tolerance = geoGet.ToleranceForZoom(zoom)
+	}
+
+	var filter geoGet.RegionFilter
+	if bboxParam != "" {
+		bbox, err := parseBBox(bboxParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.BBox = &bbox
+	}
+	if regionsParam != "" {
+		for _, name := range strings.Split(regionsParam, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				filter.Names = append(filter.Names, regionDirectory.resolve(name))
+			}
+		}
+	}
 
-	regions, err := geoGet.GetRegionsGeo(tables.subjectListCollection)
+	regions, err := geoGet.GetRegionsGeoSimplified(tables.subjectListCollection, tolerance, filter)
 	if err != nil {
 		fmt.Printf("❌ Ошибка get запроса по geojson: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные по geojson"})
@@ -108,6 +228,29 @@ func getRegionsGeo(c *gin.Context, tables useTables) {
 	c.JSON(http.StatusOK, regions)
 }
 
+// parseBBox разбирает прямоугольник minLon,minLat,maxLon,maxLat
+func parseBBox(value string) (geoMath.BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return geoMath.BBox{}, fmt.Errorf("bbox должен содержать 4 числа: minLon,minLat,maxLon,maxLat")
+	}
+
+	var numbers [4]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return geoMath.BBox{}, fmt.Errorf("неверное число в bbox: %s", part)
+		}
+		numbers[i] = number
+	}
+
+	bbox := geoMath.BBox{MinX: numbers[0], MinY: numbers[1], MaxX: numbers[2], MaxY: numbers[3]}
+	if bbox.MinX > bbox.MaxX || bbox.MinY > bbox.MaxY {
+		return geoMath.BBox{}, fmt.Errorf("неверный bbox: минимум больше максимума")
+	}
+	return bbox, nil
+}
+
 func serverInit(collection useTables) {
 
 	subjectListCollection := collection.subjectListCollection
@@ -126,12 +269,102 @@ func serverInit(collection useTables) {
 				fmt.Printf("⚠️ Ошибка загрузки гео-индексов: %v", err)
 			}
 		}
+		// Досчитываем флаги качества, расстояние и скорость у ранее загруженных полетов
+		if updated, err := plausibility.BackfillMotion(collection.flightDataCollection); err != nil {
+			fmt.Printf("⚠️ Ошибка досчета проверок полетов: %v\n", err)
+		} else if updated > 0 {
+			fmt.Printf("✅ Флаги качества, расстояние и скорость досчитаны у %d полетов\n", updated)
+		}
+
+		// Атрибуты регионов (ISO, федеральный округ, площадь, пояс, население), загруженных до их появления
+		if err := geoIndex.ApplyMetadata(subjectListCollection); err != nil {
+			fmt.Printf("⚠️ Ошибка проставления атрибутов регионов: %v\n", err)
+		}
+
 		// Обновляем список регионов
 		updateRegionList(collection)
+		loadRegionIndex(collection)
+		go precomputeSimplified(collection)
+
+		// Пользовательские зоны
+		ensureZoneIndexes(collection)
+		loadZoneDirectory(collection)
+
+		// Справочник аэродромов и коды ADEP/ADARR ранее загруженных полетов
+		if count, updated, _, err := loadAerodromes(collection, false); err != nil {
+			fmt.Printf("⚠️ Ошибка загрузки справочника аэродромов: %v\n", err)
+		} else if count > 0 || updated > 0 {
+			fmt.Printf("✅ Аэродромов в справочнике: %d, обновлено полетов %d\n", count, updated)
+		}
+
+		// Справочник населенных пунктов и ближайшие населенные пункты ранее загруженных полетов
+		if count, updated, err := loadGazetteer(collection, false); err != nil {
+			fmt.Printf("⚠️ Ошибка загрузки справочника населенных пунктов: %v\n", err)
+		} else if count > 0 {
+			fmt.Printf("✅ Населенных пунктов в справочнике: %d, обновлено полетов %d\n", count, updated)
+		}
+
+		// Зоны ограничения и проверка ранее загруженных полетов
+		if _, areas, checked, err := loadAirspace(collection, false); err != nil {
+			fmt.Printf("⚠️ Ошибка загрузки зон ограничения: %v\n", err)
+		} else if areas > 0 {
+			fmt.Printf("✅ Зоны ограничения: %d, проверено полетов %d\n", areas, checked)
+		}
 	})
 
 }
 
+// useMemoryGeoEngine включен ли поиск регионов в памяти (GEO_ENGINE=memory)
+func useMemoryGeoEngine() bool {
+	return strings.ToLower(strings.TrimSpace(os.Getenv("GEO_ENGINE"))) == "memory"
+}
+
+// loadRegionIndex загружает индекс регионов в память из regionsGeo.
+// Если коллекция пуста или недоступна, индекс строится по файлам geojsonFiles
+func loadRegionIndex(collection useTables) {
+	if !useMemoryGeoEngine() {
+		return
+	}
+
+	start := time.Now()
+	index, err := geoTree.LoadFromCollection(collection.subjectListCollection)
+	if err == nil {
+		if districts, err := geoTree.LoadFromCollection(collection.districtListCollection); err == nil {
+			index.SetDistricts(districts.WithUndefinedName("Район не определен"))
+		}
+	} else {
+		fmt.Printf("⚠️ Ошибка загрузки индекса регионов из MongoDB: %v, читаем geojsonFiles\n", err)
+		index, err = loadRegionIndexFromDir()
+		if err != nil {
+			fmt.Printf("⚠️ Ошибка загрузки индекса регионов в память: %v\n", err)
+			return
+		}
+	}
+	geoTree.SetShared(index)
+	fmt.Printf("✅ Индекс регионов в памяти: %d регионов за %v\n", index.Len(), time.Since(start).Round(time.Millisecond))
+}
+
+// loadRegionIndexFromDir строит индекс регионов и районов по папке geojsonFiles
+func loadRegionIndexFromDir() (*geoTree.RegionIndex, error) {
+	dir, err := geoIndex.RegionsDir()
+	if err != nil {
+		return nil, err
+	}
+	return geoTree.LoadFromDir(dir)
+}
+
+// newRegionResolver возвращает сервис определения региона:
+// индекс в памяти при GEO_ENGINE=memory, иначе запросы к MongoDB
+func newRegionResolver(client *mongo.Client) parsing.GeoServiceInterface {
+	if useMemoryGeoEngine() {
+		if index := geoTree.Shared(); index != nil {
+			return index
+		}
+		fmt.Println("⚠️ Индекс регионов в памяти не загружен, используем MongoDB")
+	}
+	return geoSearch.NewGeoService(client)
+}
+
 // isCollectionEmpty проверяет, пустая ли коллекция
 func isCollectionEmpty(collection *mongo.Collection) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -190,8 +423,8 @@ func getFlightTable(c *gin.Context, collection useTables) {
 	page := c.DefaultQuery("page", "1")
 	limit := c.DefaultQuery("limit", "20")
 
-	// Получаем параметр временной зоны
-	timezone := c.Query("timezone")
+	// Получаем параметр временной зоны: local, UTC или пояс IANA
+	timezone := timezoneParam(c)
 
 	pageInt, err := strconv.Atoi(page)
 	if err != nil || pageInt < 1 {
@@ -206,16 +439,6 @@ func getFlightTable(c *gin.Context, collection useTables) {
 		limitInt = 1000
 	}
 
-	// Получаем параметры фильтров
-	aircraftType := c.Query("aircraftType")
-	dateDepFrom := c.Query("dateDepFrom")
-	dateDepTo := c.Query("dateDepTo")
-	flightDurationMin := c.Query("flightDurationMin")
-	flightDurationMax := c.Query("flightDurationMax")
-	sid := c.Query("sid")
-	region := c.Query("region")
-	operatorType := c.Query("operatorType")
-
 	// Вычисляем skip
 	skip := (pageInt - 1) * limitInt
 
@@ -224,109 +447,10 @@ func getFlightTable(c *gin.Context, collection useTables) {
 	fmt.Printf("📊 Получение таблицы полетов - страница %d, лимит %d\n", pageInt, limitInt)
 
 	// Создаем базовый фильтр для запросов
-	filter := bson.M{}
-
-	// Добавляем фильтры если они переданы
-	if aircraftType != "" {
-		// Разделяем строку по запятым и убираем пробелы
-		aircraftTypes := strings.Split(aircraftType, ",")
-		// Очищаем от пробелов
-		for i, at := range aircraftTypes {
-			aircraftTypes[i] = strings.TrimSpace(at)
-		}
-		// Если только одно значение - используем обычный фильтр
-		if len(aircraftTypes) == 1 {
-			filter["shr.aircraftType"] = aircraftTypes[0]
-		} else {
-			// Если несколько значений - используем $in
-			filter["shr.aircraftType"] = bson.M{"$in": aircraftTypes}
-		}
-		fmt.Printf("✈️ Фильтр по типам самолетов: %v\n", aircraftTypes)
-	}
-
-	// Добавляем фильтры если они переданы
-	if operatorType != "" {
-		// Разделяем строку по запятым и убираем пробелы
-		operatorTypes := strings.Split(operatorType, ",")
-		// Очищаем от пробелов
-		for i, at := range operatorTypes {
-			operatorTypes[i] = strings.TrimSpace(at)
-		}
-		// Если только одно значение - используем обычный фильтр
-		if len(operatorTypes) == 1 {
-			filter["shr.operatorType"] = operatorTypes[0]
-		} else {
-			// Если несколько значений - используем $in
-			filter["shr.operatorType"] = bson.M{"$in": operatorTypes}
-		}
-		fmt.Printf("✈️ Фильтр по типам самолетов: %v\n", operatorTypes)
-	}
-
-	start, _ := time.Parse(time.RFC3339, dateDepFrom)
-	end, _ := time.Parse(time.RFC3339, dateDepTo)
-
-	if dateDepFrom != "" {
-		if dateDepTo != "" {
-			filter["searchFields.dateTime"] = bson.M{
-				"$gte": start,
-				"$lte": end,
-			}
-		} else {
-			filter["searchFields.dateTime"] = bson.M{"$gte": start}
-		}
-	} else if dateDepTo != "" {
-		filter["searchFields.dateTime"] = bson.M{"$lte": end}
-	}
-
-	if sid != "" {
-		if sidInt, err := strconv.Atoi(sid); err == nil {
-			filter["shr.sid"] = sidInt
-		} else {
-			fmt.Printf("⚠️ Некорректный формат sid: %s\n", sid)
-		}
-	}
-
-	if flightDurationMin != "" {
-		durationMin, err := strconv.Atoi(flightDurationMin)
-		if err == nil {
-			if flightDurationMax != "" {
-				durationMax, err := strconv.Atoi(flightDurationMax)
-				if err == nil {
-					// Если минимальное значение = 0, включаем также null значения
-					if durationMin == 0 {
-						filter["$or"] = []bson.M{
-							{"shr.flightDuration": bson.M{"$gte": durationMin, "$lte": durationMax}},
-							{"shr.flightDuration": nil},
-						}
-					} else {
-						filter["shr.flightDuration"] = bson.M{
-							"$gte": durationMin,
-							"$lte": durationMax,
-						}
-					}
-				}
-			} else {
-				// Если минимальное значение = 0, включаем также null значения
-				if durationMin == 0 {
-					filter["$or"] = []bson.M{
-						{"shr.flightDuration": bson.M{"$gte": durationMin}},
-						{"shr.flightDuration": nil},
-					}
-				} else {
-					filter["shr.flightDuration"] = bson.M{"$gte": durationMin}
-				}
-			}
-		}
-	} else if flightDurationMax != "" {
-		durationMax, err := strconv.Atoi(flightDurationMax)
-		if err == nil {
-			// Для максимального значения тоже можно включить null, если нужно
-			filter["shr.flightDuration"] = bson.M{"$lte": durationMax}
-		}
-	}
-
-	if region != "" {
-		filter["region"] = region
+	filter, err := buildFlightFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Создаем pipeline для агрегации
@@ -339,14 +463,25 @@ func getFlightTable(c *gin.Context, collection useTables) {
 
 	// Проекция нужных полей
 	projectFields := bson.M{
-		"region":           1,
-		"sid":              "$shr.sid",
-		"aircraftIndex":    "$shr.aircraftIndex",
-		"aircraftType":     "$shr.aircraftType",
-		"aircraftQuantity": "$shr.aircraftQuantity",
-		"operator":         "$shr.operator",
-		"operatorType":     "$shr.operatorType",
-		"flightDuration":   "$shr.flightDuration",
+		"region":            1,
+		"district":          1,
+		"arrRegion":         1,
+		"crossRegion":       1,
+		"regionMatch":       1,
+		"sid":               "$shr.sid",
+		"aircraftIndex":     "$shr.aircraftIndex",
+		"aircraftType":      "$shr.aircraftType",
+		"aircraftQuantity":  "$shr.aircraftQuantity",
+		"operator":          "$shr.operator",
+		"operatorType":      "$shr.operatorType",
+		"flightDuration":    "$shr.flightDuration",
+		"distanceKm":        1,
+		"avgSpeedKmh":       1,
+		"qualityFlags":      1,
+		"airspace":          "$airspace.designator",
+		"airportDep":        "$dep.airport",
+		"airportArr":        "$arr.airport",
+		"nearestSettlement": 1,
 		"coordinatesDep": bson.M{
 			"$let": bson.M{
 				"vars": bson.M{
@@ -428,17 +563,22 @@ func getFlightTable(c *gin.Context, collection useTables) {
 		//"rawText": "$shr.rawText",
 	}
 
-	// Добавляем поля дат с учетом временной зоны
+	// Добавляем поля дат с учетом временной зоны: местное время без смещения,
+	// переходы на летнее время и исторические изменения поясов учитывает MongoDB
 	if timezone != "" {
-		// Получаем смещение в часах для временной зоны
-		offsetHours := getTimezoneOffset(timezone)
+		timezoneExpr, err := timezoneExpression(collection, timezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		projectFields["dateDep"] = bson.M{
 			"$cond": bson.M{
 				"if": bson.M{"$ne": bson.A{"$searchFields.dateTime", nil}},
 				"then": bson.M{"$dateToString": bson.M{
-					"date":   bson.M{"$add": bson.A{"$searchFields.dateTime", offsetHours * 60 * 60 * 1000}}, // смещение в миллисекундах
-					"format": "%Y-%m-%dT%H:%M:%S.%LZ",
+					"date":     "$searchFields.dateTime",
+					"format":   "%Y-%m-%dT%H:%M:%S.%L",
+					"timezone": timezoneExpr,
 				}},
 				"else": nil,
 			},
@@ -447,8 +587,9 @@ func getFlightTable(c *gin.Context, collection useTables) {
 			"$cond": bson.M{
 				"if": bson.M{"$ne": bson.A{"$searchFields.arrDatetime", nil}},
 				"then": bson.M{"$dateToString": bson.M{
-					"date":   bson.M{"$add": bson.A{"$searchFields.arrDatetime", offsetHours * 60 * 60 * 1000}}, // смещение в миллисекундах
-					"format": "%Y-%m-%dT%H:%M:%S.%LZ",
+					"date":     "$searchFields.arrDatetime",
+					"format":   "%Y-%m-%dT%H:%M:%S.%L",
+					"timezone": timezoneExpr,
 				}},
 				"else": nil,
 			},
@@ -462,8 +603,13 @@ func getFlightTable(c *gin.Context, collection useTables) {
 	// Проекция нужных полей
 	pipeline = append(pipeline, bson.D{{Key: "$project", Value: projectFields}})
 
-	// Сортировка по sid
-	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.M{"sid": 1}}})
+	// Сортировка: sortBy и sortOrder, по умолчанию по sid
+	sortStage, err := buildFlightSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sortStage}})
 
 	// Пагинация
 	pipeline = append(pipeline, bson.D{{Key: "$skip", Value: skip}})
@@ -517,17 +663,22 @@ func getFlightTable(c *gin.Context, collection useTables) {
 			{Key: "$group", Value: bson.M{
 				"_id":               nil,
 				"maxFlightDuration": bson.M{"$max": "$shr.flightDuration"},
+				"maxDistanceKm":     bson.M{"$max": "$distanceKm"},
+				"maxAvgSpeedKmh":    bson.M{"$max": "$avgSpeedKmh"},
 			}},
 		},
 	}
 
 	maxDurationCursor, err := flightDataCollection.Aggregate(ctx, maxDurationPipeline)
 	var maxFlightDuration int = 0
+	var maxDistanceKm, maxAvgSpeedKmh float64
 	if err == nil {
 		defer maxDurationCursor.Close(ctx)
 		var maxResults []bson.M
 		if err := maxDurationCursor.All(ctx, &maxResults); err == nil && len(maxResults) > 0 {
 			maxFlightDuration = int(maxResults[0]["maxFlightDuration"].(float64))
+			maxDistanceKm, _ = maxResults[0]["maxDistanceKm"].(float64)
+			maxAvgSpeedKmh, _ = maxResults[0]["maxAvgSpeedKmh"].(float64)
 		}
 	} else {
 		fmt.Printf("❌ Ошибка получения maxFlightDuration: %v\n", err)
@@ -538,7 +689,16 @@ func getFlightTable(c *gin.Context, collection useTables) {
 	filtersMeta = bson.M{
 		"aircraftTypes":     aircraftTypes,
 		"maxFlightDuration": maxFlightDuration,
+		"maxDistanceKm":     math.Ceil(maxDistanceKm),
+		"maxAvgSpeedKmh":    math.Ceil(maxAvgSpeedKmh),
 		"operatorTypes":     operatorTypes,
+		"sortFields":        flightSortFields,
+		"qualityFlags":      plausibility.AllFlags,
+		"regionMatch":       []string{parsing.MatchInside, parsing.MatchNearest, parsing.MatchNone},
+		"regionConfidence":  []string{parsing.ConfidenceHigh, parsing.ConfidenceMedium, parsing.ConfidenceLow, "conflict"},
+		"airspaceTypes": []string{
+			airspace.TypeProhibited, airspace.TypeRestricted, airspace.TypeDanger, airspace.TypeCTR, airspace.TypeOther,
+		},
 	}
 
 	fmt.Printf("📈 Получено %d записей из %d (страница %d)\n", len(results), totalCount, pageInt)
@@ -558,48 +718,246 @@ func getFlightTable(c *gin.Context, collection useTables) {
 	c.JSON(http.StatusOK, response)
 }
 
-// getTimezoneOffset возвращает смещение временной зоны в часах
-func getTimezoneOffset(timezone string) int64 {
-
-	russianTimezones := map[string]int64{
-		"Europe/Kaliningrad": -2,  // UTC+2 - Калининградская область
-		"Europe/Moscow":      -3,  // UTC+3 - Москва, Центральная Россия
-		"Europe/Samara":      -4,  // UTC+4 - Самара, Удмуртия
-		"Asia/Yekaterinburg": -5,  // UTC+5 - Екатеринбург, Урал
-		"Asia/Omsk":          -6,  // UTC+6 - Омск, Западная Сибирь
-		"Asia/Krasnoyarsk":   -7,  // UTC+7 - Красноярск, Центральная Сибирь
-		"Asia/Irkutsk":       -8,  // UTC+8 - Иркутск, Восточная Сибирь
-		"Asia/Chita":         -9,  // UTC+9 - Чита, Забайкалье
-		"Asia/Vladivostok":   -10, // UTC+10 - Владивосток, Приморский край
-		"Asia/Magadan":       -11, // UTC+11 - Магадан, Магаданская область
-		"Asia/Kamchatka":     -12, // UTC+12 - Камчатка
-		"Asia/Anadyr":        -12, // UTC+12 - Анадырь, Чукотка
-		"Europe/Volgograd":   -3,  // UTC+3 - Волгоград
-		"Europe/Astrakhan":   -4,  // UTC+4 - Астрахань
-		"UTC":                0,   // UTC+0
-	}
-
-	if offset, exists := russianTimezones[timezone]; exists {
-		return offset
-	}
-
-	// По умолчанию UTC
-	return 0
+// applyQualityFlagFilter добавляет фильтр по флагам качества данных.
+// Значения: список флагов через запятую, "any" - есть хотя бы один флаг, "none" - без флагов
+func applyQualityFlagFilter(filter bson.M, qualityFlag string) {
+	if qualityFlag == "" {
+		return
+	}
+
+	switch qualityFlag {
+	case "any":
+		filter["qualityFlags.0"] = bson.M{"$exists": true}
+	case "none":
+		filter["qualityFlags.0"] = bson.M{"$exists": false}
+	default:
+		flags := strings.Split(qualityFlag, ",")
+		for i, f := range flags {
+			flags[i] = strings.TrimSpace(f)
+		}
+		filter["qualityFlags"] = bson.M{"$in": flags}
+	}
+	fmt.Printf("🚩 Фильтр по флагам качества: %s\n", qualityFlag)
+}
+
+// formatRegionMatch подпись способа привязки к региону для выгрузки
+func formatRegionMatch(value interface{}) string {
+	match, ok := value.(bson.M)
+	if !ok {
+		return ""
+	}
+
+	label := ""
+	switch match["method"] {
+	case parsing.MatchInside:
+		label = "внутри региона"
+	case parsing.MatchNearest:
+		label = "ближайший"
+		if distance, ok := match["distanceMeters"].(float64); ok {
+			label = fmt.Sprintf("ближайший (%.0f м)", distance)
+		}
+	case parsing.MatchNone:
+		label = "не определен"
+	}
+	if conflict, _ := match["conflict"].(bool); conflict {
+		label += fmt.Sprintf("; по второму источнику: %v", match["altRegion"])
+	}
+	return label
+}
+
+// getDataQuality возвращает количество полетов по каждому флагу качества данных
+func getDataQuality(c *gin.Context, collection useTables) {
+	flightDataCollection := collection.flightDataCollection
+
+	// Необязательный период
+	from := c.Query("from")
+	to := c.Query("to")
+
+	filter := bson.M{}
+	if from != "" || to != "" {
+		dateFilter := bson.M{}
+		if from != "" {
+			start, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат from"})
+				return
+			}
+			dateFilter["$gte"] = start
+		}
+		if to != "" {
+			end, err := time.Parse(time.RFC3339, to)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат to"})
+				return
+			}
+			dateFilter["$lte"] = end
+		}
+		filter["searchFields.dateTime"] = dateFilter
+	}
+
+	ctx := context.Background()
+
+	fmt.Println("🚩 Получение статистики качества данных")
+
+	totalCount, err := flightDataCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		fmt.Printf("❌ Ошибка подсчета документов: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"flagged": bson.A{
+				bson.M{"$match": bson.M{"qualityFlags.0": bson.M{"$exists": true}}},
+				bson.M{"$count": "count"},
+			},
+			"byFlag": bson.A{
+				bson.M{"$unwind": "$qualityFlags"},
+				bson.M{"$group": bson.M{"_id": "$qualityFlags", "count": bson.M{"$sum": 1}}},
+			},
+			"byRegionMatch": bson.A{
+				bson.M{"$match": bson.M{"regionMatch.method": bson.M{"$exists": true}}},
+				bson.M{"$group": bson.M{"_id": "$regionMatch.method", "count": bson.M{"$sum": 1}}},
+			},
+		}}},
+	}
+
+	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Printf("❌ Ошибка агрегации: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Flagged []struct {
+			Count int64 `bson:"count"`
+		} `bson:"flagged"`
+		ByFlag []struct {
+			Flag  string `bson:"_id"`
+			Count int64  `bson:"count"`
+		} `bson:"byFlag"`
+		ByRegionMatch []struct {
+			Method string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		} `bson:"byRegionMatch"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		fmt.Printf("❌ Ошибка декодирования: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	// Все известные флаги присутствуют в ответе, даже с нулевым количеством
+	counts := make(map[string]int64)
+	var flaggedCount int64
+	if len(facets) > 0 {
+		for _, f := range facets[0].ByFlag {
+			counts[f.Flag] = f.Count
+		}
+		if len(facets[0].Flagged) > 0 {
+			flaggedCount = facets[0].Flagged[0].Count
+		}
+	}
+
+	flags := []bson.M{}
+	for _, flag := range plausibility.AllFlags {
+		flags = append(flags, bson.M{"flag": flag, "count": counts[flag]})
+	}
+
+	// Распределение по способу привязки к региону
+	matchCounts := bson.M{parsing.MatchInside: 0, parsing.MatchNearest: 0, parsing.MatchNone: 0}
+	if len(facets) > 0 {
+		for _, m := range facets[0].ByRegionMatch {
+			matchCounts[m.Method] = m.Count
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       totalCount,
+		"flagged":     flaggedCount,
+		"clean":       totalCount - flaggedCount,
+		"flags":       flags,
+		"regionMatch": matchCounts,
+	})
 }
 
 func getYearlyStats(c *gin.Context, collection useTables) {
 	flightDataCollection := collection.flightDataCollection
 
-	// Получаем параметры из query string
-	region := c.Query("region")
+	// Получаем параметры из query string (регион - название или код ISO 3166-2)
+	region := regionDirectory.resolve(c.Query("region"))
 	from := c.Query("from")
 	to := c.Query("to")
 
-	if region == "" || from == "" || to == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры region, from и to обязательны"})
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры from и to обязательны"})
 		return
 	}
 
+	// Уровень: region (по умолчанию), district (region и district), federal (federalDistrict)
+	// или settlement (region и settlement).
+	// Параметр zone=<id> заменяет регион пользовательской зоной
+	level, err := parseAggregationLevel(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match := bson.M{}
+	regions := []string{region}
+	label := region
+	var zone *customZone
+	zoneID := c.Query("zone")
+	switch {
+	case zoneID != "":
+		// Пользовательская зона вместо региона
+		condition, found, err := zoneCondition(zoneID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		match = condition
+		zone = &found
+		label = "зона " + found.Name
+	case level.name == levelRegion:
+		if region == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры region, from и to обязательны"})
+			return
+		}
+		match["region"] = region
+	case level.name == levelDistrict:
+		district := c.Query("district")
+		if region == "" || district == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Для level=district параметры region и district обязательны"})
+			return
+		}
+		match["region"] = region
+		match["district"] = district
+		label = region + ", " + district
+	case level.name == levelSettlement:
+		settlement := c.Query("settlement")
+		if region == "" || settlement == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Для level=settlement параметры region и settlement обязательны"})
+			return
+		}
+		match["region"] = region
+		match["nearestSettlement.name"] = settlement
+		label = region + ", " + settlement
+	case level.name == levelFederal:
+		name, districtRegions, err := federalDistrictRegions(c.Query("federalDistrict"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match["region"] = bson.M{"$in": districtRegions}
+		regions = districtRegions
+		label = name + " федеральный округ"
+	}
+
 	ctx := context.Background()
 
 	// Парсим даты
@@ -615,29 +973,42 @@ func getYearlyStats(c *gin.Context, collection useTables) {
 		return
 	}
 
-	// Нормализуем даты (отбрасываем время)
-	startDate := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
-	endDate := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())
+	// Часовой пояс, в котором считаются сутки: tz=local|UTC|<IANA>
+	var timezone string
+	var location *time.Location
+	if zone != nil {
+		timezone, location, err = resolveZoneTimezone(collection, timezoneParam(c), *zone)
+	} else {
+		timezone, location, err = resolveTimezone(collection, timezoneParam(c), regions...)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Нормализуем даты (отбрасываем время), границы суток - в выбранном поясе
+	startDate := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	endDate := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location)
 
-	fmt.Printf("📅 Получение статистики по дням для региона '%s' с %s по %s\n",
-		region, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	fmt.Printf("📅 Получение статистики по дням для '%s' с %s по %s (%s)\n",
+		label, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), timezone)
+
+	match["searchFields.dateTime"] = bson.M{
+		"$gte": startDate,
+		"$lt":  endDate.AddDate(0, 0, 1),
+	}
 
 	// Создаем pipeline для агрегации
 	pipeline := mongo.Pipeline{
-		// Фильтруем по региону и дате
-		{{Key: "$match", Value: bson.M{
-			"region": region,
-			"searchFields.dateTime": bson.M{
-				"$gte": startDate,
-				"$lte": endDate.Add(24*time.Hour - time.Second),
-			},
-		}}},
+		// Фильтруем по региону (району, округу) и дате
+		{{Key: "$match", Value: match}},
 		// Извлекаем дату (без времени)
 		{{Key: "$project", Value: bson.M{
 			"date": bson.M{
 				"$dateToString": bson.M{
-					"format": "%Y-%m-%d",
-					"date":   "$searchFields.dateTime",
+					"format":   "%Y-%m-%d",
+					"date":     "$searchFields.dateTime",
+					"timezone": timezone,
 				},
 			},
 		}}},
@@ -706,15 +1077,30 @@ func getYearlyStats(c *gin.Context, collection useTables) {
 func getPeakHour(c *gin.Context, collection useTables) {
 	flightDataCollection := collection.flightDataCollection
 
-	// Получаем параметры из query string
-	region := c.Query("region")
+	// Получаем параметры из query string (регион - название или код ISO 3166-2, либо зона zone)
+	region := regionDirectory.resolve(c.Query("region"))
+	zoneID := c.Query("zone")
 	date := c.Query("date")
 
-	if region == "" || date == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры region и date обязательны"})
+	if (region == "" && zoneID == "") || date == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры region (или zone) и date обязательны"})
 		return
 	}
 
+	match := bson.M{"region": region}
+	label := region
+	var zone *customZone
+	if zoneID != "" {
+		condition, found, err := zoneCondition(zoneID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		match = condition
+		zone = &found
+		label = "зона " + found.Name
+	}
+
 	// Парсим дату
 	targetDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
@@ -722,27 +1108,39 @@ func getPeakHour(c *gin.Context, collection useTables) {
 		return
 	}
 
+	// Часовой пояс, в котором считаются сутки и часы: tz=local|UTC|<IANA>
+	var timezone string
+	var location *time.Location
+	if zone != nil {
+		timezone, location, err = resolveZoneTimezone(collection, timezoneParam(c), *zone)
+	} else {
+		timezone, location, err = resolveTimezone(collection, timezoneParam(c), region)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Вычисляем временной диапазон для целевого дня
-	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.UTC)
-	endOfDay := startOfDay.Add(24*time.Hour - time.Second)
+	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, location)
+	endOfDay := startOfDay.AddDate(0, 0, 1)
 
 	ctx := context.Background()
 
-	fmt.Printf("⏰ Получение статистики по часам для региона '%s' за %s\n", region, date)
+	fmt.Printf("⏰ Получение статистики по часам для '%s' за %s (%s)\n", label, date, timezone)
+
+	match["searchFields.dateTime"] = bson.M{
+		"$gte": startOfDay,
+		"$lt":  endOfDay,
+	}
 
 	// Создаем pipeline для агрегации
 	pipeline := mongo.Pipeline{
-		// Фильтруем по региону и дате
-		{{Key: "$match", Value: bson.M{
-			"region": region,
-			"searchFields.dateTime": bson.M{
-				"$gte": startOfDay,
-				"$lte": endOfDay,
-			},
-		}}},
-		// Извлекаем час из datetime
+		// Фильтруем по региону (зоне) и дате
+		{{Key: "$match", Value: match}},
+		// Извлекаем час из datetime в выбранном поясе
 		{{Key: "$project", Value: bson.M{
-			"hour":             bson.M{"$hour": "$searchFields.dateTime"},
+			"hour":             bson.M{"$hour": bson.M{"date": "$searchFields.dateTime", "timezone": timezone}},
 			"aircraftQuantity": "$shr.aircraftQuantity",
 		}}},
 		// Группируем по часам
@@ -814,7 +1212,29 @@ func getTop10Regions(c *gin.Context, collection useTables) {
 		return
 	}
 
-	fmt.Printf("🏆 Получение топ-10 регионов с %s по %s\n", from, to)
+	// Метрика ранжирования: число полетов или нормированное на площадь/население
+	metric := c.DefaultQuery("metric", "flights")
+	metricField, ok := map[string]string{
+		"flights":    "flightCount",
+		"per1000km2": "flightsPer1000Km2",
+		"per100k":    "flightsPer100k",
+	}[metric]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр metric. Используйте flights, per1000km2 или per100k"})
+		return
+	}
+
+	level, err := parseAggregationLevel(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !level.normalized() && metric != "flights" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Для районов и населенных пунктов доступна только метрика flights"})
+		return
+	}
+
+	fmt.Printf("🏆 Получение топ-10 с %s по %s (уровень %s, метрика %s)\n", from, to, level.name, metric)
 
 	// Создаем pipeline для агрегации
 	pipeline := mongo.Pipeline{
@@ -825,9 +1245,9 @@ func getTop10Regions(c *gin.Context, collection useTables) {
 				"$lte": end,
 			},
 		}}},
-		// Группируем по регионам, считаем полеты и сумму дронов
+		// Группируем по регионам (районам, округам), считаем полеты и сумму дронов
 		{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "$region"},
+			{Key: "_id", Value: level.groupKey},
 			{Key: "flightCount", Value: bson.D{{Key: "$sum", Value: 1}}},
 			{Key: "droneCount", Value: bson.D{
 				{Key: "$sum", Value: bson.D{
@@ -844,16 +1264,16 @@ func getTop10Regions(c *gin.Context, collection useTables) {
 		}},
 		// Сортируем по flightCount по убыванию
 		{{Key: "$sort", Value: bson.M{"flightCount": -1}}},
-		// Ограничиваем 10 результатами
-		{{Key: "$limit", Value: 10}},
-		// Проектируем в нужный формат
-		{{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "region", Value: "$_id"},
-			{Key: "flightCount", Value: 1},
-			{Key: "droneCount", Value: 1},
-		}}},
 	}
+	// Ограничиваем 10 результатами. Для нормированных метрик топ выбирается после расчета показателей
+	if metric == "flights" {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: 10}})
+	}
+	// Проектируем в нужный формат
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: level.project(
+		bson.E{Key: "flightCount", Value: 1},
+		bson.E{Key: "droneCount", Value: 1},
+	)}})
 
 	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -870,6 +1290,26 @@ func getTop10Regions(c *gin.Context, collection useTables) {
 		return
 	}
 
+	if level.normalized() {
+		addNormalizedMetrics(results)
+	}
+	if metric != "flights" {
+		// Регионы без площади или населения в рейтинг не попадают
+		ranked := results[:0]
+		for _, result := range results {
+			if _, ok := result[metricField]; ok {
+				ranked = append(ranked, result)
+			}
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return toFloat(ranked[i][metricField]) > toFloat(ranked[j][metricField])
+		})
+		if len(ranked) > 10 {
+			ranked = ranked[:10]
+		}
+		results = ranked
+	}
+
 	fmt.Printf("📈 Найдено регионов в топ-10: %d\n", len(results))
 
 	/* 	// Выводим результаты для отладки
@@ -908,7 +1348,13 @@ func getAvgFlightDuration(c *gin.Context, collection useTables) {
 		return
 	}
 
-	fmt.Printf("📊 Получение средней длительности полетов с %s по %s\n", from, to)
+	level, err := parseAggregationLevel(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("📊 Получение средней длительности полетов с %s по %s (уровень %s)\n", from, to, level.name)
 
 	// Создаем pipeline для агрегации
 	pipeline := mongo.Pipeline{
@@ -941,19 +1387,17 @@ func getAvgFlightDuration(c *gin.Context, collection useTables) {
 				},
 			},
 		}}},
-		// Группируем по регионам и вычисляем среднее
+		// Группируем по регионам (районам, округам) и вычисляем среднее
 		{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "$region"},
+			{Key: "_id", Value: level.groupKey},
 			{Key: "avgDurationMinutes", Value: bson.M{"$avg": "$weightedDuration"}},
 		}}},
 		// Проектируем в нужный формат
-		{{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "region", Value: "$_id"},
-			{Key: "avgDurationMinutes", Value: bson.M{"$round": bson.A{"$avgDurationMinutes", 1}}}, // округляем до 1 знака
-		}}},
-		// Сортируем по региону
-		{{Key: "$sort", Value: bson.M{"region": 1}}},
+		{{Key: "$project", Value: level.project(
+			bson.E{Key: "avgDurationMinutes", Value: bson.M{"$round": bson.A{"$avgDurationMinutes", 1}}}, // округляем до 1 знака
+		)}},
+		// Сортируем по названию группы
+		{{Key: "$sort", Value: bson.D{{Key: level.sortField, Value: 1}, {Key: "district", Value: 1}, {Key: "settlement", Value: 1}}}},
 	}
 
 	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
@@ -1010,7 +1454,15 @@ func getFlightCount(c *gin.Context, collection useTables) {
 		return
 	}
 
-	fmt.Printf("📊 Получение статистики с %s по %s\n", from, to)
+	// Уровень группировки: region (субъект), district (муниципальный район), federal (федеральный округ)
+	// или settlement (ближайший населенный пункт)
+	level, err := parseAggregationLevel(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("📊 Получение статистики с %s по %s (уровень %s)\n", from, to, level.name)
 
 	filter := bson.M{
 		"searchFields.dateTime": bson.M{
@@ -1019,13 +1471,18 @@ func getFlightCount(c *gin.Context, collection useTables) {
 		},
 	}
 
+	// Детализация районов внутри одного субъекта
+	if region := c.Query("region"); region != "" {
+		filter["region"] = regionDirectory.resolve(region)
+	}
+
 	// Создаем pipeline для агрегации
 	pipeline := mongo.Pipeline{
 		// Фильтруем по дате и региону
 		{{Key: "$match", Value: filter}},
-		// Группируем по регионам
+		// Группируем по регионам, районам или округам
 		{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "$region"},
+			{Key: "_id", Value: level.groupKey},
 			{Key: "flightCount", Value: bson.D{{Key: "$sum", Value: 1}}},
 			{Key: "droneCount", Value: bson.D{
 				{Key: "$sum", Value: bson.D{
@@ -1038,12 +1495,93 @@ func getFlightCount(c *gin.Context, collection useTables) {
 			}}},
 		}},
 		// Проектируем в нужный формат
+		{{Key: "$project", Value: level.project(
+			bson.E{Key: "flightCount", Value: 1},
+			bson.E{Key: "droneCount", Value: 1},
+		)}},
+	}
+
+	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Printf("❌ Ошибка агрегации: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		fmt.Printf("❌ Ошибка декодирования: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	if level.normalized() {
+		addNormalizedMetrics(results)
+	}
+
+	fmt.Printf("📈 Найдено групп: %d\n", len(results))
+	c.JSON(http.StatusOK, results)
+}
+
+// getRegionFlow матрица перелетов регион вылета → регион посадки за период
+func getRegionFlow(c *gin.Context, collection useTables) {
+	flightDataCollection := collection.flightDataCollection
+
+	// Получаем параметры из query string
+	from := c.Query("from")
+	to := c.Query("to")
+
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры from и to обязательны"})
+		return
+	}
+
+	// Парсим даты
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат from"})
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат to"})
+		return
+	}
+
+	ctx := context.Background()
+
+	fmt.Printf("🔀 Получение перелетов между регионами с %s по %s\n", from, to)
+
+	filter := bson.M{
+		"searchFields.dateTime": bson.M{
+			"$gte": start,
+			"$lte": end,
+		},
+		"arrRegion": bson.M{"$exists": true},
+	}
+
+	// Только межрегиональные полеты (без диагонали матрицы)
+	if c.Query("crossOnly") == "true" {
+		filter["crossRegion"] = true
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.M{"origin": "$region", "destination": "$arrRegion"}},
+			{Key: "flightCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "droneCount", Value: bson.D{{Key: "$sum", Value: "$shr.aircraftQuantity"}}},
+		}}},
 		{{Key: "$project", Value: bson.D{
 			{Key: "_id", Value: 0},
-			{Key: "region", Value: "$_id"},
+			{Key: "origin", Value: "$_id.origin"},
+			{Key: "destination", Value: "$_id.destination"},
 			{Key: "flightCount", Value: 1},
 			{Key: "droneCount", Value: 1},
 		}}},
+		{{Key: "$sort", Value: bson.M{"flightCount": -1}}},
 	}
 
 	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
@@ -1054,15 +1592,45 @@ func getFlightCount(c *gin.Context, collection useTables) {
 	}
 	defer cursor.Close(ctx)
 
-	var results []bson.M
-	if err := cursor.All(ctx, &results); err != nil {
+	var flows []struct {
+		Origin      string `bson:"origin" json:"origin"`
+		Destination string `bson:"destination" json:"destination"`
+		FlightCount int64  `bson:"flightCount" json:"flightCount"`
+		DroneCount  int64  `bson:"droneCount" json:"droneCount"`
+	}
+	if err := cursor.All(ctx, &flows); err != nil {
 		fmt.Printf("❌ Ошибка декодирования: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
 		return
 	}
 
-	fmt.Printf("📈 Найдено регионов: %d\n", len(results))
-	c.JSON(http.StatusOK, results)
+	// Квадратная матрица для chord-диаграммы: строки - вылет, столбцы - посадка
+	var regions []string
+	regionIndex := make(map[string]int)
+	for _, flow := range flows {
+		for _, name := range []string{flow.Origin, flow.Destination} {
+			if _, ok := regionIndex[name]; !ok {
+				regionIndex[name] = len(regions)
+				regions = append(regions, name)
+			}
+		}
+	}
+
+	matrix := make([][]int64, len(regions))
+	for i := range matrix {
+		matrix[i] = make([]int64, len(regions))
+	}
+	for _, flow := range flows {
+		matrix[regionIndex[flow.Origin]][regionIndex[flow.Destination]] += flow.FlightCount
+	}
+
+	fmt.Printf("📈 Найдено направлений: %d\n", len(flows))
+
+	c.JSON(http.StatusOK, gin.H{
+		"flows":   flows,
+		"regions": regions,
+		"matrix":  matrix,
+	})
 }
 
 // Запрос для тепловой карты полетов
@@ -1082,9 +1650,16 @@ func getHeatmapData(c *gin.Context, collection useTables) {
 		return
 	}
 
+	filter := bson.M{"region": regionDirectory.resolve(region)}
+
+	// Детализация до муниципального района
+	if district := c.Query("district"); district != "" {
+		filter["district"] = district
+	}
+
 	ctx := context.Background()
 
-	cursor, err := flightDataCollection.Find(ctx, bson.M{"region": region})
+	cursor, err := flightDataCollection.Find(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
 		return
@@ -1153,6 +1728,110 @@ func getHeatmapData(c *gin.Context, collection useTables) {
 	c.JSON(http.StatusOK, coordinates)
 }
 
+// heatmapCell агрегированная ячейка тепловой карты
+type heatmapCell struct {
+	ID      string  `json:"id"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Flights int64   `json:"flights"`
+	Drones  int64   `json:"drones"`
+}
+
+// getHeatmapBins агрегирует точки вылета в ячейки шестиугольной сетки или geohash.
+// Размер ячейки выбирается по zoom; фильтры те же, что у /flights-table.
+// Ключ ячейки вычисляется по depPoint в $group, из базы приходят только ячейки.
+// Без region работает по всей России
+func getHeatmapBins(c *gin.Context, collection useTables) {
+	zoom, err := strconv.Atoi(c.DefaultQuery("zoom", "4"))
+	if err != nil || zoom < 0 || zoom > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр zoom (0..20)"})
+		return
+	}
+
+	gridType := c.DefaultQuery("grid", "hex")
+	var grid geoGrid.Grid
+	var resolution interface{}
+	switch gridType {
+	case "hex":
+		size := geoGrid.HexSizeForZoom(zoom)
+		grid = geoGrid.NewHexGrid(size)
+		resolution = math.Round(size)
+	case "geohash":
+		precision := geoGrid.GeohashPrecisionForZoom(zoom)
+		grid = geoGrid.NewGeohashGrid(precision)
+		resolution = precision
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр grid. Используйте hex или geohash"})
+		return
+	}
+
+	// Фильтры таблицы полетов, включая пространственные (bbox, polygon, near/radiusKm)
+	filter, err := buildFlightFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// depPoint - точка вылета из dep, иначе из SHR; нулевые координаты не учитываются
+	appendAnd(filter, bson.M{"depPoint.coordinates": bson.M{"$exists": true, "$ne": bson.A{0.0, 0.0}}})
+
+	ctx := context.Background()
+
+	fmt.Printf("🔥 Тепловая карта: сетка %s, zoom %d\n", gridType, zoom)
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id":     grid.KeyExpression("$depPoint.coordinates"),
+			"flights": bson.M{"$sum": 1},
+			"drones": bson.M{"$sum": bson.M{"$max": bson.A{1, bson.M{
+				"$ifNull": bson.A{"$shr.aircraftQuantity", 1},
+			}}}},
+		}},
+		{"$sort": bson.M{"flights": -1}},
+	}
+
+	cursor, err := collection.flightDataCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		fmt.Printf("❌ Ошибка выполнения запроса: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	result := []heatmapCell{}
+	var totalFlights, maxFlights int64
+	for cursor.Next(ctx) {
+		var bin struct {
+			Key     []float64 `bson:"_id"`
+			Flights int64     `bson:"flights"`
+			Drones  int64     `bson:"drones"`
+		}
+		if err := cursor.Decode(&bin); err != nil || len(bin.Key) != 2 {
+			continue
+		}
+
+		id, lat, lon := grid.CellForKey(int64(bin.Key[0]), int64(bin.Key[1]))
+		result = append(result, heatmapCell{ID: id, Lat: lat, Lon: lon, Flights: bin.Flights, Drones: bin.Drones})
+		totalFlights += bin.Flights
+		if bin.Flights > maxFlights {
+			maxFlights = bin.Flights
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"grid":         gridType,
+		"zoom":         zoom,
+		"resolution":   resolution,
+		"totalFlights": totalFlights,
+		"maxFlights":   maxFlights,
+		"cells":        result,
+	})
+}
+
 // Получаем все документы из коллекции regionList
 func getRegionList(c *gin.Context, collection useTables) {
 
@@ -1160,6 +1839,12 @@ func getRegionList(c *gin.Context, collection useTables) {
 
 	ctx := context.Background()
 
+	// Детализация: список муниципальных районов субъекта
+	if region := c.Query("region"); region != "" {
+		getDistrictList(c, collection, regionDirectory.resolve(region))
+		return
+	}
+
 	// Создаем структуру для ответа
 	type RegionResponse struct {
 		Region string `bson:"region" json:"region"`
@@ -1176,7 +1861,8 @@ func getRegionList(c *gin.Context, collection useTables) {
 	// Если нужно вернуть только поле region без regionID
 	if len(regions) == 0 {
 		// Альтернативный способ - проекция в запросе
-		cursor, err := regionListCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"region": 1, "_id": 0}))
+		cursor, err := regionListCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"region": 1, "iso": 1, "shortName": 1, "federalDistrict": 1,
+			"areaKm2": 1, "population": 1, "timezone": 1, "_id": 0}))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения данных из базы"})
 			return
@@ -1196,6 +1882,33 @@ func getRegionList(c *gin.Context, collection useTables) {
 	c.JSON(http.StatusOK, regions)
 }
 
+// getDistrictList возвращает муниципальные районы субъекта
+func getDistrictList(c *gin.Context, collection useTables, region string) {
+	ctx := context.Background()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"parent": region}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "district", Value: "$_id"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "district", Value: 1}}}},
+	}
+
+	cursor, err := collection.districtListCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения данных из базы"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	districts := []bson.M{}
+	if err := cursor.All(ctx, &districts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, districts)
+}
+
 // Проверка доступности сервера
 func healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "pong"})
@@ -1279,6 +1992,9 @@ func validateHeaders(headers []string) bool {
 	return true
 }
 
+// uploadChunkSize число строк Excel, передаваемых в пакетное определение регионов
+const uploadChunkSize = 2000
+
 // Парсинг и загрузка файла в базу
 func uploadFiles(c *gin.Context, collection useTables, client *mongo.Client) {
 
@@ -1345,7 +2061,7 @@ func uploadFiles(c *gin.Context, collection useTables, client *mongo.Client) {
 	}
 
 	// СОЗДАЕМ ГЕОСЕРВИС ДЛЯ ИНТЕГРАЦИИ
-	geoService := geoSearch.NewGeoService(client) // client - ваш MongoDB client
+	geoService := newRegionResolver(client)
 	geoParser := parsing.NewGeoIntegratedParser(geoService)
 
 	// Получаем существующие SID из базы для проверки уникальности
@@ -1358,30 +2074,9 @@ func uploadFiles(c *gin.Context, collection useTables, client *mongo.Client) {
 
 	fmt.Printf("🔍 Найдено %d существующих SID в базе\n", len(existingSIDs))
 
-	// Каналы для параллельной обработки
-	jobs := make(chan []string, 50000)
+	// Канал разобранных полетов
 	results := make(chan parsing.FlightData, 50000)
 
-	// Запускаем worker'ов для параллельного парсинга
-	numWorkers := runtime.NumCPU()
-	var wg sync.WaitGroup
-	for w := 0; w < numWorkers; w++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for row := range jobs {
-				flightData := geoParser.CreateFlightDataWithRegion(row)
-				results <- flightData
-			}
-		}(w)
-	}
-
-	// Собираем результаты
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
 	// Читаем и обрабатываем строки ПОТОКОВО (начинаем со ВТОРОЙ строки, так как первую уже прочитали)
 	var documents []any
 	var insertedCount int
@@ -1389,8 +2084,19 @@ func uploadFiles(c *gin.Context, collection useTables, client *mongo.Client) {
 	var totalProcessed int
 	batchSize := 1000
 
-	// Запускаем горутину для отправки заданий
+	// Строки читаются пачками: регионы пачки определяются пакетными запросами
+	// ProcessBatchWithRegion, а не поиском по каждой точке
 	go func() {
+		defer close(results)
+
+		rows := make([][]string, 0, uploadChunkSize)
+		flush := func() {
+			for _, flightData := range geoParser.ProcessBatchWithRegion(rows) {
+				results <- flightData
+			}
+			rows = rows[:0]
+		}
+
 		// Читаем оставшиеся строки (начиная со второй)
 		for rowsStream.Next() {
 			row, err := rowsStream.Columns(excelize.Options{
@@ -1401,9 +2107,12 @@ func uploadFiles(c *gin.Context, collection useTables, client *mongo.Client) {
 				continue
 			}
 
-			jobs <- row
+			rows = append(rows, row)
+			if len(rows) >= uploadChunkSize {
+				flush()
+			}
 		}
-		close(jobs)
+		flush()
 
 		if err := rowsStream.Error(); err != nil {
 			fmt.Printf("❌ Ошибка потока строк: %v\n", err)
@@ -1484,8 +2193,9 @@ func updateRegionList(collection useTables) {
 
 	// Получаем уникальные регионы из regionsGeo
 	pipeline := mongo.Pipeline{
-		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}}}},
-		{{Key: "$project", Value: bson.D{{Key: "name", Value: "$_id"}, {Key: "_id", Value: 0}}}},
+		{{Key: "$group", Value: regionListGroup()}},
+		{{Key: "$addFields", Value: bson.D{{Key: "name", Value: "$_id"}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}}}},
 		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
 	}
 
@@ -1509,6 +2219,11 @@ func updateRegionList(collection useTables) {
 				"regionID": i + 1,
 				"region":   region["name"],
 			}
+			for _, field := range regionMetaFields {
+				if value, ok := region[field]; ok && value != nil {
+					document[field] = value
+				}
+			}
 			documents = append(documents, document)
 		}
 
@@ -1527,6 +2242,16 @@ func updateRegionList(collection useTables) {
 		fmt.Println("ℹ️  Не найдено регионов для добавления")
 	}
 
+	loadRegionDirectory(collection)
+}
+
+// regionListGroup стадия $group для regionList: уникальные названия с атрибутами регионов
+func regionListGroup() bson.D {
+	group := bson.D{{Key: "_id", Value: "$name"}}
+	for _, field := range regionMetaFields {
+		group = append(group, bson.E{Key: field, Value: bson.M{"$first": "$" + field}})
+	}
+	return group
 }
 
 // Обновляем список уникальных типов воздушных судов
@@ -1618,110 +2343,15 @@ func exportFlightTable(c *gin.Context, collection useTables) {
 		return
 	}
 
-	// Получаем параметры фильтров (те же что и в getFlightTable)
-	aircraftType := c.Query("aircraftType")
-	dateDepFrom := c.Query("dateDepFrom")
-	dateDepTo := c.Query("dateDepTo")
-	flightDurationMin := c.Query("flightDurationMin")
-	flightDurationMax := c.Query("flightDurationMax")
-	sid := c.Query("sid")
-	region := c.Query("region")
-	operatorType := c.Query("operatorType")
-
 	ctx := context.Background()
 
 	fmt.Printf("📤 Экспорт таблицы полетов в формате %s\n", format)
 
-	// Создаем базовый фильтр (используем ту же логику что и в getFlightTable)
-	filter := bson.M{}
-
-	// Добавляем фильтры если они переданы
-	if aircraftType != "" {
-		aircraftTypes := strings.Split(aircraftType, ",")
-		for i, at := range aircraftTypes {
-			aircraftTypes[i] = strings.TrimSpace(at)
-		}
-		if len(aircraftTypes) == 1 {
-			filter["shr.aircraftType"] = aircraftTypes[0]
-		} else {
-			filter["shr.aircraftType"] = bson.M{"$in": aircraftTypes}
-		}
-	}
-
-	// ДОБАВЛЯЕМ ФИЛЬТР ПО ТИПУ ОПЕРАТОРА
-	if operatorType != "" {
-		operatorTypes := strings.Split(operatorType, ",")
-		for i, ot := range operatorTypes {
-			operatorTypes[i] = strings.TrimSpace(ot)
-		}
-		if len(operatorTypes) == 1 {
-			filter["shr.operatorType"] = operatorTypes[0]
-		} else {
-			filter["shr.operatorType"] = bson.M{"$in": operatorTypes}
-		}
-		fmt.Printf("🏢 Фильтр по типам операторов: %v\n", operatorTypes)
-	}
-
-	start, _ := time.Parse(time.RFC3339, dateDepFrom)
-	end, _ := time.Parse(time.RFC3339, dateDepTo)
-
-	if dateDepFrom != "" {
-		if dateDepTo != "" {
-			filter["searchFields.dateTime"] = bson.M{
-				"$gte": start,
-				"$lte": end,
-			}
-		} else {
-			filter["searchFields.dateTime"] = bson.M{"$gte": start}
-		}
-	} else if dateDepTo != "" {
-		filter["searchFields.dateTime"] = bson.M{"$lte": end}
-	}
-
-	if sid != "" {
-		if sidInt, err := strconv.Atoi(sid); err == nil {
-			filter["shr.sid"] = sidInt
-		}
-	}
-
-	if flightDurationMin != "" {
-		durationMin, err := strconv.Atoi(flightDurationMin)
-		if err == nil {
-			if flightDurationMax != "" {
-				durationMax, err := strconv.Atoi(flightDurationMax)
-				if err == nil {
-					if durationMin == 0 {
-						filter["$or"] = []bson.M{
-							{"shr.flightDuration": bson.M{"$gte": durationMin, "$lte": durationMax}},
-							{"shr.flightDuration": nil},
-						}
-					} else {
-						filter["shr.flightDuration"] = bson.M{
-							"$gte": durationMin,
-							"$lte": durationMax,
-						}
-					}
-				}
-			} else {
-				if durationMin == 0 {
-					filter["$or"] = []bson.M{
-						{"shr.flightDuration": bson.M{"$gte": durationMin}},
-						{"shr.flightDuration": nil},
-					}
-				} else {
-					filter["shr.flightDuration"] = bson.M{"$gte": durationMin}
-				}
-			}
-		}
-	} else if flightDurationMax != "" {
-		durationMax, err := strconv.Atoi(flightDurationMax)
-		if err == nil {
-			filter["shr.flightDuration"] = bson.M{"$lte": durationMax}
-		}
-	}
-
-	if region != "" {
-		filter["region"] = region
+	// Создаем базовый фильтр (та же логика, что и в getFlightTable)
+	filter, err := buildFlightFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Создаем pipeline для агрегации (без пагинации)
@@ -1734,16 +2364,25 @@ func exportFlightTable(c *gin.Context, collection useTables) {
 
 	// Проекция нужных полей
 	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.M{
-		"region":           1,
-		"sid":              "$shr.sid",
-		"aircraftIndex":    "$shr.aircraftIndex",
-		"aircraftType":     "$shr.aircraftType",
-		"aircraftQuantity": "$shr.aircraftQuantity",
-		"operator":         "$shr.operator",
-		"operatorType":     "$shr.operatorType",
-		"dateDep":          "$searchFields.dateTime",
-		"dateArr":          "$searchFields.arrDatetime",
-		"flightDuration":   "$shr.flightDuration",
+		"region":            1,
+		"district":          1,
+		"regionMatch":       1,
+		"sid":               "$shr.sid",
+		"aircraftIndex":     "$shr.aircraftIndex",
+		"aircraftType":      "$shr.aircraftType",
+		"aircraftQuantity":  "$shr.aircraftQuantity",
+		"operator":          "$shr.operator",
+		"operatorType":      "$shr.operatorType",
+		"dateDep":           "$searchFields.dateTime",
+		"dateArr":           "$searchFields.arrDatetime",
+		"flightDuration":    "$shr.flightDuration",
+		"distanceKm":        1,
+		"avgSpeedKmh":       1,
+		"qualityFlags":      1,
+		"airspace":          "$airspace.designator",
+		"airportDep":        "$dep.airport",
+		"airportArr":        "$arr.airport",
+		"nearestSettlement": 1,
 		"coordinatesDep": bson.M{
 			"$let": bson.M{
 				"vars": bson.M{
@@ -1824,8 +2463,13 @@ func exportFlightTable(c *gin.Context, collection useTables) {
 		},
 	}}})
 
-	// Сортировка по sid
-	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.M{"sid": 1}}})
+	// Сортировка: sortBy и sortOrder, по умолчанию по sid
+	sortStage, err := buildFlightSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sortStage}})
 
 	cursor, err := flightDataCollection.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -1888,8 +2532,11 @@ func exportXLSX(c *gin.Context, data []bson.M, filename string) {
 
 	// Создаем заголовки - ДОБАВЛЯЕМ ОПЕРАТОРА И ТИП ОПЕРАТОРА
 	headers := []string{
-		"Регион", "Системный ID", "Индекс ВС", "Тип ВС", "Количество ВС",
+		"Регион", "Район", "Привязка региона", "Системный ID", "Индекс ВС", "Тип ВС", "Количество ВС",
 		"Время вылета", "Время прибытия", "Длительность полета (мин)",
+		"Расстояние (км)", "Средняя скорость (км/ч)", "Зоны ограничения",
+		"Аэродром вылета", "Аэродром прибытия",
+		"Ближайший населенный пункт", "До населенного пункта (км)",
 		"Координаты вылета", "Координаты прибытия", "Оператор", "Тип оператора",
 	}
 
@@ -1911,6 +2558,16 @@ func exportXLSX(c *gin.Context, data []bson.M, filename string) {
 			row.AddCell().Value = ""
 		}
 
+		// Муниципальный район
+		if district, ok := record["district"].(string); ok {
+			row.AddCell().Value = district
+		} else {
+			row.AddCell().Value = ""
+		}
+
+		// Способ привязки к региону
+		row.AddCell().Value = formatRegionMatch(record["regionMatch"])
+
 		// SID
 		if sid, ok := record["sid"].(int64); ok {
 			row.AddCell().SetString(fmt.Sprint(sid))
@@ -1960,6 +2617,35 @@ func exportXLSX(c *gin.Context, data []bson.M, filename string) {
 			row.AddCell().Value = ""
 		}
 
+		// Расстояние вылет-посадка
+		if distanceKm, ok := record["distanceKm"].(float64); ok {
+			row.AddCell().SetFloat(distanceKm)
+		} else {
+			row.AddCell().Value = ""
+		}
+
+		// Средняя скорость
+		if avgSpeedKmh, ok := record["avgSpeedKmh"].(float64); ok {
+			row.AddCell().SetFloat(avgSpeedKmh)
+		} else {
+			row.AddCell().Value = ""
+		}
+
+		// Зоны ограничения
+		row.AddCell().Value = formatAirspace(record["airspace"])
+
+		// Аэродромы вылета и прибытия
+		row.AddCell().Value = formatAerodrome(record["airportDep"])
+		row.AddCell().Value = formatAerodrome(record["airportArr"])
+
+		// Ближайший населенный пункт и расстояние до него
+		row.AddCell().Value = formatSettlement(record["nearestSettlement"])
+		if settlement, ok := record["nearestSettlement"].(bson.M); ok {
+			row.AddCell().SetFloat(toFloat(settlement["distanceKm"]))
+		} else {
+			row.AddCell().Value = ""
+		}
+
 		// Координаты вылета
 		if coordsDep, ok := record["coordinatesDep"].(string); ok {
 			row.AddCell().Value = coordsDep
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"project/packages/parsing/launchSites"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rebuildLaunchSites пересчитывает реестр мест запуска.
+// Необязательные параметры: epsilon (метры) и minPoints
+func rebuildLaunchSites(c *gin.Context, collection useTables) {
+	cfg := launchSites.DefaultConfig()
+
+	if epsilon := c.Query("epsilon"); epsilon != "" {
+		value, err := strconv.ParseFloat(epsilon, 64)
+		if err != nil || value <= 0 || value > 10000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр epsilon (метры, 0..10000)"})
+			return
+		}
+		cfg.EpsilonMeters = value
+	}
+	if minPoints := c.Query("minPoints"); minPoints != "" {
+		value, err := strconv.Atoi(minPoints)
+		if err != nil || value < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр minPoints"})
+			return
+		}
+		cfg.MinPoints = value
+	}
+
+	summary, err := launchSites.Build(collection.flightDataCollection, collection.launchSitesCollection, cfg)
+	if err != nil {
+		fmt.Printf("❌ Ошибка построения реестра мест запуска: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Реестр мест запуска обновлен",
+		"epsilonMeters": cfg.EpsilonMeters,
+		"minPoints":     cfg.MinPoints,
+		"summary":       summary,
+	})
+}
+
+// getLaunchSites список мест запуска по убыванию числа полетов.
+// Фильтры: region, minFlights, bbox; limit (по умолчанию 100, максимум 1000)
+func getLaunchSites(c *gin.Context, collection useTables) {
+	filter := bson.M{}
+
+	if region := c.Query("region"); region != "" {
+		filter["region"] = regionDirectory.resolve(region)
+	}
+	if minFlights := c.Query("minFlights"); minFlights != "" {
+		value, err := strconv.Atoi(minFlights)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр minFlights"})
+			return
+		}
+		filter["flightCount"] = bson.M{"$gte": value}
+	}
+	if bboxParam := c.Query("bbox"); bboxParam != "" {
+		bbox, err := parseBBox(bboxParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter["lat"] = bson.M{"$gte": bbox.MinY, "$lte": bbox.MaxY}
+		filter["lon"] = bson.M{"$gte": bbox.MinX, "$lte": bbox.MaxX}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	ctx := context.Background()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "flightCount", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.launchSitesCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	sites := []launchSites.Site{}
+	if err := cursor.All(ctx, &sites); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	total, err := collection.launchSitesCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"sites": sites,
+	})
+}
+
+// getLaunchSiteFlights полеты, отнесенные к месту запуска, с пагинацией
+func getLaunchSiteFlights(c *gin.Context, collection useTables) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный идентификатор места запуска"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	ctx := context.Background()
+
+	var site launchSites.Site
+	if err := collection.launchSitesCollection.FindOne(ctx, bson.M{"_id": siteID}).Decode(&site); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Место запуска не найдено"})
+		return
+	}
+
+	filter := bson.M{"launchSiteId": siteID}
+
+	total, err := collection.flightDataCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "searchFields.dateTime", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{
+			"region":           1,
+			"sid":              "$shr.sid",
+			"aircraftIndex":    "$shr.aircraftIndex",
+			"aircraftType":     "$shr.aircraftType",
+			"aircraftQuantity": "$shr.aircraftQuantity",
+			"operator":         "$shr.operator",
+			"operatorType":     "$shr.operatorType",
+			"flightDuration":   "$shr.flightDuration",
+			"dateDep":          "$searchFields.dateTime",
+			"coordinatesDep":   bson.M{"$ifNull": bson.A{"$dep.coordinates", "$shr.coordinatesDep"}},
+		})
+
+	cursor, err := collection.flightDataCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	flights := []bson.M{}
+	if err := cursor.All(ctx, &flights); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"site":    site,
+		"flights": flights,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": totalPages,
+		},
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// regionConflictPairsLimit число пар регионов в сводке расхождений
+const regionConflictPairsLimit = 20
+
+// getRegionConflicts полеты, у которых координаты вылета из сообщения о вылете и из SHR
+// попадают в разные регионы. Необязательные параметры: from, to (RFC3339), region
+// (название или код ISO, совпадение по любому из двух регионов), page, limit.
+// В ответе также сводка по парам регионов для поиска систематических расхождений на границах
+func getRegionConflicts(c *gin.Context, collection useTables) {
+	match := bson.M{"regionMatch.conflict": true}
+
+	dateFilter := bson.M{}
+	if from := c.Query("from"); from != "" {
+		start, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат from"})
+			return
+		}
+		dateFilter["$gte"] = start
+	}
+	if to := c.Query("to"); to != "" {
+		end, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат to"})
+			return
+		}
+		dateFilter["$lte"] = end
+	}
+	if len(dateFilter) > 0 {
+		match["searchFields.dateTime"] = dateFilter
+	}
+
+	if region := c.Query("region"); region != "" {
+		region = regionDirectory.resolve(region)
+		match["$or"] = bson.A{
+			bson.M{"region": region},
+			bson.M{"regionMatch.altRegion": region},
+		}
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	ctx := context.Background()
+
+	total, err := collection.flightDataCollection.CountDocuments(ctx, match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "searchFields.dateTime", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{
+			"region":         1,
+			"regionMatch":    1,
+			"sid":            "$shr.sid",
+			"operator":       "$shr.operator",
+			"aircraftType":   "$shr.aircraftType",
+			"dateDep":        "$searchFields.dateTime",
+			"coordinatesDep": "$dep.coordinates",
+			"coordinatesShr": "$shr.coordinatesDep",
+		})
+
+	cursor, err := collection.flightDataCollection.Find(ctx, match, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения запроса к базе данных"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	flights := []bson.M{}
+	if err := cursor.All(ctx, &flights); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	// Сводка: какие пары регионов расходятся чаще всего
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":   bson.M{"region": "$region", "altRegion": "$regionMatch.altRegion"},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$sort": bson.D{{Key: "count", Value: -1}}},
+		{"$limit": regionConflictPairsLimit},
+		{"$project": bson.M{
+			"_id":       0,
+			"region":    "$_id.region",
+			"altRegion": "$_id.altRegion",
+			"count":     1,
+		}},
+	}
+	pairsCursor, err := collection.flightDataCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выполнения агрегации"})
+		return
+	}
+	defer pairsCursor.Close(ctx)
+
+	pairs := []bson.M{}
+	if err := pairsCursor.All(ctx, &pairs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования данных"})
+		return
+	}
+
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"flights": flights,
+		"pairs":   pairs,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": totalPages,
+		},
+	})
+}
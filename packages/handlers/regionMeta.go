@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"project/packages/parsing/geoIndex"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// regionMetaFields атрибуты региона, копируемые из regionsGeo в regionList
+var regionMetaFields = []string{"iso", "shortName", "federalDistrict", "areaKm2", "population", "timezone"}
+
+// regionInfo атрибуты субъекта для нормированных метрик и адресации по коду ISO
+type regionInfo struct {
+	Region          string  `bson:"region" json:"region"`
+	ISO             string  `bson:"iso,omitempty" json:"iso,omitempty"`
+	ShortName       string  `bson:"shortName,omitempty" json:"shortName,omitempty"`
+	FederalDistrict string  `bson:"federalDistrict,omitempty" json:"federalDistrict,omitempty"`
+	AreaKm2         float64 `bson:"areaKm2,omitempty" json:"areaKm2,omitempty"`
+	Population      int64   `bson:"population,omitempty" json:"population,omitempty"`
+	Timezone        string  `bson:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// regionDirectory справочник регионов в памяти, обновляется вместе с regionList
+var regionDirectory = &regionStore{byName: map[string]regionInfo{}, byISO: map[string]string{}}
+
+type regionStore struct {
+	sync.RWMutex
+	byName map[string]regionInfo
+	byISO  map[string]string
+}
+
+// set заменяет справочник
+func (s *regionStore) set(regions []regionInfo) {
+	byName := make(map[string]regionInfo, len(regions))
+	byISO := make(map[string]string, len(regions))
+	for _, region := range regions {
+		byName[region.Region] = region
+		if region.ISO != "" {
+			byISO[region.ISO] = region.Region
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.byName, s.byISO = byName, byISO
+}
+
+// info атрибуты региона по названию
+func (s *regionStore) info(name string) (regionInfo, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	region, ok := s.byName[name]
+	return region, ok
+}
+
+// byCode атрибуты региона по коду ISO 3166-2
+func (s *regionStore) byCode(iso string) (regionInfo, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	name, ok := s.byISO[strings.ToUpper(strings.TrimSpace(iso))]
+	if !ok {
+		return regionInfo{}, false
+	}
+	return s.byName[name], true
+}
+
+// resolve переводит код ISO 3166-2 (RU-MOW, без учета регистра) в название региона.
+// Остальные значения возвращаются как есть
+func (s *regionStore) resolve(value string) string {
+	s.RLock()
+	defer s.RUnlock()
+	if name, ok := s.byISO[strings.ToUpper(strings.TrimSpace(value))]; ok {
+		return name
+	}
+	return value
+}
+
+// federalDistricts федеральный округ каждого региона, для которого он известен
+func (s *regionStore) federalDistricts() map[string]string {
+	s.RLock()
+	defer s.RUnlock()
+	districts := make(map[string]string, len(s.byName))
+	for name, region := range s.byName {
+		if region.FederalDistrict != "" {
+			districts[name] = region.FederalDistrict
+		}
+	}
+	return districts
+}
+
+// federalTotals площадь и население федерального округа по входящим в него регионам.
+// Население суммируется, только если оно известно для всех регионов округа
+func (s *regionStore) federalTotals(district string) regionInfo {
+	s.RLock()
+	defer s.RUnlock()
+	total := regionInfo{FederalDistrict: district}
+	populationKnown := true
+	for _, region := range s.byName {
+		if region.FederalDistrict != district {
+			continue
+		}
+		total.AreaKm2 += region.AreaKm2
+		total.Population += region.Population
+		populationKnown = populationKnown && region.Population > 0
+	}
+	if !populationKnown {
+		total.Population = 0
+	}
+	return total
+}
+
+// loadRegionDirectory загружает справочник из regionList
+func loadRegionDirectory(collection useTables) {
+	ctx := context.Background()
+
+	cursor, err := collection.regionListCollection.Find(ctx, bson.M{})
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка загрузки справочника регионов: %v\n", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var regions []regionInfo
+	if err := cursor.All(ctx, &regions); err != nil {
+		fmt.Printf("⚠️ Ошибка декодирования справочника регионов: %v\n", err)
+		return
+	}
+	regionDirectory.set(regions)
+}
+
+// getRegionByISO атрибуты региона по коду ISO 3166-2: /regions/RU-MOW
+func getRegionByISO(c *gin.Context) {
+	iso := c.Param("iso")
+	region, ok := regionDirectory.byCode(iso)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Регион с кодом %s не найден", iso)})
+		return
+	}
+	c.JSON(http.StatusOK, region)
+}
+
+// importPopulation загружает таблицу населения (CSV: регион или код ISO, численность)
+// и обновляет список регионов
+func importPopulation(c *gin.Context, collection useTables) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ожидается CSV файл в поле file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+
+	updated, unmatched, err := geoIndex.ImportPopulation(collection.subjectListCollection, bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Население записывается и в активную версию набора границ, иначе оно пропадет при ее повторной активации
+	if active, err := geoIndex.ActiveRegionsCollection(collection.subjectListCollection.Database()); err != nil {
+		fmt.Printf("⚠️ Ошибка поиска активной версии границ: %v\n", err)
+	} else if active != nil {
+		if _, _, err := geoIndex.ImportPopulation(active, bytes.NewReader(data)); err != nil {
+			fmt.Printf("⚠️ Ошибка записи населения в активную версию границ: %v\n", err)
+		}
+	}
+
+	updateRegionList(collection)
+
+	fmt.Printf("👥 Население обновлено у %d регионов, не сопоставлено %d\n", updated, len(unmatched))
+	c.JSON(http.StatusOK, gin.H{
+		"updated":   updated,
+		"unmatched": unmatched,
+	})
+}
+
+// addNormalizedMetrics добавляет к строкам статистики по регионам или федеральным округам
+// число полетов на 1000 км² (flightsPer1000Km2) и на 100 тыс. жителей (flightsPer100k)
+func addNormalizedMetrics(results []bson.M) {
+	for _, result := range results {
+		var region regionInfo
+		if district, ok := result["federalDistrict"].(string); ok {
+			region = regionDirectory.federalTotals(district)
+			if code, ok := geoIndex.FederalDistrictCodes[district]; ok {
+				result["code"] = code
+			}
+		} else {
+			name, _ := result["region"].(string)
+			info, ok := regionDirectory.info(name)
+			if !ok {
+				continue
+			}
+			region = info
+		}
+
+		flights := toFloat(result["flightCount"])
+		if region.ISO != "" {
+			result["iso"] = region.ISO
+		}
+		if region.AreaKm2 > 0 {
+			result["flightsPer1000Km2"] = math.Round(flights/region.AreaKm2*1000*100) / 100
+		}
+		if region.Population > 0 {
+			result["flightsPer100k"] = math.Round(flights/float64(region.Population)*100000*100) / 100
+		}
+	}
+}
+
+// toFloat приводит числовое значение из результата агрегации к float64
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project/packages/parsing/geoGet"
+	"project/packages/parsing/vectorTile"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxTilePoints ограничение числа точек полетов в одном тайле
+const maxTilePoints = 50000
+
+// tileCacheSize и tileCacheTTL ограничения кэша тайлов
+const (
+	tileCacheSize = 5000
+	tileCacheTTL  = 10 * time.Minute
+)
+
+// tileCacheEntry закодированный тайл
+type tileCacheEntry struct {
+	key     string
+	data    []byte
+	created time.Time
+}
+
+// tileCache кэш тайлов по слою, координатам и хэшу фильтров
+var tileCache = newTileStore(tileCacheSize)
+
+// tileStore LRU кэш тайлов (как geoSearch.RegionCache): при переполнении вытесняются
+// давно не запрошенные тайлы, устаревшие по tileCacheTTL удаляются при обращении
+type tileStore struct {
+	sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // Начало списка - недавно использованные
+}
+
+func newTileStore(capacity int) *tileStore {
+	return &tileStore{capacity: capacity, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *tileStore) get(key string) ([]byte, bool) {
+	s.Lock()
+	defer s.Unlock()
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*tileCacheEntry)
+	if time.Since(entry.created) > tileCacheTTL {
+		s.order.Remove(element)
+		delete(s.items, key)
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return entry.data, true
+}
+
+func (s *tileStore) set(key string, data []byte) {
+	s.Lock()
+	defer s.Unlock()
+	if element, ok := s.items[key]; ok {
+		entry := element.Value.(*tileCacheEntry)
+		entry.data, entry.created = data, time.Now()
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.items[key] = s.order.PushFront(&tileCacheEntry{key: key, data: data, created: time.Now()})
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*tileCacheEntry).key)
+	}
+}
+
+// Reset очищает кэш (после загрузки данных или перезагрузки регионов)
+func (s *tileStore) Reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.items = make(map[string]*list.Element)
+	s.order.Init()
+}
+
+// getVectorTile отдает тайл Mapbox Vector Tile: /tiles/{layer}/{z}/{x}/{y}.mvt.
+// Слои: regions - границы субъектов, flights - точки вылета с фильтрами таблицы полетов
+func getVectorTile(c *gin.Context, collection useTables) {
+	layer := c.Param("layer")
+	if layer != "regions" && layer != "flights" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный слой. Используйте regions или flights"})
+		return
+	}
+
+	yParam := c.Param("y")
+	if !strings.HasSuffix(yParam, ".mvt") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ожидается расширение .mvt"})
+		return
+	}
+
+	z, errZ := strconv.Atoi(c.Param("z"))
+	x, errX := strconv.Atoi(c.Param("x"))
+	y, errY := strconv.Atoi(strings.TrimSuffix(yParam, ".mvt"))
+	if errZ != nil || errX != nil || errY != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные координаты тайла"})
+		return
+	}
+
+	tile, err := vectorTile.NewTile(z, x, y)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Ключ кэша: слой, тайл и хэш параметров фильтра (Encode сортирует ключи)
+	filterHash := sha1.Sum([]byte(c.Request.URL.Query().Encode()))
+	cacheKey := fmt.Sprintf("%s/%d/%d/%d/%s", layer, z, x, y, hex.EncodeToString(filterHash[:]))
+
+	if data, ok := tileCache.get(cacheKey); ok {
+		c.Header("X-Cache", "HIT")
+		c.Data(http.StatusOK, "application/vnd.mapbox-vector-tile", data)
+		return
+	}
+
+	var data []byte
+	switch layer {
+	case "regions":
+		data, err = buildRegionsTile(collection, tile)
+	case "flights":
+		data, err = buildFlightsTile(c, collection, tile)
+	}
+	if err != nil {
+		fmt.Printf("❌ Ошибка построения тайла %s: %v\n", cacheKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка построения тайла"})
+		return
+	}
+
+	tileCache.set(cacheKey, data)
+
+	c.Header("X-Cache", "MISS")
+	c.Data(http.StatusOK, "application/vnd.mapbox-vector-tile", data)
+}
+
+// buildRegionsTile границы субъектов, упрощенные для уровня масштаба тайла
+func buildRegionsTile(collection useTables, tile vectorTile.Tile) ([]byte, error) {
+	bbox := tile.BBox()
+	shapes, err := geoGet.SimplifiedRegions(collection.subjectListCollection,
+		geoGet.ToleranceForZoom(tile.Z), geoGet.RegionFilter{BBox: &bbox})
+	if err != nil {
+		return nil, err
+	}
+
+	layer := vectorTile.NewLayer("regions")
+	for i, shape := range shapes {
+		layer.AddPolygons(tile, shape.Polygons, uint64(i+1), map[string]interface{}{"name": shape.Name})
+	}
+
+	return vectorTile.Encode(layer), nil
+}
+
+// buildFlightsTile точки вылета, попадающие в тайл, с фильтрами /flights-table
+func buildFlightsTile(c *gin.Context, collection useTables, tile vectorTile.Tile) ([]byte, error) {
+	filter, err := buildFlightFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	appendAnd(filter, depPointWithin(bson.M{"$geometry": bboxGeometry(tile.BBox())}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetProjection(bson.M{
+			"depPoint":              1,
+			"shr.sid":               1,
+			"shr.aircraftType":      1,
+			"shr.operatorType":      1,
+			"region":                1,
+			"searchFields.dateTime": 1,
+		}).
+		SetLimit(maxTilePoints)
+
+	cursor, err := collection.flightDataCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	layer := vectorTile.NewLayer("flights")
+	for cursor.Next(ctx) {
+		var doc struct {
+			Region   string `bson:"region"`
+			DepPoint *struct {
+				Coordinates []float64 `bson:"coordinates"`
+			} `bson:"depPoint"`
+			SHR struct {
+				SID          int64  `bson:"sid"`
+				AircraftType string `bson:"aircraftType"`
+				OperatorType string `bson:"operatorType"`
+			} `bson:"shr"`
+			SearchFields struct {
+				DateTime *time.Time `bson:"dateTime"`
+			} `bson:"searchFields"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		// depPoint - точка вылета из dep, иначе из SHR, в порядке GeoJSON [lon, lat]
+		if doc.DepPoint == nil || len(doc.DepPoint.Coordinates) != 2 {
+			continue
+		}
+		lon, lat := doc.DepPoint.Coordinates[0], doc.DepPoint.Coordinates[1]
+
+		properties := map[string]interface{}{
+			"region":       doc.Region,
+			"aircraftType": doc.SHR.AircraftType,
+			"operatorType": doc.SHR.OperatorType,
+		}
+		if doc.SHR.SID != 0 {
+			properties["sid"] = doc.SHR.SID
+		}
+		if doc.SearchFields.DateTime != nil {
+			properties["dateTime"] = doc.SearchFields.DateTime.Format(time.RFC3339)
+		}
+
+		layer.AddPoint(tile, lat, lon, uint64(layer.Len()+1), properties)
+	}
+
+	if layer.Len() >= maxTilePoints {
+		fmt.Printf("⚠️ Тайл %d/%d/%d обрезан до %d точек\n", tile.Z, tile.X, tile.Y, maxTilePoints)
+	}
+
+	return vectorTile.Encode(layer), cursor.Err()
+}
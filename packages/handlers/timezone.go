@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// timezoneLocal значение tz: часовой пояс региона полета
+const timezoneLocal = "local"
+
+// timezoneParam значение параметра tz: local, UTC или название пояса IANA.
+// Для совместимости принимается и старый параметр timezone
+func timezoneParam(c *gin.Context) string {
+	if tz := strings.TrimSpace(c.Query("tz")); tz != "" {
+		return tz
+	}
+	return strings.TrimSpace(c.Query("timezone"))
+}
+
+// regionTimezones часовые пояса регионов из regionsGeo: название -> IANA
+func regionTimezones(collection useTables) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := collection.subjectListCollection.Find(ctx,
+		bson.M{"timezone": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"name": 1, "timezone": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	timezones := make(map[string]string)
+	for cursor.Next(ctx) {
+		var doc struct {
+			Name     string `bson:"name"`
+			Timezone string `bson:"timezone"`
+		}
+		if err := cursor.Decode(&doc); err == nil && doc.Timezone != "" {
+			timezones[doc.Name] = doc.Timezone
+		}
+	}
+	return timezones, cursor.Err()
+}
+
+// resolveTimezone возвращает пояс IANA для агрегаций по одному региону или группе регионов.
+// Пустое значение - UTC, local - общий пояс регионов regions
+func resolveTimezone(collection useTables, tz string, regions ...string) (string, *time.Location, error) {
+	switch {
+	case tz == "" || strings.EqualFold(tz, "UTC"):
+		return "UTC", time.UTC, nil
+	case strings.EqualFold(tz, timezoneLocal):
+		timezones, err := regionTimezones(collection)
+		if err != nil {
+			return "", nil, fmt.Errorf("ошибка получения часового пояса региона: %v", err)
+		}
+		name := ""
+		for _, region := range regions {
+			regionTimezone, ok := timezones[region]
+			if !ok {
+				return "", nil, fmt.Errorf("часовой пояс региона '%s' неизвестен", region)
+			}
+			if name != "" && regionTimezone != name {
+				return "", nil, fmt.Errorf("регионы находятся в разных часовых поясах, укажите tz явно")
+			}
+			name = regionTimezone
+		}
+		if name == "" {
+			return "", nil, fmt.Errorf("не указан регион для часового пояса local")
+		}
+		location, err := time.LoadLocation(name)
+		return name, location, err
+	default:
+		location, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return "", nil, fmt.Errorf("неизвестный часовой пояс: %s", tz)
+		}
+		return tz, location, nil
+	}
+}
+
+// timezoneExpression выражение часового пояса для $dateToString/$hour по множеству регионов:
+// для local - $switch по полю region с поясом каждого региона (UTC для неизвестных)
+func timezoneExpression(collection useTables, tz string) (interface{}, error) {
+	if !strings.EqualFold(tz, timezoneLocal) {
+		name, _, err := resolveTimezone(collection, tz)
+		return name, err
+	}
+
+	timezones, err := regionTimezones(collection)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения часовых поясов регионов: %v", err)
+	}
+	if len(timezones) == 0 {
+		return "UTC", nil
+	}
+
+	branches := make(bson.A, 0, len(timezones))
+	for name, timezone := range timezones {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$eq": bson.A{"$region", name}},
+			"then": timezone,
+		})
+	}
+	return bson.M{"$switch": bson.M{"branches": branches, "default": "UTC"}}, nil
+}
@@ -41,6 +41,10 @@ func ConnectToMongoDB() *mongo.Client {
 		fmt.Println("✅ Индексы успешно созданы/проверены")
 	}
 
+	if err := backfillDepPoints(client); err != nil {
+		fmt.Printf("⚠️  Предупреждение: не удалось заполнить depPoint: %v\n", err)
+	}
+
 	return client
 }
 
@@ -59,6 +63,34 @@ func сreateSearchFieldsIndexes(client *mongo.Client) error {
 			Keys:    bson.D{{Key: "searchFields.dateTime", Value: 1}},
 			Options: options.Index().SetName("searchFields_dateTime_1"),
 		},
+		{
+			Keys:    bson.D{{Key: "qualityFlags", Value: 1}},
+			Options: options.Index().SetName("qualityFlags_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "regionMatch.method", Value: 1}},
+			Options: options.Index().SetName("regionMatch_method_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "regionMatch.conflict", Value: 1}, {Key: "searchFields.dateTime", Value: -1}},
+			Options: options.Index().SetName("regionMatch_conflict_dateTime"),
+		},
+		{
+			Keys:    bson.D{{Key: "regionMatch.confidence", Value: 1}},
+			Options: options.Index().SetName("regionMatch_confidence_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "depPoint", Value: "2dsphere"}},
+			Options: options.Index().SetName("depPoint_2dsphere"),
+		},
+		{
+			Keys:    bson.D{{Key: "distanceKm", Value: 1}},
+			Options: options.Index().SetName("distanceKm_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "avgSpeedKmh", Value: 1}},
+			Options: options.Index().SetName("avgSpeedKmh_1"),
+		},
 	}
 
 	// Создаем индексы
@@ -69,3 +101,48 @@ func сreateSearchFieldsIndexes(client *mongo.Client) error {
 
 	return nil
 }
+
+// backfillDepPoints заполняет GeoJSON точку вылета depPoint у полетов, загруженных до ее появления.
+// Точка берется из dep, иначе из SHR - так же, как при разборе
+func backfillDepPoints(client *mongo.Client) error {
+	collection := GetCollection(client, "admin", "flightData")
+	ctx := context.Background()
+
+	validPoint := func(field string) bson.M {
+		return bson.M{
+			field + ".lat": bson.M{"$gte": -90, "$lte": 90},
+			field + ".lon": bson.M{"$gte": -180, "$lte": 180},
+			"$nor":         []bson.M{{field + ".lat": 0, field + ".lon": 0}},
+		}
+	}
+	setPoint := func(field string) mongo.Pipeline {
+		return mongo.Pipeline{{{Key: "$set", Value: bson.M{"depPoint": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{"$" + field + ".lon", "$" + field + ".lat"},
+		}}}}}
+	}
+
+	depFilter := validPoint("dep.coordinates")
+	depFilter["depPoint"] = bson.M{"$exists": false}
+	depResult, err := collection.UpdateMany(ctx, depFilter, setPoint("dep.coordinates"))
+	if err != nil {
+		return err
+	}
+
+	shrFilter := validPoint("shr.coordinatesDep")
+	shrFilter["depPoint"] = bson.M{"$exists": false}
+	// dep без координат или с заглушкой 0,0
+	shrFilter["$or"] = []bson.M{
+		{"dep.coordinates": bson.M{"$exists": false}},
+		{"dep.coordinates.lat": 0, "dep.coordinates.lon": 0},
+	}
+	shrResult, err := collection.UpdateMany(ctx, shrFilter, setPoint("shr.coordinatesDep"))
+	if err != nil {
+		return err
+	}
+
+	if updated := depResult.ModifiedCount + shrResult.ModifiedCount; updated > 0 {
+		fmt.Printf("✅ depPoint заполнен у %d полетов\n", updated)
+	}
+	return nil
+}
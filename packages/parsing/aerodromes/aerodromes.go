@@ -0,0 +1,204 @@
+package aerodromes
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	coorinates "project/packages/parsing/coordinates"
+	"project/packages/parsing/geoIndex"
+)
+
+// FileName необязательный справочник аэродромов в папке geojsonFiles.
+// Столбцы: код (ICAO или внутренний, несколько через "/"), название, широта, долгота, регион
+const FileName = "aerodromes.csv"
+
+// Коды аэродромов вылета и посадки в сообщениях DEP и ARR
+var (
+	adepRegex  = regexp.MustCompile(`-ADEP ([A-ZА-ЯЁ0-9]+)`)
+	adarrRegex = regexp.MustCompile(`-ADARR ([A-ZА-ЯЁ0-9]+)`)
+)
+
+// DepartureCode код аэродрома вылета (-ADEP) из сообщения DEP
+func DepartureCode(rawText string) string {
+	return extractCode(adepRegex, rawText)
+}
+
+// ArrivalCode код аэродрома посадки (-ADARR) из сообщения ARR
+func ArrivalCode(rawText string) string {
+	return extractCode(adarrRegex, rawText)
+}
+
+func extractCode(regex *regexp.Regexp, rawText string) string {
+	if matches := regex.FindStringSubmatch(strings.ToUpper(rawText)); len(matches) > 1 {
+		return NormalizeCode(matches[1])
+	}
+	return ""
+}
+
+// Aerodrome аэродром или посадочная площадка справочника
+type Aerodrome struct {
+	Code   string   `bson:"code" json:"code"`
+	Codes  []string `bson:"codes" json:"codes"` // Все коды, включая основной
+	Name   string   `bson:"name" json:"name"`
+	Lat    float64  `bson:"lat" json:"lat"`
+	Lon    float64  `bson:"lon" json:"lon"`
+	Region string   `bson:"region,omitempty" json:"region,omitempty"`
+}
+
+// Coordinate координаты аэродрома в формате парсера
+func (a Aerodrome) Coordinate() *coorinates.Coordinate {
+	return &coorinates.Coordinate{Lat: a.Lat, Lon: a.Lon}
+}
+
+// NormalizeCode приводит код аэродрома к верхнему регистру.
+// Пустая строка для кодов-заглушек ZZZZ (аэродром не указан)
+func NormalizeCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if strings.Trim(code, "ZЗ") == "" {
+		return ""
+	}
+	return code
+}
+
+// Parse читает справочник аэродромов из CSV. Строки с некорректными координатами
+// (включая заголовок) пропускаются, их коды возвращаются в invalid
+func Parse(reader io.Reader) ([]Aerodrome, []string, error) {
+	records, err := geoIndex.ReadTable(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка разбора справочника аэродромов: %v", err)
+	}
+
+	var list []Aerodrome
+	var invalid []string
+	seen := make(map[string]bool)
+	for i, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+		aerodrome, err := parseRecord(record)
+		if err != nil {
+			if i > 0 {
+				invalid = append(invalid, strings.TrimSpace(record[0]))
+			}
+			continue // Строка заголовка или некорректная строка
+		}
+
+		// Первое упоминание кода имеет приоритет
+		codes := aerodrome.Codes[:0]
+		for _, code := range aerodrome.Codes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) == 0 {
+			continue
+		}
+		aerodrome.Codes = codes
+		aerodrome.Code = codes[0]
+		list = append(list, aerodrome)
+	}
+	return list, invalid, nil
+}
+
+// parseRecord разбирает строку справочника. Координаты - десятичные градусы
+// или авиационный формат (5530N, 03730E)
+func parseRecord(record []string) (Aerodrome, error) {
+	aerodrome := Aerodrome{Name: strings.TrimSpace(record[1])}
+	if len(record) > 4 {
+		aerodrome.Region = strings.TrimSpace(record[4])
+	}
+
+	for _, code := range strings.FieldsFunc(record[0], func(r rune) bool { return r == '/' || r == ' ' }) {
+		if code = NormalizeCode(code); code != "" {
+			aerodrome.Codes = append(aerodrome.Codes, code)
+		}
+	}
+	if len(aerodrome.Codes) == 0 {
+		return Aerodrome{}, fmt.Errorf("не указан код")
+	}
+
+	lat, latErr := geoIndex.ParseDecimal(record[2])
+	lon, lonErr := geoIndex.ParseDecimal(record[3])
+	if latErr != nil || lonErr != nil {
+		coordinate, err := coorinates.ParseAviationCoordinate(strings.TrimSpace(record[2]) + strings.TrimSpace(record[3]))
+		if err != nil {
+			return Aerodrome{}, err
+		}
+		lat, lon = coordinate.Lat, coordinate.Lon
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 || (lat == 0 && lon == 0) {
+		return Aerodrome{}, fmt.Errorf("координаты вне диапазона")
+	}
+	aerodrome.Lat, aerodrome.Lon = lat, lon
+	return aerodrome, nil
+}
+
+// Directory справочник аэродромов в памяти: код -> аэродром
+type Directory struct {
+	byCode map[string]Aerodrome
+	list   []Aerodrome
+}
+
+// NewDirectory строит справочник по списку аэродромов
+func NewDirectory(list []Aerodrome) *Directory {
+	directory := &Directory{byCode: make(map[string]Aerodrome, len(list))}
+	for _, aerodrome := range list {
+		for _, code := range aerodrome.Codes {
+			if _, exists := directory.byCode[code]; !exists {
+				directory.byCode[code] = aerodrome
+			}
+		}
+		directory.list = append(directory.list, aerodrome)
+	}
+	sort.Slice(directory.list, func(i, j int) bool { return directory.list[i].Code < directory.list[j].Code })
+	return directory
+}
+
+// Len число аэродромов
+func (d *Directory) Len() int {
+	return len(d.list)
+}
+
+// Lookup аэродром по любому из его кодов
+func (d *Directory) Lookup(code string) (Aerodrome, bool) {
+	aerodrome, ok := d.byCode[NormalizeCode(code)]
+	return aerodrome, ok
+}
+
+// List аэродромы в порядке кодов
+func (d *Directory) List() []Aerodrome {
+	return d.list
+}
+
+var (
+	sharedMu sync.RWMutex
+	shared   *Directory
+)
+
+// Shared возвращает общий справочник аэродромов (nil, если не загружен)
+func Shared() *Directory {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return shared
+}
+
+// SetShared атомарно заменяет общий справочник аэродромов
+func SetShared(directory *Directory) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = directory
+}
+
+// Lookup ищет аэродром в общем справочнике
+func Lookup(code string) (Aerodrome, bool) {
+	directory := Shared()
+	if directory == nil {
+		return Aerodrome{}, false
+	}
+	return directory.Lookup(code)
+}
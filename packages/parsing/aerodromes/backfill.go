@@ -0,0 +1,171 @@
+package aerodromes
+
+import (
+	"context"
+	"fmt"
+
+	coorinates "project/packages/parsing/coordinates"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CoordinatesSourceAerodrome координаты вылета/посадки взяты из справочника аэродромов
+const CoordinatesSourceAerodrome = "aerodrome"
+
+// backfillBatchSize размер пачки обновлений
+const backfillBatchSize = 1000
+
+// codeFilter сообщения с кодом аэродрома
+var codeFilter = bson.M{"$or": []bson.M{
+	{"dep.rawText": bson.M{"$regex": "-ADEP "}},
+	{"arr.rawText": bson.M{"$regex": "-ADARR "}},
+}}
+
+// flightPoint часть полета, нужная для привязки аэродрома
+type flightPoint struct {
+	RawText           string                 `bson:"rawText"`
+	Airport           string                 `bson:"airport"`
+	Coordinates       *coorinates.Coordinate `bson:"coordinates"`
+	CoordinatesSource string                 `bson:"coordinatesSource"`
+}
+
+// ApplyToFlights проставляет коды аэродромов (dep.airport, arr.airport) у ранее загруженных полетов
+// и подставляет координаты справочника там, где явных координат нет. all=false - только полеты
+// без кода аэродрома, all=true - все полеты с кодами, например после загрузки нового справочника.
+// Возвращает число обновленных полетов и число полетов, у которых изменились координаты
+// (их регион нужно пересчитать)
+func ApplyToFlights(flights *mongo.Collection, directory *Directory, all bool) (int, int, error) {
+	ctx := context.Background()
+
+	filter := codeFilter
+	if !all {
+		filter = bson.M{"$or": []bson.M{
+			{"dep.rawText": bson.M{"$regex": "-ADEP "}, "dep.airport": nil},
+			{"arr.rawText": bson.M{"$regex": "-ADARR "}, "arr.airport": nil},
+		}}
+	}
+	opts := options.Find().SetProjection(bson.M{
+		"dep.rawText": 1, "dep.airport": 1, "dep.coordinates": 1, "dep.coordinatesSource": 1,
+		"arr.rawText": 1, "arr.airport": 1, "arr.coordinates": 1, "arr.coordinatesSource": 1,
+		"shr.coordinatesDep": 1, "shr.coordinatesArr": 1,
+	})
+
+	cursor, err := flights.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка чтения полетов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	updated, moved := 0, 0
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		result, err := flights.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения аэродромов: %v", err)
+		}
+		updated += int(result.ModifiedCount)
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID  interface{} `bson:"_id"`
+			Dep flightPoint `bson:"dep"`
+			Arr flightPoint `bson:"arr"`
+			SHR struct {
+				CoordinatesDep *coorinates.Coordinate `bson:"coordinatesDep"`
+				CoordinatesArr *coorinates.Coordinate `bson:"coordinatesArr"`
+			} `bson:"shr"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		set, unset := bson.M{}, bson.M{}
+		depMoved := resolvePoint(directory, "dep", &doc.Dep, DepartureCode(doc.Dep.RawText), doc.SHR.CoordinatesDep, set, unset)
+		arrMoved := resolvePoint(directory, "arr", &doc.Arr, ArrivalCode(doc.Arr.RawText), doc.SHR.CoordinatesArr, set, unset)
+
+		// Точка вылета для пространственных фильтров
+		if depMoved && doc.SHR.CoordinatesDep == nil {
+			if doc.Dep.Coordinates != nil {
+				set["depPoint"] = bson.M{"type": "Point", "coordinates": []float64{doc.Dep.Coordinates.Lon, doc.Dep.Coordinates.Lat}}
+			} else {
+				unset["depPoint"] = ""
+			}
+		}
+		if depMoved || arrMoved {
+			moved++
+		}
+
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if len(update) == 0 {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": doc.ID}).SetUpdate(update))
+		if len(models) >= backfillBatchSize {
+			if err := flush(); err != nil {
+				return updated, moved, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, moved, err
+	}
+	if err := flush(); err != nil {
+		return updated, moved, err
+	}
+
+	return updated, moved, nil
+}
+
+// resolvePoint обновляет код аэродрома и координаты справочника у вылета или посадки.
+// Явные координаты (ADEPZ/ADARRZ или DEP/ и DEST/ из SHR) не заменяются.
+// Возвращает true, если координаты точки изменились
+func resolvePoint(directory *Directory, prefix string, point *flightPoint, code string, shrCoordinates *coorinates.Coordinate, set, unset bson.M) bool {
+	if code != point.Airport {
+		if code != "" {
+			set[prefix+".airport"] = code
+		} else {
+			unset[prefix+".airport"] = ""
+		}
+	}
+
+	fromDirectory := point.CoordinatesSource == CoordinatesSourceAerodrome
+	if (point.Coordinates != nil && !fromDirectory) || shrCoordinates != nil {
+		return false
+	}
+
+	aerodrome, found := Aerodrome{}, false
+	if code != "" {
+		aerodrome, found = directory.Lookup(code)
+	}
+	switch {
+	case found:
+		if point.Coordinates != nil && point.Coordinates.Lat == aerodrome.Lat && point.Coordinates.Lon == aerodrome.Lon {
+			return false
+		}
+		point.Coordinates = aerodrome.Coordinate()
+		set[prefix+".coordinates"] = point.Coordinates
+		set[prefix+".coordinatesSource"] = CoordinatesSourceAerodrome
+		return true
+	case fromDirectory:
+		// Аэродром исключен из справочника
+		point.Coordinates = nil
+		unset[prefix+".coordinates"] = ""
+		unset[prefix+".coordinatesSource"] = ""
+		return true
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package aerodromes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"project/packages/parsing/geoIndex"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName коллекция справочника аэродромов
+const CollectionName = "aerodromes"
+
+// FilePath путь к файлу справочника в папке geojsonFiles
+func FilePath() (string, error) {
+	regionsDir, err := geoIndex.RegionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(regionsDir, FileName), nil
+}
+
+// LoadFileToMongo загружает справочник из geojsonFiles/aerodromes.csv, если файл есть.
+// Возвращает число загруженных аэродромов
+func LoadFileToMongo(collection *mongo.Collection) (int, error) {
+	path, err := FilePath()
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения справочника аэродромов: %v", err)
+	}
+	defer file.Close()
+
+	count, invalid, err := Import(collection, file)
+	if len(invalid) > 0 {
+		log.Printf("⚠️ Пропущены строки справочника аэродромов: %v", invalid)
+	}
+	return count, err
+}
+
+// Import заменяет справочник аэродромов в коллекции данными CSV. Справочник собирается
+// в промежуточной коллекции с уникальным индексом codes и подменяет рабочий целиком,
+// поэтому при ошибке остается прежний. Возвращает число аэродромов и коды некорректных строк
+func Import(collection *mongo.Collection, reader io.Reader) (int, []string, error) {
+	list, invalid, err := Parse(reader)
+	if err != nil {
+		return 0, invalid, err
+	}
+	if len(list) == 0 {
+		return 0, invalid, fmt.Errorf("в справочнике нет ни одного аэродрома")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(list))
+	for _, aerodrome := range list {
+		documents = append(documents, aerodrome)
+	}
+	if err := geoIndex.ReplaceCollection(ctx, collection, documents, ensureIndexes); err != nil {
+		return 0, invalid, fmt.Errorf("ошибка загрузки аэродромов: %v", err)
+	}
+
+	fmt.Printf("✅ В MongoDB загружено %d аэродромов\n", len(list))
+	return len(list), invalid, nil
+}
+
+// ensureIndexes уникальный индекс кодов аэродромов
+func ensureIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "codes", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// LoadFromCollection загружает справочник аэродромов из коллекции в память
+func LoadFromCollection(collection *mongo.Collection) (*Directory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска аэродромов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var list []Aerodrome
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования аэродромов: %v", err)
+	}
+	return NewDirectory(list), nil
+}
+
+// EnsureFlightIndexes индексы полетов для фильтра по аэродрому
+func EnsureFlightIndexes(flights *mongo.Collection) error {
+	_, err := flights.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "dep.airport", Value: 1}}, Options: options.Index().SetName("dep_airport")},
+		{Keys: bson.D{{Key: "arr.airport", Value: 1}}, Options: options.Index().SetName("arr_airport")},
+	})
+	return err
+}
@@ -0,0 +1,278 @@
+package airspace
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	coorinates "project/packages/parsing/coordinates"
+	"project/packages/parsing/geoMath"
+)
+
+// Типы зон ограничения
+const (
+	TypeProhibited = "prohibited" // Запретная зона (P)
+	TypeRestricted = "restricted" // Зона ограничения полетов (R)
+	TypeDanger     = "danger"     // Опасная зона (D)
+	TypeCTR        = "ctr"        // Диспетчерская зона аэродрома (CTR/ATZ)
+	TypeOther      = "other"
+)
+
+// Источник пересечения: точка вылета или район полетов из /ZONA
+const (
+	SourceDeparture = "dep"
+	SourceZone      = "zone"
+)
+
+var (
+	zoneRegex   = regexp.MustCompile(`/ZONA\s+([^/]+)/`)
+	radiusRegex = regexp.MustCompile(`(?:^|\s)R\s?(\d+(?:[.,]\d+)?)\s?(KM|M)?(?:\s|$)`)
+	coordRegex  = regexp.MustCompile(coorinates.Pattern)
+)
+
+// FlightZone район полетов из поля /ZONA сообщения SHR: круг радиусом RadiusKm
+// с центром в Points[0] или многоугольник по точкам Points ([lon, lat])
+type FlightZone struct {
+	RadiusKm float64     `bson:"radiusKm,omitempty" json:"radiusKm,omitempty"`
+	Points   [][]float64 `bson:"points" json:"points"`
+}
+
+// ParseZone разбирает район полетов "/ZONA R0,5 5530N03730E/" или
+// "/ZONA 5530N03730E 5531N03735E 5529N03736E/". nil, если района нет или он не разобран
+func ParseZone(rawText string) *FlightZone {
+	matches := zoneRegex.FindStringSubmatch(rawText)
+	if len(matches) < 2 {
+		return nil
+	}
+	text := strings.ToUpper(matches[1])
+
+	zone := &FlightZone{}
+	if radius := radiusRegex.FindStringSubmatch(text); len(radius) > 1 {
+		value, err := strconv.ParseFloat(strings.Replace(radius[1], ",", ".", 1), 64)
+		if err == nil && value > 0 {
+			if radius[2] == "M" {
+				value /= 1000
+			}
+			zone.RadiusKm = value
+		}
+		text = strings.Replace(text, radius[0], " ", 1)
+	}
+
+	for _, token := range coordRegex.FindAllString(text, -1) {
+		coordinate, err := coorinates.ParseAviationCoordinate(token)
+		if err != nil || (coordinate.Lat == 0 && coordinate.Lon == 0) {
+			continue
+		}
+		zone.Points = append(zone.Points, []float64{coordinate.Lon, coordinate.Lat})
+	}
+
+	if len(zone.Points) == 0 {
+		return nil
+	}
+	return zone
+}
+
+// IsCircle район задан кругом
+func (z *FlightZone) IsCircle() bool {
+	return z.RadiusKm > 0
+}
+
+// ring замкнутое кольцо многоугольника района
+func (z *FlightZone) ring() [][]float64 {
+	ring := append([][]float64{}, z.Points...)
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		ring = append(ring, first)
+	}
+	return ring
+}
+
+// bbox охватывающий прямоугольник района
+func (z *FlightZone) bbox() geoMath.BBox {
+	box := geoMath.RingBBox(z.Points)
+	if z.IsCircle() {
+		center := z.Points[0]
+		dLat, dLon := geoMath.DegreesForMeters(center[1], z.RadiusKm*1000)
+		box.Extend(center[0]-dLon, center[1]-dLat)
+		box.Extend(center[0]+dLon, center[1]+dLat)
+	}
+	return box
+}
+
+// Area зона ограничения с геометрией в виде полигонов (кольца lon/lat)
+type Area struct {
+	Designator string          `bson:"designator" json:"designator"`
+	Name       string          `bson:"name" json:"name"`
+	Type       string          `bson:"type" json:"type"`
+	Lower      string          `bson:"lower,omitempty" json:"lower,omitempty"`
+	Upper      string          `bson:"upper,omitempty" json:"upper,omitempty"`
+	Source     string          `bson:"source,omitempty" json:"source,omitempty"`
+	Polygons   [][][][]float64 `bson:"-" json:"-"`
+}
+
+// Hit пересечение полета с зоной ограничения
+type Hit struct {
+	Designator string `bson:"designator" json:"designator"`
+	Name       string `bson:"name" json:"name"`
+	Type       string `bson:"type" json:"type"`
+	Source     string `bson:"source" json:"source"` // dep или zone
+}
+
+// preparedArea зона с прямоугольниками полигонов для быстрого отсева
+type preparedArea struct {
+	area  Area
+	bbox  geoMath.BBox
+	boxes []geoMath.BBox
+}
+
+// Index зоны ограничения в памяти
+type Index struct {
+	areas []preparedArea
+}
+
+// NewIndex строит индекс по списку зон
+func NewIndex(areas []Area) *Index {
+	index := &Index{}
+	for _, area := range areas {
+		prepared := preparedArea{area: area, bbox: geoMath.EmptyBBox()}
+		for _, polygon := range area.Polygons {
+			box := geoMath.EmptyBBox()
+			if len(polygon) > 0 {
+				box = geoMath.RingBBox(polygon[0])
+			}
+			prepared.boxes = append(prepared.boxes, box)
+			prepared.bbox.Union(box)
+		}
+		index.areas = append(index.areas, prepared)
+	}
+	return index
+}
+
+// Len число зон в индексе
+func (ix *Index) Len() int {
+	return len(ix.areas)
+}
+
+// Check возвращает зоны, в которые попадает точка вылета (lat, lon; hasDep - точка известна)
+// и с которыми пересекается район полетов zone. Зона, задетая и точкой, и районом,
+// возвращается один раз с источником dep
+func (ix *Index) Check(lat, lon float64, hasDep bool, zone *FlightZone) []Hit {
+	hits := []Hit{}
+	var zoneBox geoMath.BBox
+	if zone != nil {
+		zoneBox = zone.bbox()
+	}
+
+	for _, prepared := range ix.areas {
+		source := ""
+		if hasDep && prepared.bbox.Contains(lon, lat) && prepared.containsPoint(lon, lat) {
+			source = SourceDeparture
+		} else if zone != nil && prepared.bbox.Intersects(zoneBox) && prepared.intersectsZone(zone) {
+			source = SourceZone
+		}
+		if source == "" {
+			continue
+		}
+		hits = append(hits, Hit{
+			Designator: prepared.area.Designator,
+			Name:       prepared.area.Name,
+			Type:       prepared.area.Type,
+			Source:     source,
+		})
+	}
+	return hits
+}
+
+// containsPoint попадание точки в зону
+func (p *preparedArea) containsPoint(x, y float64) bool {
+	for i, polygon := range p.area.Polygons {
+		if p.boxes[i].Contains(x, y) && geoMath.PointInPolygon(x, y, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectsZone пересечение зоны с районом полетов
+func (p *preparedArea) intersectsZone(zone *FlightZone) bool {
+	if zone.IsCircle() {
+		center := zone.Points[0]
+		if p.containsPoint(center[0], center[1]) {
+			return true
+		}
+		radiusMeters := zone.RadiusKm * 1000
+		for _, polygon := range p.area.Polygons {
+			for _, ring := range polygon {
+				if geoMath.DistanceToRingMeters(center[1], center[0], ring) <= radiusMeters {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Отдельные точки без радиуса
+	for _, point := range zone.Points {
+		if p.containsPoint(point[0], point[1]) {
+			return true
+		}
+	}
+	if len(zone.Points) < 3 {
+		return false
+	}
+
+	zoneRing := zone.ring()
+	for _, polygon := range p.area.Polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		// Зона целиком внутри района полетов
+		if point := polygon[0][0]; geoMath.PointInRing(point[0], point[1], zoneRing) {
+			return true
+		}
+		// Пересечение границ
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				for j := 1; j < len(zoneRing); j++ {
+					if geoMath.SegmentsIntersect(ring[i-1], ring[i], zoneRing[j-1], zoneRing[j]) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// circlePolygon многоугольник, аппроксимирующий круг радиусом radiusMeters
+func circlePolygon(lat, lon, radiusMeters float64, segments int) [][][]float64 {
+	dLat, dLon := geoMath.DegreesForMeters(lat, radiusMeters)
+	ring := make([][]float64, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		ring = append(ring, []float64{lon + dLon*math.Cos(angle), lat + dLat*math.Sin(angle)})
+	}
+	ring = append(ring, ring[0])
+	return [][][]float64{ring}
+}
+
+var (
+	sharedMu sync.RWMutex
+	shared   *Index
+)
+
+// Shared возвращает общий индекс зон ограничения (nil, если не загружен)
+func Shared() *Index {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return shared
+}
+
+// SetShared атомарно заменяет общий индекс зон ограничения
+func SetShared(index *Index) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = index
+}
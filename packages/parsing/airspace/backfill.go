@@ -0,0 +1,100 @@
+package airspace
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfillBatchSize размер пачки обновлений при проверке полетов
+const backfillBatchSize = 1000
+
+// ApplyToFlights проверяет полеты по зонам ограничения и сохраняет пересечения в поле airspace.
+// all=false - только полеты, еще не проверенные (airspace отсутствует), all=true - все полеты,
+// например после перезагрузки зон. Район полетов /ZONA разбирается из текста SHR, если он не сохранен.
+// Возвращает число обновленных полетов
+func ApplyToFlights(flights *mongo.Collection, index *Index, all bool) (int, error) {
+	ctx := context.Background()
+
+	filter := bson.M{"airspace": nil}
+	if all {
+		filter = bson.M{}
+	}
+	opts := options.Find().SetProjection(bson.M{
+		"depPoint":    1,
+		"shr.zone":    1,
+		"shr.rawText": 1,
+	})
+
+	cursor, err := flights.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения полетов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		result, err := flights.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения пересечений с зонами: %v", err)
+		}
+		updated += int(result.ModifiedCount)
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       interface{} `bson:"_id"`
+			DepPoint *struct {
+				Coordinates []float64 `bson:"coordinates"`
+			} `bson:"depPoint"`
+			SHR struct {
+				Zone    *FlightZone `bson:"zone"`
+				RawText string      `bson:"rawText"`
+			} `bson:"shr"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		set := bson.M{}
+		zone := doc.SHR.Zone
+		if zone == nil {
+			if zone = ParseZone(doc.SHR.RawText); zone != nil {
+				set["shr.zone"] = zone
+			}
+		}
+
+		var lat, lon float64
+		hasDep := doc.DepPoint != nil && len(doc.DepPoint.Coordinates) == 2
+		if hasDep {
+			lon, lat = doc.DepPoint.Coordinates[0], doc.DepPoint.Coordinates[1]
+		}
+		set["airspace"] = index.Check(lat, lon, hasDep, zone)
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID}).
+			SetUpdate(bson.M{"$set": set}))
+		if len(models) >= backfillBatchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+	if err := flush(); err != nil {
+		return updated, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,323 @@
+package airspace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"project/packages/parsing/geoIndex"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName коллекция зон ограничения
+const CollectionName = "airspaceGeo"
+
+// circleSegments число вершин многоугольника для зон, заданных точкой и радиусом
+const circleSegments = 64
+
+// Поля properties с обозначением, типом и вертикальными границами зоны
+var (
+	designatorKeys = []string{"designator", "ident", "code", "id"}
+	typeKeys       = []string{"type", "class", "category", "zoneType", "airspace_type"}
+	lowerKeys      = []string{"lower", "lowerLimit", "lower_limit"}
+	upperKeys      = []string{"upper", "upperLimit", "upper_limit"}
+	radiusKeys     = []string{"radiusKm", "radius_km", "radius"}
+)
+
+// designatorTypeRegex тип зоны по обозначению: UUP101 - запретная, UUR12 - ограничения, UUD5 - опасная
+var designatorTypeRegex = regexp.MustCompile(`^[A-Z]{2}([PRD])\d`)
+
+// Dir папка GeoJSON файлов зон ограничения внутри geojsonFiles
+func Dir() (string, error) {
+	regionsDir, err := geoIndex.RegionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(regionsDir, geoIndex.AirspaceDirName), nil
+}
+
+// Report отчет о чтении зон ограничения по файлам, как у наборов границ
+type Report struct {
+	Files    []geoIndex.FileReport `json:"files"`
+	Areas    int                   `json:"areas"`
+	Repaired int                   `json:"repaired"`
+	Errors   int                   `json:"errors"`
+}
+
+// FailedFiles число файлов, которые не удалось разобрать
+func (r Report) FailedFiles() int {
+	failed := 0
+	for _, file := range r.Files {
+		if file.Failed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ReadDir читает зоны ограничения из GeoJSON файлов папки. Неразобранные файлы и пропущенные
+// зоны попадают в отчет
+func ReadDir(dir string) ([]Area, Report, error) {
+	var report Report
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, report, nil
+	}
+
+	files, err := geoIndex.GetGeoJSONFiles(dir)
+	if err != nil {
+		return nil, report, fmt.Errorf("ошибка чтения папки: %v", err)
+	}
+
+	var areas []Area
+	for _, file := range files {
+		source := filepath.Base(file)
+		fileReport := geoIndex.FileReport{Name: source, Kind: geoIndex.AirspaceDirName}
+
+		geoJSONFile, err := geoIndex.ReadGeoJSONFile(file)
+		if err != nil {
+			log.Printf("⚠️ Ошибка обработки файла %s: %v", file, err)
+			fileReport.Failed = true
+			fileReport.Errors = append(fileReport.Errors, err.Error())
+			report.Files = append(report.Files, fileReport)
+			continue
+		}
+
+		fileReport.Features = len(geoJSONFile.Features)
+		for i, feature := range geoJSONFile.Features {
+			featureReport := geoIndex.FeatureReport{Index: i, Name: geoIndex.FeatureName(feature.Properties, i)}
+
+			area, repairs, err := featureArea(feature, i, source)
+			if err != nil {
+				log.Printf("⚠️ Пропущена зона %d в файле %s: %v", i, source, err)
+				featureReport.Status = geoIndex.FeatureInvalid
+				featureReport.Errors = []string{err.Error()}
+				fileReport.Errors = append(fileReport.Errors, fmt.Sprintf("%s: %v", featureReport.Name, err))
+				fileReport.FeatureReports = append(fileReport.FeatureReports, featureReport)
+				report.Errors++
+				continue
+			}
+
+			featureReport.Status = geoIndex.FeatureOK
+			if len(repairs) > 0 {
+				featureReport.Status = geoIndex.FeatureRepaired
+				featureReport.Repairs = repairs
+				fileReport.Repaired++
+				report.Repaired++
+			}
+			fileReport.Loaded++
+			fileReport.FeatureReports = append(fileReport.FeatureReports, featureReport)
+			areas = append(areas, area)
+		}
+		report.Files = append(report.Files, fileReport)
+	}
+	report.Areas = len(areas)
+	return areas, report, nil
+}
+
+// featureArea зона ограничения из объекта GeoJSON и список исправлений геометрии
+func featureArea(feature geoIndex.GeoJSONFeature, index int, source string) (Area, []string, error) {
+	properties := feature.Properties
+	area := Area{
+		Name:   geoIndex.FeatureName(properties, index),
+		Lower:  geoIndex.FirstProperty(properties, lowerKeys),
+		Upper:  geoIndex.FirstProperty(properties, upperKeys),
+		Source: source,
+	}
+
+	area.Designator = strings.ToUpper(geoIndex.FirstProperty(properties, designatorKeys))
+	if area.Designator == "" {
+		area.Designator = area.Name
+	}
+	area.Type = areaType(geoIndex.FirstProperty(properties, typeKeys), area.Designator)
+
+	polygons, repairs, err := featurePolygons(feature.Geometry, properties)
+	if err != nil {
+		return Area{}, nil, err
+	}
+	area.Polygons = polygons
+	return area, repairs, nil
+}
+
+// featurePolygons полигоны зоны: Polygon, MultiPolygon или Point с радиусом в км
+func featurePolygons(geometry geoIndex.GeoJSONGeometry, properties map[string]interface{}) ([][][][]float64, []string, error) {
+	var polygons [][][][]float64
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil, nil, fmt.Errorf("неверные координаты Polygon: %v", err)
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, nil, fmt.Errorf("неверные координаты MultiPolygon: %v", err)
+		}
+	case "Point":
+		var point []float64
+		if err := json.Unmarshal(geometry.Coordinates, &point); err != nil || len(point) < 2 {
+			return nil, nil, fmt.Errorf("неверные координаты Point")
+		}
+		radiusKm, err := geoIndex.ParseDecimal(geoIndex.FirstProperty(properties, radiusKeys))
+		if err != nil || radiusKm <= 0 {
+			return nil, nil, fmt.Errorf("для зоны-точки требуется свойство radiusKm")
+		}
+		polygons = [][][][]float64{circlePolygon(point[1], point[0], radiusKm*1000, circleSegments)}
+	default:
+		return nil, nil, fmt.Errorf("неподдерживаемый тип геометрии: %s", geometry.Type)
+	}
+
+	for _, polygon := range polygons {
+		for r, ring := range polygon {
+			if len(ring) < 3 {
+				return nil, nil, fmt.Errorf("кольцо содержит меньше 3 точек")
+			}
+			for _, point := range ring {
+				if len(point) < 2 || math.Abs(point[0]) > 180 || math.Abs(point[1]) > 90 {
+					return nil, nil, fmt.Errorf("неверная точка: %v", point)
+				}
+			}
+			if first, last := ring[0], ring[len(ring)-1]; first[0] != last[0] || first[1] != last[1] {
+				polygon[r] = append(ring, first)
+			}
+		}
+	}
+
+	polygons, _, err := geoIndex.NormalizeAntimeridian(polygons)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Самопересечения и дыры вне зоны не принимает 2dsphere индекс
+	return geoIndex.RepairPolygons(polygons)
+}
+
+// areaType тип зоны по свойству type/class или по обозначению
+func areaType(value, designator string) string {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "P", "PROHIBITED", "ЗАПРЕТНАЯ", "ЗАПРЕТНАЯ ЗОНА":
+		return TypeProhibited
+	case "R", "RESTRICTED", "ОГРАНИЧЕНИЯ", "ЗОНА ОГРАНИЧЕНИЯ ПОЛЕТОВ":
+		return TypeRestricted
+	case "D", "DANGER", "ОПАСНАЯ", "ОПАСНАЯ ЗОНА":
+		return TypeDanger
+	case "CTR", "ATZ", "CONTROL ZONE", "ДИСПЕТЧЕРСКАЯ ЗОНА":
+		return TypeCTR
+	}
+
+	if matches := designatorTypeRegex.FindStringSubmatch(designator); len(matches) > 1 {
+		switch matches[1] {
+		case "P":
+			return TypeProhibited
+		case "R":
+			return TypeRestricted
+		case "D":
+			return TypeDanger
+		}
+	}
+	if strings.Contains(designator, "CTR") || strings.Contains(designator, "ATZ") {
+		return TypeCTR
+	}
+	return TypeOther
+}
+
+// LoadToMongo перечитывает зоны ограничения из папки geojsonFiles/airspace в коллекцию.
+// Коллекция заменяется целиком через промежуточную (geoIndex.ReplaceCollection); если какой-то
+// файл не разобран, прежние зоны остаются на месте. Возвращает отчет по файлам
+func LoadToMongo(collection *mongo.Collection) (Report, error) {
+	dir, err := Dir()
+	if err != nil {
+		return Report{}, err
+	}
+
+	areas, report, err := ReadDir(dir)
+	if err != nil {
+		return report, err
+	}
+	if failed := report.FailedFiles(); failed > 0 {
+		return report, fmt.Errorf("зоны ограничения не прошли проверку: файлов с ошибками %d", failed)
+	}
+	if len(areas) == 0 {
+		fmt.Printf("ℹ️  Зоны ограничения не найдены в %s\n", dir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(areas))
+	for _, area := range areas {
+		documents = append(documents, bson.M{
+			"designator": area.Designator,
+			"name":       area.Name,
+			"type":       area.Type,
+			"lower":      area.Lower,
+			"upper":      area.Upper,
+			"source":     area.Source,
+			"geometry":   bson.M{"type": "MultiPolygon", "coordinates": area.Polygons},
+		})
+	}
+	if err := geoIndex.ReplaceCollection(ctx, collection, documents, createZoneIndexes); err != nil {
+		return report, fmt.Errorf("ошибка загрузки зон ограничения: %v", err)
+	}
+
+	fmt.Printf("✅ В MongoDB загружено %d зон ограничения\n", len(areas))
+	return report, nil
+}
+
+// createZoneIndexes индексы коллекции зон ограничения
+func createZoneIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "geometry", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "designator", Value: 1}}},
+	})
+	return err
+}
+
+// LoadFromCollection загружает зоны ограничения из коллекции в индекс
+func LoadFromCollection(collection *mongo.Collection) (*Index, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска зон ограничения: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var areas []Area
+	for cursor.Next(ctx) {
+		var doc struct {
+			Area     `bson:",inline"`
+			Geometry struct {
+				Coordinates [][][][]float64 `bson:"coordinates"`
+			} `bson:"geometry"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("⚠️ Ошибка декодирования зоны ограничения: %v", err)
+			continue
+		}
+		doc.Area.Polygons = doc.Geometry.Coordinates
+		areas = append(areas, doc.Area)
+	}
+	return NewIndex(areas), cursor.Err()
+}
+
+// EnsureFlightIndexes индексы полетов для выборки нарушений по зоне и периоду
+func EnsureFlightIndexes(flights *mongo.Collection) error {
+	_, err := flights.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "airspace.designator", Value: 1},
+			{Key: "searchFields.dateTime", Value: 1},
+		},
+		Options: options.Index().SetName("airspace_designator_dateTime"),
+	})
+	return err
+}
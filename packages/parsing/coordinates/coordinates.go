@@ -1,148 +1,235 @@
 package coorinates
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 type Coordinate struct {
 	Lat float64 `bson:"lat"`
 	Lon float64 `bson:"lon"`
+	// Precision точность исходного формата (Precision.String), пусто - координата не из текста сообщения
+	Precision string `bson:"precision,omitempty"`
+	// Swapped - в исходной строке долгота шла перед широтой
+	Swapped bool `bson:"swapped,omitempty"`
 }
 
-// ParseAviationCoordinate универсальный парсер для разных форматов
-func ParseAviationCoordinate(coord string) (*Coordinate, error) {
+// Pattern регулярное выражение координаты для встраивания в шаблоны парсера
+// (DEP/, DEST/, -ADEPZ, -ADARRZ). Допускает десятичные минуты, пробел между
+// широтой и долготой и кириллические буквы полушарий.
+const Pattern = `[0-9]+(?:[.,][0-9]+)?\s?[NSСЮ]\s?[0-9]+(?:[.,][0-9]+)?[EWВЗ]`
+
+// Типизированные ошибки парсинга
+var (
+	ErrEmpty          = errors.New("empty coordinate")
+	ErrUnknownFormat  = errors.New("unknown coordinate format")
+	ErrLatitudeRange  = errors.New("latitude out of range")
+	ErrLongitudeRange = errors.New("longitude out of range")
+	ErrMinutesRange   = errors.New("minutes out of range")
+	ErrSecondsRange   = errors.New("seconds out of range")
+)
 
-	// Определяем формат по длине строки
-	switch len(coord) {
-	case 11, 12: // Базовый формат: DDMMN/SDDDMME
-		return parseBasicFormat(coord)
-	case 15, 16: // Высокоточный формат: DDMMSSN/SDDDMMSSE
-		return parseHighPrecisionFormat(coord)
-	default:
-		return nil, fmt.Errorf("unknown coordinate format: %s (length: %d)", coord, len(coord))
-	}
+// ParseError ошибка разбора конкретной строки координат
+type ParseError struct {
+	Input string
+	Err   error
 }
 
-// Парсер базового формата (DDMMN/SDDDMME)
-func parseBasicFormat(coord string) (*Coordinate, error) {
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: %q", e.Err, e.Input)
+}
 
-	// Широта: DDMMN
-	latDegrees, err := strconv.Atoi(coord[:2])
-	if err != nil {
-		return nil, err
-	}
-	latMinutes, err := strconv.Atoi(coord[2:4])
-	if err != nil {
-		return nil, err
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Precision точность исходного формата координат
+type Precision int
+
+const (
+	PrecisionUnknown        Precision = iota
+	PrecisionDegrees                  // DDN DDDE
+	PrecisionDecimalDegrees           // DD.dN DDD.dE
+	PrecisionMinutes                  // DDMMN DDDMME
+	PrecisionDecimalMinutes           // DDMM.mN DDDMM.mE
+	PrecisionSeconds                  // DDMMSSN DDDMMSSE
+)
+
+func (p Precision) String() string {
+	switch p {
+	case PrecisionDegrees:
+		return "degrees"
+	case PrecisionDecimalDegrees:
+		return "decimalDegrees"
+	case PrecisionMinutes:
+		return "minutes"
+	case PrecisionDecimalMinutes:
+		return "decimalMinutes"
+	case PrecisionSeconds:
+		return "seconds"
+	default:
+		return "unknown"
 	}
-	latHemisphere := coord[4:5]
+}
 
-	// Долгота: DDDMME (может начинаться с 3 цифр градусов)
-	lonStart := 5
-	// Определяем, где начинаются градусы долготы (3 или 2 цифры)
-	if coord[5] >= '0' && coord[5] <= '9' {
-		lonStart = 5 // DDDMM format
-	} else {
-		return nil, fmt.Errorf("invalid longitude format: %s", coord)
+// ApproxMeters возвращает примерную погрешность формата по широте в метрах
+func (p Precision) ApproxMeters() float64 {
+	switch p {
+	case PrecisionDegrees:
+		return 111000
+	case PrecisionDecimalDegrees:
+		return 11100
+	case PrecisionMinutes:
+		return 1852
+	case PrecisionDecimalMinutes:
+		return 185
+	case PrecisionSeconds:
+		return 31
+	default:
+		return 0
 	}
+}
 
-	lonDegrees, err := strconv.Atoi(coord[lonStart : lonStart+3])
-	if err != nil {
-		// Пробуем 2 цифры для долготы
-		lonDegrees, err = strconv.Atoi(coord[lonStart : lonStart+2])
-		if err != nil {
-			return nil, err
-		}
-		lonMinutes, err := strconv.Atoi(coord[lonStart+2 : lonStart+4])
-		if err != nil {
-			return nil, err
-		}
-		lonHemisphere := coord[lonStart+4 : lonStart+5]
+var (
+	latLonRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([NS])([0-9]+(?:\.[0-9]+)?)([EW])$`)
+	lonLatRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([EW])([0-9]+(?:\.[0-9]+)?)([NS])$`)
 
-		lat := float64(latDegrees) + float64(latMinutes)/60.0
-		if latHemisphere == "S" {
-			lat = -lat
-		}
+	// Кириллические буквы полушарий: Север, Юг, Восток, Запад
+	hemisphereReplacer = strings.NewReplacer(
+		"С", "N", "Ю", "S", "В", "E", "З", "W",
+		"с", "N", "ю", "S", "в", "E", "з", "W",
+		",", ".",
+	)
+)
 
-		lon := float64(lonDegrees) + float64(lonMinutes)/60.0
-		if lonHemisphere == "W" {
-			lon = -lon
-		}
+// ParseAviationCoordinate разбирает координату и сохраняет в ней точность формата.
+// Поддерживаются градусы, минуты, секунды, десятичная дробь в последнем компоненте,
+// пробелы между широтой и долготой, кириллические буквы полушарий
+// и обратный порядок (долгота перед широтой).
+func ParseAviationCoordinate(coord string) (*Coordinate, error) {
+	normalized := normalize(coord)
+	if normalized == "" {
+		return nil, &ParseError{Input: coord, Err: ErrEmpty}
+	}
+
+	var latStr, latHem, lonStr, lonHem string
+	swapped := false
 
-		return &Coordinate{Lat: lat, Lon: lon}, nil
+	if m := latLonRegex.FindStringSubmatch(normalized); m != nil {
+		latStr, latHem, lonStr, lonHem = m[1], m[2], m[3], m[4]
+	} else if m := lonLatRegex.FindStringSubmatch(normalized); m != nil {
+		lonStr, lonHem, latStr, latHem = m[1], m[2], m[3], m[4]
+		swapped = true
+	} else {
+		return nil, &ParseError{Input: coord, Err: ErrUnknownFormat}
 	}
 
-	lonMinutes, err := strconv.Atoi(coord[lonStart+3 : lonStart+5])
+	lat, latPrecision, err := parseComponent(latStr)
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Input: coord, Err: err}
+	}
+	lon, lonPrecision, err := parseComponent(lonStr)
+	if err != nil {
+		return nil, &ParseError{Input: coord, Err: err}
 	}
-	lonHemisphere := coord[lonStart+5 : lonStart+6]
 
-	lat := float64(latDegrees) + float64(latMinutes)/60.0
-	if latHemisphere == "S" {
-		lat = -lat
+	if lat > 90 {
+		return nil, &ParseError{Input: coord, Err: ErrLatitudeRange}
+	}
+	if lon > 180 {
+		return nil, &ParseError{Input: coord, Err: ErrLongitudeRange}
 	}
 
-	lon := float64(lonDegrees) + float64(lonMinutes)/60.0
-	if lonHemisphere == "W" {
+	if latHem == "S" {
+		lat = -lat
+	}
+	if lonHem == "W" {
 		lon = -lon
 	}
 
-	lat = roundOptimal(lat)
-	lon = roundOptimal(lon)
+	// Итоговая точность определяется менее точным компонентом
+	precision := latPrecision
+	if lonPrecision < precision {
+		precision = lonPrecision
+	}
 
-	return &Coordinate{Lat: lat, Lon: lon}, nil
+	return &Coordinate{
+		Lat:       roundOptimal(lat),
+		Lon:       roundOptimal(lon),
+		Precision: precision.String(),
+		Swapped:   swapped,
+	}, nil
 }
 
-// Парсер высокоточного формата (DDMMSSN/SDDDMMSSE)
-func parseHighPrecisionFormat(coord string) (*Coordinate, error) {
+// normalize приводит строку к виду DDMM[.m]NDDDMM[.m]E без пробелов
+func normalize(coord string) string {
+	s := hemisphereReplacer.Replace(strings.TrimSpace(coord))
+	s = strings.ToUpper(s)
+	return strings.Join(strings.Fields(s), "")
+}
 
-	// Широта: DDMMSSN
-	latDegrees, err := strconv.Atoi(coord[:2])
-	if err != nil {
-		return nil, err
-	}
-	latMinutes, err := strconv.Atoi(coord[2:4])
-	if err != nil {
-		return nil, err
-	}
-	latSeconds, err := strconv.Atoi(coord[4:6])
-	if err != nil {
-		return nil, err
-	}
-	latHemisphere := coord[6:7]
+// parseComponent разбирает градусы [минуты [секунды]] с необязательной дробной частью.
+// Ширина поля градусов: 2 цифры при чётной длине целой части, 3 - при нечётной.
+func parseComponent(s string) (float64, Precision, error) {
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
 
-	// Долгота: DDDMMSSE
-	lonDegrees, err := strconv.Atoi(coord[7:10])
-	if err != nil {
-		return nil, err
-	}
-	lonMinutes, err := strconv.Atoi(coord[10:12])
-	if err != nil {
-		return nil, err
+	degWidth := 2
+	if len(intPart)%2 == 1 {
+		degWidth = 3
 	}
-	lonSeconds, err := strconv.Atoi(coord[12:14])
-	if err != nil {
-		return nil, err
+	if len(intPart) < degWidth {
+		degWidth = len(intPart)
 	}
-	lonHemisphere := coord[14:15]
 
-	lat := float64(latDegrees) + float64(latMinutes)/60.0 + float64(latSeconds)/3600.0
-	if latHemisphere == "S" {
-		lat = -lat
+	rest := len(intPart) - degWidth
+	if rest != 0 && rest != 2 && rest != 4 {
+		return 0, PrecisionUnknown, ErrUnknownFormat
 	}
 
-	lon := float64(lonDegrees) + float64(lonMinutes)/60.0 + float64(lonSeconds)/3600.0
-	if lonHemisphere == "W" {
-		lon = -lon
+	degrees, _ := strconv.Atoi(intPart[:degWidth])
+	value := float64(degrees)
+
+	var fraction float64
+	if hasFrac {
+		fraction, _ = strconv.ParseFloat("0."+fracPart, 64)
 	}
 
-	lat = roundOptimal(lat)
-	lon = roundOptimal(lon)
+	var precision Precision
+	switch rest {
+	case 0:
+		value += fraction
+		precision = PrecisionDegrees
+		if hasFrac {
+			precision = PrecisionDecimalDegrees
+		}
+	case 2:
+		minutes, _ := strconv.Atoi(intPart[degWidth:])
+		if minutes >= 60 {
+			return 0, PrecisionUnknown, ErrMinutesRange
+		}
+		value += (float64(minutes) + fraction) / 60.0
+		precision = PrecisionMinutes
+		if hasFrac {
+			precision = PrecisionDecimalMinutes
+		}
+	case 4:
+		minutes, _ := strconv.Atoi(intPart[degWidth : degWidth+2])
+		if minutes >= 60 {
+			return 0, PrecisionUnknown, ErrMinutesRange
+		}
+		seconds, _ := strconv.Atoi(intPart[degWidth+2:])
+		if seconds >= 60 {
+			return 0, PrecisionUnknown, ErrSecondsRange
+		}
+		value += float64(minutes)/60.0 + (float64(seconds)+fraction)/3600.0
+		precision = PrecisionSeconds
+	}
 
-	return &Coordinate{Lat: lat, Lon: lon}, nil
+	return value, precision, nil
 }
 
 func roundOptimal(value float64) float64 {
@@ -0,0 +1,106 @@
+package coorinates
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAviationCoordinate(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		lat, lon  float64
+		precision Precision
+		swapped   bool
+	}{
+		{"минуты", "5530N03730E", 55.5, 37.5, PrecisionMinutes, false},
+		{"десятичные минуты", "5530.5N03730.2E", 55.508333, 37.503333, PrecisionDecimalMinutes, false},
+		{"десятичные минуты через запятую", "5530,5N03730,2E", 55.508333, 37.503333, PrecisionDecimalMinutes, false},
+		{"только градусы", "55N037E", 55, 37, PrecisionDegrees, false},
+		{"десятичные градусы", "55.5N37.5E", 55.5, 37.5, PrecisionDecimalDegrees, false},
+		{"секунды", "553012N0373015E", 55.503333, 37.504167, PrecisionSeconds, false},
+		{"пробел между широтой и долготой", "5530N 03730E", 55.5, 37.5, PrecisionMinutes, false},
+		{"пробелы по краям", "  5530N03730E ", 55.5, 37.5, PrecisionMinutes, false},
+		{"кириллица С/В", "5530С03730В", 55.5, 37.5, PrecisionMinutes, false},
+		{"кириллица Ю/З", "5530Ю03730З", -55.5, -37.5, PrecisionMinutes, false},
+		{"строчные буквы", "5530n03730e", 55.5, 37.5, PrecisionMinutes, false},
+		{"долгота перед широтой", "03730E5530N", 55.5, 37.5, PrecisionMinutes, true},
+		{"точность по менее точному компоненту", "553012N03730E", 55.503333, 37.5, PrecisionMinutes, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAviationCoordinate(tt.input)
+			if err != nil {
+				t.Fatalf("ParseAviationCoordinate(%q) error: %v", tt.input, err)
+			}
+			if math.Abs(got.Lat-tt.lat) > 1e-6 || math.Abs(got.Lon-tt.lon) > 1e-6 {
+				t.Errorf("ParseAviationCoordinate(%q) = (%v, %v), want (%v, %v)", tt.input, got.Lat, got.Lon, tt.lat, tt.lon)
+			}
+			if got.Precision != tt.precision.String() {
+				t.Errorf("ParseAviationCoordinate(%q) precision = %s, want %s", tt.input, got.Precision, tt.precision)
+			}
+			if got.Swapped != tt.swapped {
+				t.Errorf("ParseAviationCoordinate(%q) swapped = %v, want %v", tt.input, got.Swapped, tt.swapped)
+			}
+		})
+	}
+}
+
+func TestParseAviationCoordinateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"пустая строка", "", ErrEmpty},
+		{"только пробелы", "   ", ErrEmpty},
+		{"мусор", "abc", ErrUnknownFormat},
+		{"нет полушария долготы", "5530N03730", ErrUnknownFormat},
+		{"лишние цифры", "553012345N03730E", ErrUnknownFormat},
+		{"широта больше 90", "9530N03730E", ErrLatitudeRange},
+		{"долгота больше 180", "5530N18530E", ErrLongitudeRange},
+		{"минуты больше 59", "5575N03730E", ErrMinutesRange},
+		{"секунды больше 59", "553075N0373015E", ErrSecondsRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAviationCoordinate(tt.input)
+			if got != nil {
+				t.Errorf("ParseAviationCoordinate(%q) = %+v, want nil", tt.input, got)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ParseAviationCoordinate(%q) error = %v, want %v", tt.input, err, tt.want)
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Input != tt.input {
+				t.Errorf("ParseAviationCoordinate(%q) error %v is not a ParseError with input", tt.input, err)
+			}
+		})
+	}
+}
+
+func FuzzParseAviationCoordinate(f *testing.F) {
+	for _, seed := range []string{
+		"5530N03730E", "5530.5N03730.2E", "55N037E", "553012N0373015E", "5530N 03730E",
+		"5530С03730В", "03730E5530N", "9530N03730E", "5575N03730E", "", "N", "0.N0.E",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		got, err := ParseAviationCoordinate(input)
+		if err != nil {
+			var parseErr *ParseError
+			if got != nil || !errors.As(err, &parseErr) {
+				t.Fatalf("ParseAviationCoordinate(%q) = %+v, %v: want nil and ParseError", input, got, err)
+			}
+			return
+		}
+		if math.IsNaN(got.Lat) || math.IsNaN(got.Lon) || math.Abs(got.Lat) > 90 || math.Abs(got.Lon) > 180 {
+			t.Fatalf("ParseAviationCoordinate(%q) out of range: %+v", input, got)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package gazetteer
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfillBatchSize размер пачки обновлений
+const backfillBatchSize = 1000
+
+// ApplyToFlights определяет ближайший населенный пункт для точек вылета полетов.
+// Полетам вдали от населенных пунктов записывается nearestSettlement: null - отметка о проверке,
+// чтобы они не пересчитывались при каждом запуске.
+// all=false - только еще не проверенные полеты (поля nearestSettlement нет), all=true - все
+// полеты с точкой вылета, например после загрузки нового справочника. Возвращает число обновленных полетов
+func ApplyToFlights(flights *mongo.Collection, index *Index, all bool) (int, error) {
+	ctx := context.Background()
+
+	filter := bson.M{"depPoint": bson.M{"$ne": nil}}
+	if !all {
+		filter["nearestSettlement"] = bson.M{"$exists": false}
+	}
+	opts := options.Find().SetProjection(bson.M{"depPoint": 1})
+
+	cursor, err := flights.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения полетов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		result, err := flights.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения населенных пунктов: %v", err)
+		}
+		updated += int(result.ModifiedCount)
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       interface{} `bson:"_id"`
+			DepPoint struct {
+				Coordinates []float64 `bson:"coordinates"`
+			} `bson:"depPoint"`
+		}
+		if err := cursor.Decode(&doc); err != nil || len(doc.DepPoint.Coordinates) != 2 {
+			continue
+		}
+
+		// nil - населенных пунктов поблизости нет (или прежний исключен из справочника)
+		nearest := index.Nearest(doc.DepPoint.Coordinates[1], doc.DepPoint.Coordinates[0])
+		update := bson.M{"$set": bson.M{"nearestSettlement": nearest}}
+
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": doc.ID}).SetUpdate(update))
+		if len(models) >= backfillBatchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+	if err := flush(); err != nil {
+		return updated, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,159 @@
+package gazetteer
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+
+	"project/packages/parsing/geoMath"
+)
+
+// cellDegrees размер ячейки сетки индекса в градусах
+const cellDegrees = 0.2
+
+// searchRadiiKm радиусы последовательного поиска ближайшего населенного пункта
+var searchRadiiKm = []float64{5, 20}
+
+// MaxDistanceKm максимальное расстояние до населенного пункта
+// (GAZETTEER_MAX_DISTANCE_KM); дальше точка считается вне населенных пунктов
+var MaxDistanceKm = maxDistanceFromEnv()
+
+func maxDistanceFromEnv() float64 {
+	if value := os.Getenv("GAZETTEER_MAX_DISTANCE_KM"); value != "" {
+		if km, err := strconv.ParseFloat(value, 64); err == nil && km > 0 {
+			return km
+		}
+	}
+	return 50
+}
+
+// Settlement населенный пункт справочника
+type Settlement struct {
+	Name       string  `bson:"name" json:"name"`
+	Type       string  `bson:"type" json:"type"` // Значение OSM place: city, town, village, hamlet...
+	Lat        float64 `bson:"lat" json:"lat"`
+	Lon        float64 `bson:"lon" json:"lon"`
+	Region     string  `bson:"region,omitempty" json:"region,omitempty"`
+	Population int64   `bson:"population,omitempty" json:"population,omitempty"`
+}
+
+// Nearest ближайший к точке населенный пункт с расстоянием
+type Nearest struct {
+	Name       string  `bson:"name" json:"name"`
+	Type       string  `bson:"type" json:"type"`
+	Region     string  `bson:"region,omitempty" json:"region,omitempty"`
+	DistanceKm float64 `bson:"distanceKm" json:"distanceKm"`
+}
+
+// typeLabels названия типов населенных пунктов для выгрузки
+var typeLabels = map[string]string{
+	"city":              "город",
+	"town":              "город",
+	"village":           "село",
+	"hamlet":            "деревня",
+	"isolated_dwelling": "хутор",
+}
+
+// TypeLabel русское название типа населенного пункта
+func TypeLabel(placeType string) string {
+	if label, ok := typeLabels[placeType]; ok {
+		return label
+	}
+	return placeType
+}
+
+type cellKey struct {
+	lat, lon int
+}
+
+// Index населенные пункты в сетке ячеек для поиска ближайшего
+type Index struct {
+	cells map[cellKey][]Settlement
+	count int
+}
+
+// NewIndex строит индекс по списку населенных пунктов
+func NewIndex(settlements []Settlement) *Index {
+	index := &Index{cells: make(map[cellKey][]Settlement)}
+	for _, settlement := range settlements {
+		key := cellOf(settlement.Lat, settlement.Lon)
+		index.cells[key] = append(index.cells[key], settlement)
+		index.count++
+	}
+	return index
+}
+
+func cellOf(lat, lon float64) cellKey {
+	return cellKey{int(math.Floor(lat / cellDegrees)), int(math.Floor(lon / cellDegrees))}
+}
+
+// Len число населенных пунктов
+func (ix *Index) Len() int {
+	return ix.count
+}
+
+// Nearest ближайший населенный пункт в пределах MaxDistanceKm; nil, если его нет.
+// Поиск начинается с малого радиуса и расширяется, пока кандидат не найден
+func (ix *Index) Nearest(lat, lon float64) *Nearest {
+	radii := append(append([]float64{}, searchRadiiKm...), MaxDistanceKm)
+	for _, radiusKm := range radii {
+		if radiusKm > MaxDistanceKm {
+			continue
+		}
+		if nearest := ix.nearestWithin(lat, lon, radiusKm); nearest != nil {
+			return nearest
+		}
+	}
+	return nil
+}
+
+// nearestWithin ближайший населенный пункт не дальше radiusKm
+func (ix *Index) nearestWithin(lat, lon, radiusKm float64) *Nearest {
+	// Запас 10% на неточность перевода метров в градусы
+	dLat, dLon := geoMath.DegreesForMeters(lat, radiusKm*1000*1.1)
+	from := cellOf(lat-dLat, lon-dLon)
+	to := cellOf(lat+dLat, lon+dLon)
+
+	var best *Settlement
+	bestMeters := radiusKm * 1000
+	for cellLat := from.lat; cellLat <= to.lat; cellLat++ {
+		for cellLon := from.lon; cellLon <= to.lon; cellLon++ {
+			settlements := ix.cells[cellKey{cellLat, cellLon}]
+			for i := range settlements {
+				meters := geoMath.HaversineMeters(lat, lon, settlements[i].Lat, settlements[i].Lon)
+				if meters <= bestMeters {
+					best, bestMeters = &settlements[i], meters
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &Nearest{
+		Name:       best.Name,
+		Type:       best.Type,
+		Region:     best.Region,
+		DistanceKm: math.Round(bestMeters/10) / 100,
+	}
+}
+
+var (
+	sharedMu sync.RWMutex
+	shared   *Index
+)
+
+// Shared возвращает общий индекс населенных пунктов (nil, если не загружен)
+func Shared() *Index {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return shared
+}
+
+// SetShared атомарно заменяет общий индекс населенных пунктов
+func SetShared(index *Index) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = index
+}
@@ -0,0 +1,298 @@
+package gazetteer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName коллекция справочника населенных пунктов
+const CollectionName = "settlements"
+
+// settlementTypes значения OSM place, которые считаются населенными пунктами.
+// Части городов (suburb, quarter, neighbourhood) не учитываются
+var settlementTypes = map[string]bool{
+	"city":              true,
+	"town":              true,
+	"village":           true,
+	"hamlet":            true,
+	"isolated_dwelling": true,
+}
+
+// Поля properties выгрузки OSM
+var (
+	nameKeys       = []string{"name:ru", "name"}
+	typeKeys       = []string{"place", "type"}
+	regionKeys     = []string{"addr:region", "is_in:region", "region", "is_in:state"}
+	populationKeys = []string{"population"}
+)
+
+// Dir папка справочника внутри geojsonFiles
+func Dir() (string, error) {
+	regionsDir, err := geoIndex.RegionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(regionsDir, geoIndex.GazetteerDirName), nil
+}
+
+// Parse читает населенные пункты из выгрузки OSM в GeoJSON (объекты place) или из CSV
+// со столбцами: название, тип, широта, долгота, регион, население. Формат определяется по содержимому.
+// Возвращает населенные пункты и число пропущенных записей
+func Parse(reader io.Reader) ([]Settlement, int, error) {
+	buffered := bufio.NewReader(reader)
+	for {
+		r, _, err := buffered.ReadRune()
+		if err != nil {
+			return nil, 0, fmt.Errorf("пустой файл справочника")
+		}
+		if r == '\ufeff' || r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			continue
+		}
+		if err := buffered.UnreadRune(); err != nil {
+			return nil, 0, err
+		}
+		if r == '{' {
+			return parseGeoJSON(buffered)
+		}
+		return parseCSV(buffered)
+	}
+}
+
+// parseGeoJSON населенные пункты из GeoJSON: точки или полигоны (берется центр охватывающего прямоугольника)
+func parseGeoJSON(reader io.Reader) ([]Settlement, int, error) {
+	var file geoIndex.GeoJSONFile
+	if err := json.NewDecoder(reader).Decode(&file); err != nil {
+		return nil, 0, fmt.Errorf("ошибка парсинга GeoJSON: %v", err)
+	}
+
+	var settlements []Settlement
+	skipped := 0
+	for _, feature := range file.Features {
+		settlement := Settlement{
+			Name:   geoIndex.FirstProperty(feature.Properties, nameKeys),
+			Type:   strings.ToLower(geoIndex.FirstProperty(feature.Properties, typeKeys)),
+			Region: geoIndex.FirstProperty(feature.Properties, regionKeys),
+		}
+		if settlement.Name == "" || (settlement.Type != "" && !settlementTypes[settlement.Type]) {
+			skipped++
+			continue
+		}
+		lat, lon, ok := featurePoint(feature.Geometry)
+		if !ok {
+			skipped++
+			continue
+		}
+		settlement.Lat, settlement.Lon = lat, lon
+		settlement.Population = parsePopulation(geoIndex.FirstProperty(feature.Properties, populationKeys))
+		settlements = append(settlements, settlement)
+	}
+	return settlements, skipped, nil
+}
+
+// featurePoint координаты объекта: точка или центр внешнего кольца полигона
+func featurePoint(geometry geoIndex.GeoJSONGeometry) (float64, float64, bool) {
+	var ring [][]float64
+	switch geometry.Type {
+	case "Point":
+		var point []float64
+		if err := json.Unmarshal(geometry.Coordinates, &point); err != nil || len(point) < 2 {
+			return 0, 0, false
+		}
+		return point[1], point[0], validPoint(point[1], point[0])
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil || len(polygon) == 0 {
+			return 0, 0, false
+		}
+		ring = polygon[0]
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil || len(polygons) == 0 || len(polygons[0]) == 0 {
+			return 0, 0, false
+		}
+		ring = polygons[0][0]
+	default:
+		return 0, 0, false
+	}
+	if len(ring) == 0 {
+		return 0, 0, false
+	}
+	box := geoMath.RingBBox(ring)
+	lat, lon := (box.MinY+box.MaxY)/2, (box.MinX+box.MaxX)/2
+	return lat, lon, validPoint(lat, lon)
+}
+
+// parseCSV населенные пункты из CSV; строки с некорректными координатами (включая заголовок) пропускаются
+func parseCSV(reader io.Reader) ([]Settlement, int, error) {
+	records, err := geoIndex.ReadTable(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка разбора CSV: %v", err)
+	}
+
+	var settlements []Settlement
+	skipped := 0
+	for i, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+		lat, latErr := geoIndex.ParseDecimal(record[2])
+		lon, lonErr := geoIndex.ParseDecimal(record[3])
+		name := strings.TrimSpace(record[0])
+		if latErr != nil || lonErr != nil || name == "" || !validPoint(lat, lon) {
+			if i > 0 {
+				skipped++
+			}
+			continue
+		}
+		settlement := Settlement{
+			Name: name,
+			Type: strings.ToLower(strings.TrimSpace(record[1])),
+			Lat:  lat,
+			Lon:  lon,
+		}
+		if len(record) > 4 {
+			settlement.Region = strings.TrimSpace(record[4])
+		}
+		if len(record) > 5 {
+			settlement.Population = parsePopulation(record[5])
+		}
+		settlements = append(settlements, settlement)
+	}
+	return settlements, skipped, nil
+}
+
+func validPoint(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && (lat != 0 || lon != 0)
+}
+
+// parsePopulation население; в OSM встречаются значения с пробелами и пометками ("12 345", "1200;2010")
+func parsePopulation(value string) int64 {
+	value = strings.NewReplacer(" ", "", "\u00a0", "").Replace(value)
+	if end := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+		value = value[:end]
+	}
+	population, _ := strconv.ParseInt(value, 10, 64)
+	return population
+}
+
+// ReadDir читает все файлы справочника (.geojson, .json, .csv) из папки
+func ReadDir(dir string) ([]Settlement, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения папки: %v", err)
+	}
+
+	var settlements []Settlement
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".geojson" && ext != ".json" && ext != ".csv") {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("⚠️ Ошибка чтения файла %s: %v", entry.Name(), err)
+			continue
+		}
+		parsed, skipped, err := Parse(file)
+		file.Close()
+		if err != nil {
+			log.Printf("⚠️ Ошибка обработки файла %s: %v", entry.Name(), err)
+			continue
+		}
+		if skipped > 0 {
+			log.Printf("ℹ️  %s: пропущено записей %d", entry.Name(), skipped)
+		}
+		settlements = append(settlements, parsed...)
+	}
+	return settlements, nil
+}
+
+// LoadDirToMongo загружает справочник из папки geojsonFiles/gazetteer, если в ней есть файлы.
+// Возвращает число загруженных населенных пунктов
+func LoadDirToMongo(collection *mongo.Collection) (int, error) {
+	dir, err := Dir()
+	if err != nil {
+		return 0, err
+	}
+	settlements, err := ReadDir(dir)
+	if err != nil || len(settlements) == 0 {
+		return 0, err
+	}
+	return Replace(collection, settlements)
+}
+
+// Replace заменяет справочник населенных пунктов в коллекции. Справочник собирается
+// в промежуточной коллекции и подменяет рабочий целиком: при ошибке остается прежний
+func Replace(collection *mongo.Collection, settlements []Settlement) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(settlements))
+	for _, settlement := range settlements {
+		documents = append(documents, settlement)
+	}
+	if err := geoIndex.ReplaceCollection(ctx, collection, documents, ensureIndexes); err != nil {
+		return 0, fmt.Errorf("ошибка загрузки населенных пунктов: %v", err)
+	}
+
+	fmt.Printf("✅ В MongoDB загружено %d населенных пунктов\n", len(settlements))
+	return len(settlements), nil
+}
+
+// ensureIndexes индексы справочника по названию и региону
+func ensureIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "region", Value: 1}}},
+	})
+	return err
+}
+
+// LoadFromCollection загружает справочник населенных пунктов из коллекции в индекс
+func LoadFromCollection(collection *mongo.Collection) (*Index, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 0}))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска населенных пунктов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования населенных пунктов: %v", err)
+	}
+	return NewIndex(settlements), nil
+}
+
+// EnsureFlightIndexes индекс полетов для фильтра и группировки по населенному пункту
+func EnsureFlightIndexes(flights *mongo.Collection) error {
+	_, err := flights.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "nearestSettlement.name", Value: 1},
+			{Key: "searchFields.dateTime", Value: 1},
+		},
+		Options: options.Index().SetName("nearestSettlement_name_dateTime"),
+	})
+	return err
+}
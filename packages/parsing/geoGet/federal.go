@@ -0,0 +1,160 @@
+package geoGet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FederalDistrictGeoResponse объединенная геометрия федерального округа
+type FederalDistrictGeoResponse struct {
+	FederalDistrict string                 `json:"federalDistrict"`
+	GeoJSON         GeoJSONFeatureResponse `json:"geojson"`
+}
+
+// federalShape объединенная геометрия округа и входящие в него регионы
+type federalShape struct {
+	name     string
+	regions  []string
+	polygons [][][][]float64
+}
+
+// federalEntry объединенные геометрии округов одного уровня масштаба; done закрывается после вычисления
+type federalEntry struct {
+	done   chan struct{}
+	shapes []federalShape
+	err    error
+}
+
+// federalCache объединенные геометрии по уровню масштаба; сбрасывается при смене соответствия
+// регионов округам и вместе с simplifiedCache. generation увеличивается при сбросе, чтобы
+// округа, объединенные из упрощенных геометрий старых границ, не попали в новый кэш
+var federalCache = struct {
+	sync.Mutex
+	generation int
+	mapping    string
+	levels     map[int]*federalEntry
+}{levels: make(map[int]*federalEntry)}
+
+// GetFederalDistrictsGeo возвращает геометрии федеральных округов, объединенные из
+// упрощенных с допуском tolerance регионов. districtOf - федеральный округ по названию региона,
+// codes - краткие обозначения округов (ЦФО, СЗФО, ...) для свойств объектов
+func GetFederalDistrictsGeo(collection *mongo.Collection, tolerance float64, districtOf, codes map[string]string) ([]FederalDistrictGeoResponse, error) {
+	shapes, err := federalLevel(collection, tolerance, districtOf)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []FederalDistrictGeoResponse{}
+	for _, shape := range shapes {
+		geometry := GeometryResponse{Type: "MultiPolygon", Coordinates: shape.polygons}
+		if len(shape.polygons) == 1 {
+			geometry = GeometryResponse{Type: "Polygon", Coordinates: shape.polygons[0]}
+		}
+
+		results = append(results, FederalDistrictGeoResponse{
+			FederalDistrict: shape.name,
+			GeoJSON: GeoJSONFeatureResponse{
+				Type:     "Feature",
+				Geometry: geometry,
+				Properties: map[string]interface{}{
+					"code":    codes[shape.name],
+					"regions": shape.regions,
+				},
+			},
+		})
+	}
+
+	return results, nil
+}
+
+// federalLevel возвращает объединенные геометрии округов из кэша или вычисляет их вне блокировки
+func federalLevel(collection *mongo.Collection, tolerance float64, districtOf map[string]string) ([]federalShape, error) {
+	federalCache.Lock()
+	generation := federalCache.generation
+	federalCache.Unlock()
+
+	regions, err := simplifiedLevel(collection, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	zoom := ZoomForTolerance(tolerance)
+
+	federalCache.Lock()
+	if generation != federalCache.generation {
+		// Кэш сброшен, пока загружались регионы: результат по старым границам не сохраняем
+		federalCache.Unlock()
+		return dissolveFederal(regions, districtOf)
+	}
+	mapping := mappingSignature(districtOf)
+	if federalCache.mapping != mapping {
+		federalCache.mapping = mapping
+		federalCache.levels = make(map[int]*federalEntry)
+	}
+	if entry, ok := federalCache.levels[zoom]; ok {
+		federalCache.Unlock()
+		<-entry.done
+		return entry.shapes, entry.err
+	}
+	entry := &federalEntry{done: make(chan struct{})}
+	federalCache.levels[zoom] = entry
+	federalCache.Unlock()
+
+	entry.shapes, entry.err = dissolveFederal(regions, districtOf)
+	if entry.err != nil {
+		federalCache.Lock()
+		if federalCache.levels[zoom] == entry {
+			delete(federalCache.levels, zoom)
+		}
+		federalCache.Unlock()
+	} else {
+		fmt.Printf("🗺️ Объединены геометрии федеральных округов с допуском %g°: %d округов\n",
+			ToleranceForZoom(zoom), len(entry.shapes))
+	}
+	close(entry.done)
+	return entry.shapes, entry.err
+}
+
+// dissolveFederal объединяет упрощенные регионы по федеральным округам
+func dissolveFederal(regions []simplifiedRegion, districtOf map[string]string) ([]federalShape, error) {
+	grouped := make(map[string]*federalShape)
+	parts := make(map[string][][][][][]float64)
+	for _, region := range regions {
+		district, ok := districtOf[region.name]
+		if !ok || district == "" {
+			continue
+		}
+		if grouped[district] == nil {
+			grouped[district] = &federalShape{name: district}
+		}
+		grouped[district].regions = append(grouped[district].regions, region.name)
+		parts[district] = append(parts[district], region.polygons)
+	}
+	if len(grouped) == 0 {
+		return nil, fmt.Errorf("регионы не сопоставлены федеральным округам")
+	}
+
+	shapes := make([]federalShape, 0, len(grouped))
+	for district, shape := range grouped {
+		sort.Strings(shape.regions)
+		shape.polygons = geoMath.DissolvePolygons(parts[district])
+		shapes = append(shapes, *shape)
+	}
+	sort.Slice(shapes, func(i, j int) bool { return shapes[i].name < shapes[j].name })
+	return shapes, nil
+}
+
+// mappingSignature строка, однозначно описывающая соответствие регионов округам
+func mappingSignature(districtOf map[string]string) string {
+	pairs := make([]string, 0, len(districtOf))
+	for region, district := range districtOf {
+		pairs = append(pairs, region+"\x00"+district)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x01")
+}
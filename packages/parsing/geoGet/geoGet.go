@@ -55,13 +55,10 @@ func GetRegionsGeo(collection *mongo.Collection) ([]RegionGeoResponse, error) {
 			continue
 		}
 
+		// Геометрии, пересекающие 180-й меридиан, разрезаются при загрузке (geoIndex)
 		geoType, _ := geometry["type"].(string)
 		coordinates := geometry["coordinates"]
 
-		if regionName == "Чукотский автономный округ" {
-			coordinates = normalizeGeometry(coordinates)
-		}
-
 		response := RegionGeoResponse{
 			Region: regionName,
 			GeoJSON: GeoJSONFeatureResponse{
@@ -80,64 +77,3 @@ func GetRegionsGeo(collection *mongo.Collection) ([]RegionGeoResponse, error) {
 
 	return results, nil
 }
-
-// Применяет нормализацию координат для Чукотки
-func normalizeGeometry(coordinates any) any {
-
-	fmt.Printf("🔧 Начинаем нормализацию Чукотки. Тип coordinates: %T\n", coordinates)
-
-	return processBSONArray(coordinates.(bson.A))
-}
-
-// processBSONArray обрабатывает bson.A (массив из MongoDB)
-func processBSONArray(data bson.A) [][][][]float64 {
-	var result [][][][]float64
-
-	for _, polyItem := range data {
-		if polyArray, ok := polyItem.(bson.A); ok {
-			var polygon [][][]float64
-
-			for _, ringItem := range polyArray {
-				if ringArray, ok := ringItem.(bson.A); ok {
-					var ring [][]float64
-
-					for _, pointItem := range ringArray {
-						if pointArray, ok := pointItem.(bson.A); ok {
-							var coord []float64
-
-							for _, numItem := range pointArray {
-								switch num := numItem.(type) {
-								case float64:
-									coord = append(coord, num)
-								case int32:
-									coord = append(coord, float64(num))
-								case int64:
-									coord = append(coord, float64(num))
-								default:
-									fmt.Printf("⚠️ Неподдерживаемый тип числа: %T\n", num)
-									coord = append(coord, 0)
-								}
-							}
-
-							// Применяем коррекцию для Чукотки
-							if len(coord) >= 2 {
-								lng := coord[0]
-								if lng > -170 {
-									coord[0] = lng - 0.0000005 // Сдвигаем долготу
-								}
-							}
-
-							ring = append(ring, coord)
-						}
-					}
-
-					polygon = append(polygon, ring)
-				}
-			}
-
-			result = append(result, polygon)
-		}
-	}
-
-	return result
-}
@@ -0,0 +1,460 @@
+package geoGet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"project/packages/parsing/geoMath"
+	"project/packages/parsing/geoTree"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxZoom максимальный уровень масштаба для упрощения; дальше отдаются исходные геометрии
+const MaxZoom = 14
+
+// RegionFilter отбор регионов для ответа
+type RegionFilter struct {
+	Names []string      // Только указанные регионы (пусто - все)
+	BBox  *geoMath.BBox // Только регионы, пересекающие прямоугольник
+}
+
+// simplifiedRegion упрощенная геометрия региона с прямоугольником для отбора
+type simplifiedRegion struct {
+	name     string
+	bbox     geoMath.BBox
+	polygons [][][][]float64
+}
+
+// levelEntry упрощенные геометрии одного уровня масштаба; done закрывается после вычисления
+type levelEntry struct {
+	done    chan struct{}
+	regions []simplifiedRegion
+	err     error
+}
+
+// simplifiedCache упрощенные геометрии по уровню масштаба (0..MaxZoom). generation
+// увеличивается при сбросе, чтобы уровни, посчитанные по старым границам, не попали в новый кэш
+var simplifiedCache = struct {
+	sync.Mutex
+	generation int
+	levels     map[int]*levelEntry
+}{levels: make(map[int]*levelEntry)}
+
+// errStaleLevel кэш сброшен во время предварительного расчета уровней
+var errStaleLevel = errors.New("кэш упрощенных геометрий сброшен")
+
+// ToleranceForZoom допуск упрощения в градусах для уровня масштаба веб-карты:
+// половина пикселя тайла 256x256 на экваторе
+func ToleranceForZoom(zoom int) float64 {
+	if zoom >= MaxZoom {
+		return 0
+	}
+	if zoom < 0 {
+		zoom = 0
+	}
+	return 360.0 / (256 * math.Pow(2, float64(zoom))) / 2
+}
+
+// ZoomForTolerance уровень масштаба, к которому приводится произвольный tolerance:
+// самый мелкий уровень, допуск которого не больше запрошенного (MaxZoom - исходные геометрии)
+func ZoomForTolerance(tolerance float64) int {
+	for zoom := 0; zoom < MaxZoom; zoom++ {
+		if ToleranceForZoom(zoom) <= tolerance {
+			return zoom
+		}
+	}
+	return MaxZoom
+}
+
+// ResetSimplifiedCache сбрасывает кэш упрощенных и объединенных геометрий (после перезагрузки регионов)
+func ResetSimplifiedCache() {
+	simplifiedCache.Lock()
+	simplifiedCache.generation++
+	simplifiedCache.levels = make(map[int]*levelEntry)
+	simplifiedCache.Unlock()
+
+	federalCache.Lock()
+	federalCache.generation++
+	federalCache.levels = make(map[int]*federalEntry)
+	federalCache.Unlock()
+}
+
+// PrecomputeSimplified заранее упрощает регионы для всех уровней масштаба 0..MaxZoom-1,
+// загружая исходные геометрии один раз. Прерывается без ошибки, если кэш сброшен во время расчета
+func PrecomputeSimplified(collection *mongo.Collection) error {
+	simplifiedCache.Lock()
+	generation := simplifiedCache.generation
+	simplifiedCache.Unlock()
+
+	var source []geoTree.Region
+	load := func() ([]geoTree.Region, error) {
+		if source != nil {
+			return source, nil
+		}
+		regions, err := loadSimplifySource(collection)
+		source = regions
+		return regions, err
+	}
+
+	for zoom := 0; zoom < MaxZoom; zoom++ {
+		if _, err := levelRegions(zoom, generation, load); err != nil {
+			if errors.Is(err, errStaleLevel) {
+				return nil
+			}
+			return err
+		}
+	}
+	fmt.Printf("🗺️ Упрощенные геометрии регионов подготовлены для уровней 0-%d\n", MaxZoom-1)
+	return nil
+}
+
+// RegionShape упрощенная геометрия региона
+type RegionShape struct {
+	Name     string
+	BBox     geoMath.BBox
+	Polygons [][][][]float64
+}
+
+// SimplifiedRegions возвращает регионы, упрощенные с допуском tolerance (в градусах) и отобранные по фильтру.
+// Общие границы соседних регионов упрощаются одинаково, поэтому между ними не появляется щелей и наложений.
+// tolerance приводится к уровню масштаба (ZoomForTolerance), результат каждого уровня вычисляется один раз
+func SimplifiedRegions(collection *mongo.Collection, tolerance float64, filter RegionFilter) ([]RegionShape, error) {
+	regions, err := simplifiedLevel(collection, tolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(filter.Names))
+	for _, name := range filter.Names {
+		names[name] = true
+	}
+
+	var shapes []RegionShape
+	for _, region := range regions {
+		if len(names) > 0 && !names[region.name] {
+			continue
+		}
+		if filter.BBox != nil && !region.bbox.Intersects(*filter.BBox) {
+			continue
+		}
+		shapes = append(shapes, RegionShape{Name: region.name, BBox: region.bbox, Polygons: region.polygons})
+	}
+
+	return shapes, nil
+}
+
+// GetRegionsGeoSimplified возвращает упрощенные регионы в формате ответа /regions/geojson
+func GetRegionsGeoSimplified(collection *mongo.Collection, tolerance float64, filter RegionFilter) ([]RegionGeoResponse, error) {
+	shapes, err := SimplifiedRegions(collection, tolerance, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RegionGeoResponse{}
+	for _, shape := range shapes {
+		geometry := GeometryResponse{Type: "MultiPolygon", Coordinates: shape.Polygons}
+		if len(shape.Polygons) == 1 {
+			geometry = GeometryResponse{Type: "Polygon", Coordinates: shape.Polygons[0]}
+		}
+
+		results = append(results, RegionGeoResponse{
+			Region: shape.Name,
+			GeoJSON: GeoJSONFeatureResponse{
+				Type:       "Feature",
+				Geometry:   geometry,
+				Properties: map[string]interface{}{},
+			},
+		})
+	}
+
+	return results, nil
+}
+
+// simplifiedLevel возвращает упрощенные регионы уровня, к которому приводится tolerance
+func simplifiedLevel(collection *mongo.Collection, tolerance float64) ([]simplifiedRegion, error) {
+	return levelRegions(ZoomForTolerance(tolerance), -1, func() ([]geoTree.Region, error) {
+		return loadSimplifySource(collection)
+	})
+}
+
+// levelRegions возвращает упрощенные регионы уровня zoom из кэша или вычисляет их.
+// Блокировка кэша держится только на время поиска записи: загрузка и упрощение идут вне ее,
+// одновременные запросы того же уровня ждут одно вычисление. generation >= 0 - поколение кэша,
+// для которого считается уровень; после сброса возвращается errStaleLevel
+func levelRegions(zoom, generation int, load func() ([]geoTree.Region, error)) ([]simplifiedRegion, error) {
+	simplifiedCache.Lock()
+	if generation >= 0 && generation != simplifiedCache.generation {
+		simplifiedCache.Unlock()
+		return nil, errStaleLevel
+	}
+	if entry, ok := simplifiedCache.levels[zoom]; ok {
+		simplifiedCache.Unlock()
+		<-entry.done
+		return entry.regions, entry.err
+	}
+	entry := &levelEntry{done: make(chan struct{})}
+	simplifiedCache.levels[zoom] = entry
+	simplifiedCache.Unlock()
+
+	source, err := load()
+	if err == nil {
+		tolerance := ToleranceForZoom(zoom)
+		entry.regions = simplifyRegions(source, tolerance)
+		fmt.Printf("🗺️ Подготовлены геометрии регионов с допуском %g° (уровень %d): %d регионов\n",
+			tolerance, zoom, len(entry.regions))
+	} else {
+		// Ошибку не кэшируем: следующий запрос повторит загрузку
+		entry.err = err
+		simplifiedCache.Lock()
+		if simplifiedCache.levels[zoom] == entry {
+			delete(simplifiedCache.levels, zoom)
+		}
+		simplifiedCache.Unlock()
+	}
+	close(entry.done)
+	return entry.regions, entry.err
+}
+
+// loadSimplifySource загружает исходные геометрии регионов для упрощения
+func loadSimplifySource(collection *mongo.Collection) ([]geoTree.Region, error) {
+	source, err := geoTree.LoadRegions(collection, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(source) == 0 {
+		return nil, fmt.Errorf("коллекция регионов пуста")
+	}
+	return source, nil
+}
+
+// vertexKey вершина как ключ карты
+type vertexKey [2]float64
+
+// simplifyRegions упрощает все регионы с сохранением общих границ.
+// Вершины, в которых меняется набор колец, проходящих через них (стыки трех регионов,
+// начало и конец общей границы), фиксируются; участки между ними упрощаются
+// алгоритмом Дугласа-Пекера в каноническом направлении, поэтому общий участок
+// двух соседей дает одинаковый результат в обоих регионах
+func simplifyRegions(source []geoTree.Region, tolerance float64) []simplifiedRegion {
+	// Сколько колец проходит через каждую вершину
+	usage := make(map[vertexKey]int)
+	for _, region := range source {
+		for _, polygon := range region.Polygons {
+			for _, ring := range polygon {
+				seen := make(map[vertexKey]bool, len(ring))
+				for _, point := range ring {
+					key := vertexKey{point[0], point[1]}
+					if !seen[key] {
+						seen[key] = true
+						usage[key]++
+					}
+				}
+			}
+		}
+	}
+
+	regions := make([]simplifiedRegion, 0, len(source))
+	for _, region := range source {
+		simplified := simplifiedRegion{name: region.Name, bbox: geoMath.EmptyBBox()}
+
+		for _, polygon := range region.Polygons {
+			var rings [][][]float64
+			for i, ring := range polygon {
+				simplified.bbox.Union(geoMath.RingBBox(ring))
+
+				result := ring
+				if tolerance > 0 {
+					result = simplifyRing(ring, tolerance, usage)
+				}
+				if len(result) < 4 {
+					if i == 0 {
+						break // Внешнее кольцо выродилось - полигон слишком мал для этого масштаба
+					}
+					continue
+				}
+				rings = append(rings, result)
+			}
+			if len(rings) > 0 {
+				simplified.polygons = append(simplified.polygons, rings)
+			}
+		}
+
+		// Регион не должен пропасть целиком: оставляем исходный крупнейший полигон
+		if len(simplified.polygons) == 0 && len(region.Polygons) > 0 {
+			simplified.polygons = [][][][]float64{largestPolygon(region.Polygons)}
+		}
+
+		regions = append(regions, simplified)
+	}
+
+	return regions
+}
+
+// simplifyRing упрощает замкнутое кольцо между зафиксированными вершинами
+func simplifyRing(ring [][]float64, tolerance float64, usage map[vertexKey]int) [][]float64 {
+	points := ring[:len(ring)-1]
+	n := len(points)
+	if n < 4 {
+		return ring
+	}
+
+	countAt := func(i int) int {
+		p := points[(i+n)%n]
+		return usage[vertexKey{p[0], p[1]}]
+	}
+
+	var locked []int
+	for i := 0; i < n; i++ {
+		c := countAt(i)
+		if c >= 3 || c != countAt(i-1) || c != countAt(i+1) {
+			locked = append(locked, i)
+		}
+	}
+
+	// Кольцо без стыков (остров или регион целиком внутри другого):
+	// фиксируем наименьшую вершину и самую удаленную от нее
+	if len(locked) < 2 {
+		start := 0
+		if len(locked) == 1 {
+			start = locked[0]
+		} else {
+			for i := 1; i < n; i++ {
+				if lessPoint(points[i], points[start]) {
+					start = i
+				}
+			}
+		}
+		far := farthestFrom(points, start)
+		if far == start {
+			return ring
+		}
+		locked = []int{start, far}
+		if far < start {
+			locked = []int{far, start}
+		}
+	}
+
+	var result [][]float64
+	for k := range locked {
+		from := locked[k]
+		to := locked[(k+1)%len(locked)]
+		if to <= from {
+			to += n
+		}
+
+		run := make([][]float64, 0, to-from+1)
+		for i := from; i <= to; i++ {
+			run = append(run, points[i%n])
+		}
+
+		simplified := simplifyRun(run, tolerance)
+		// Последняя точка участка - первая точка следующего
+		result = append(result, simplified[:len(simplified)-1]...)
+	}
+
+	return append(result, result[0])
+}
+
+// simplifyRun упрощает участок в каноническом направлении (от меньшей концевой точки к большей)
+func simplifyRun(run [][]float64, tolerance float64) [][]float64 {
+	if len(run) <= 2 {
+		return run
+	}
+
+	reversed := lessPoint(run[len(run)-1], run[0])
+	if reversed {
+		run = reversePoints(run)
+	}
+
+	result := douglasPeucker(run, tolerance)
+
+	if reversed {
+		result = reversePoints(result)
+	}
+	return result
+}
+
+// douglasPeucker классическое упрощение ломаной, концы сохраняются
+func douglasPeucker(points [][]float64, tolerance float64) [][]float64 {
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		segment := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDistance, index := 0.0, -1
+		for i := segment[0] + 1; i < segment[1]; i++ {
+			if d := segmentDistance(points[i], points[segment[0]], points[segment[1]]); d > maxDistance {
+				maxDistance, index = d, i
+			}
+		}
+
+		if index >= 0 && maxDistance > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{segment[0], index}, [2]int{index, segment[1]})
+		}
+	}
+
+	result := make([][]float64, 0, len(points))
+	for i, point := range points {
+		if keep[i] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// segmentDistance расстояние от точки до отрезка в градусах (плоское приближение)
+func segmentDistance(p, a, b []float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if lenSq := dx*dx + dy*dy; lenSq > 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/lenSq))
+	}
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
+
+// farthestFrom индекс вершины, наиболее удаленной от points[from]
+func farthestFrom(points [][]float64, from int) int {
+	best, bestDistance := from, -1.0
+	for i, point := range points {
+		d := math.Hypot(point[0]-points[from][0], point[1]-points[from][1])
+		if d > bestDistance || (d == bestDistance && lessPoint(point, points[best])) {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
+
+// largestPolygon полигон с наибольшим прямоугольником внешнего кольца
+func largestPolygon(polygons [][][][]float64) [][][]float64 {
+	best, bestArea := polygons[0], -1.0
+	for _, polygon := range polygons {
+		box := geoMath.RingBBox(polygon[0])
+		if area := (box.MaxX - box.MinX) * (box.MaxY - box.MinY); area > bestArea {
+			best, bestArea = polygon, area
+		}
+	}
+	return best
+}
+
+func lessPoint(a, b []float64) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
+
+func reversePoints(points [][]float64) [][]float64 {
+	result := make([][]float64, len(points))
+	for i, point := range points {
+		result[len(points)-1-i] = point
+	}
+	return result
+}
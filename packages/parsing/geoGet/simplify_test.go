@@ -0,0 +1,119 @@
+package geoGet
+
+import (
+	"math"
+	"testing"
+
+	"project/packages/parsing/geoMath"
+	"project/packages/parsing/geoTree"
+)
+
+// sharedBorder общая граница двух регионов от (10,0) до (10,10): мелкий зигзаг и один крупный выступ
+func sharedBorder() [][]float64 {
+	var border [][]float64
+	for i := 0; i <= 20; i++ {
+		y := float64(i) / 2
+		x := 10.0
+		switch {
+		case i == 10:
+			x += 2.5
+		case i > 0 && i < 20:
+			x += 0.1 * float64(i%2*2-1)
+		}
+		border = append(border, []float64{x, y})
+	}
+	return border
+}
+
+func TestSimplifyRegionsSharedEdge(t *testing.T) {
+	border := sharedBorder()
+
+	// A слева от границы, B справа; обход против часовой стрелки
+	left := [][]float64{{0, 0}}
+	left = append(left, border...)
+	for y := 10.0; y > 0; y -= 0.5 {
+		left = append(left, []float64{0.05 * math.Sin(y), y}) // шум на внешней стороне
+	}
+	left = append(left, []float64{0, 0})
+
+	right := [][]float64{{10, 0}, {20, 0}, {20, 10}}
+	for i := len(border) - 1; i >= 0; i-- {
+		right = append(right, border[i])
+	}
+
+	// C - остров меньше допуска
+	island := [][]float64{{50, 50}, {50.1, 50}, {50.1, 50.05}, {50.1, 50.1}, {50, 50.1}, {50, 50}}
+
+	source := []geoTree.Region{
+		{Name: "A", Polygons: [][][][]float64{{left}}},
+		{Name: "B", Polygons: [][][][]float64{{right}}},
+		{Name: "C", Polygons: [][][][]float64{{island}}},
+	}
+	regions := simplifyRegions(source, 0.5)
+	if len(regions) != 3 {
+		t.Fatalf("получено %d регионов, ожидалось 3", len(regions))
+	}
+
+	// Вершины общей границы (только они лежат в полосе 5 < x < 15)
+	borderVertices := func(region simplifiedRegion) map[vertexKey]bool {
+		vertices := make(map[vertexKey]bool)
+		for _, polygon := range region.polygons {
+			for _, ring := range polygon {
+				for _, point := range ring {
+					if point[0] > 5 && point[0] < 15 {
+						vertices[vertexKey{point[0], point[1]}] = true
+					}
+				}
+			}
+		}
+		return vertices
+	}
+
+	a, b := borderVertices(regions[0]), borderVertices(regions[1])
+	if len(a) != len(b) {
+		t.Errorf("на общей границе %d вершин у A и %d у B", len(a), len(b))
+	}
+	for vertex := range a {
+		if !b[vertex] {
+			t.Errorf("вершина %v общей границы есть у A, но не у B", vertex)
+		}
+	}
+	if len(a) >= len(border) {
+		t.Errorf("общая граница не упрощена: %d вершин из %d", len(a), len(border))
+	}
+	if !a[vertexKey{12.5, 5}] {
+		t.Errorf("крупный выступ границы потерян")
+	}
+
+	for _, region := range regions {
+		if len(region.polygons) == 0 {
+			t.Errorf("регион %s пропал", region.name)
+		}
+		for _, polygon := range region.polygons {
+			for _, ring := range polygon {
+				if len(ring) < 4 {
+					t.Errorf("регион %s: кольцо из %d точек", region.name, len(ring))
+				}
+				if ringSelfIntersects(ring) {
+					t.Errorf("регион %s: кольцо самопересекается", region.name)
+				}
+			}
+		}
+	}
+}
+
+// ringSelfIntersects пересекаются ли несмежные ребра замкнутого кольца
+func ringSelfIntersects(ring [][]float64) bool {
+	n := len(ring) - 1
+	for i := 0; i < n; i++ {
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue // первое и последнее ребра смежны
+			}
+			if geoMath.SegmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
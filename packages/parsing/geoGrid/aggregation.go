@@ -0,0 +1,131 @@
+package geoGrid
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Выражения агрегации MongoDB, вычисляющие ключ ячейки прямо в $group.
+// Ключ - массив из двух целых чисел; CellForKey переводит его в идентификатор и центр ячейки,
+// совпадающие с результатом Cell для той же точки
+
+// pointLon и pointLat долгота и широта GeoJSON точки [lon, lat] по пути point ("$depPoint.coordinates")
+func pointLon(point string) bson.M {
+	return bson.M{"$arrayElemAt": bson.A{point, 0}}
+}
+
+func pointLat(point string) bson.M {
+	return bson.M{"$arrayElemAt": bson.A{point, 1}}
+}
+
+// KeyExpression осевые координаты [q, r] шестиугольника точки: те же проекция и cubeRound, что в Cell
+func (g *HexGrid) KeyExpression(point string) interface{} {
+	lat := bson.M{"$max": bson.A{-maxMercatorLat, bson.M{"$min": bson.A{maxMercatorLat, pointLat(point)}}}}
+	x := bson.M{"$multiply": bson.A{earthRadius * math.Pi / 180, pointLon(point)}}
+	y := bson.M{"$multiply": bson.A{earthRadius, bson.M{"$ln": bson.M{"$tan": bson.M{
+		"$add": bson.A{math.Pi / 4, bson.M{"$multiply": bson.A{lat, math.Pi / 360}}},
+	}}}}}
+
+	// Дробные осевые координаты
+	fractional := bson.M{
+		"q": bson.M{"$divide": bson.A{
+			bson.M{"$subtract": bson.A{bson.M{"$multiply": bson.A{math.Sqrt(3) / 3, "$$x"}}, bson.M{"$divide": bson.A{"$$y", 3}}}},
+			g.size,
+		}},
+		"r": bson.M{"$divide": bson.A{bson.M{"$multiply": bson.A{2.0 / 3, "$$y"}}, g.size}},
+	}
+	s := bson.M{"$subtract": bson.A{bson.M{"$multiply": bson.A{-1, "$$q"}}, "$$r"}}
+
+	// cubeRound: поправляется координата с наибольшей ошибкой округления.
+	// $round округляет половину до четного, а math.Round в cubeRound - от нуля; расхождение
+	// возможно только для точек ровно на середине между центрами и на ключ практически не влияет
+	rounded := bson.M{
+		"rq": bson.M{"$round": bson.A{"$$q"}},
+		"rr": bson.M{"$round": bson.A{"$$r"}},
+		"rs": bson.M{"$round": bson.A{s}},
+	}
+	errors := bson.M{
+		"dq": bson.M{"$abs": bson.M{"$subtract": bson.A{"$$rq", "$$q"}}},
+		"dr": bson.M{"$abs": bson.M{"$subtract": bson.A{"$$rr", "$$r"}}},
+		"ds": bson.M{"$abs": bson.M{"$subtract": bson.A{"$$rs", s}}},
+	}
+	negSum := func(a, b string) bson.M {
+		return bson.M{"$subtract": bson.A{bson.M{"$multiply": bson.A{-1, a}}, b}}
+	}
+	key := bson.M{"$cond": bson.M{
+		"if":   bson.M{"$and": bson.A{bson.M{"$gt": bson.A{"$$dq", "$$dr"}}, bson.M{"$gt": bson.A{"$$dq", "$$ds"}}}},
+		"then": bson.A{negSum("$$rr", "$$rs"), "$$rr"},
+		"else": bson.M{"$cond": bson.M{
+			"if":   bson.M{"$gt": bson.A{"$$dr", "$$ds"}},
+			"then": bson.A{"$$rq", negSum("$$rq", "$$rs")},
+			"else": bson.A{"$$rq", "$$rr"},
+		}},
+	}}
+
+	return bson.M{"$let": bson.M{"vars": bson.M{"x": x, "y": y}, "in": bson.M{
+		"$let": bson.M{"vars": fractional, "in": bson.M{
+			"$let": bson.M{"vars": rounded, "in": bson.M{
+				"$let": bson.M{"vars": errors, "in": key},
+			}},
+		}},
+	}}}
+}
+
+// CellForKey идентификатор q:r и центр шестиугольника по ключу из KeyExpression
+func (g *HexGrid) CellForKey(q, r int64) (string, float64, float64) {
+	centerLat, centerLon := g.center(int(q), int(r))
+	return fmt.Sprintf("%d:%d", q, r), centerLat, centerLon
+}
+
+// geohashBits число бит долготы и широты в geohash длины precision (биты чередуются, начиная с долготы)
+func geohashBits(precision int) (int, int) {
+	total := 5 * precision
+	return (total + 1) / 2, total / 2
+}
+
+// KeyExpression номера интервалов [долготы, широты] точки на сетке geohash: floor((lon+180)/360 * 2^bits)
+func (g *GeohashGrid) KeyExpression(point string) interface{} {
+	lonBits, latBits := geohashBits(g.precision)
+	index := func(value interface{}, offset, span float64, bits int) bson.M {
+		cells := math.Pow(2, float64(bits))
+		return bson.M{"$min": bson.A{cells - 1, bson.M{"$floor": bson.M{
+			"$multiply": bson.A{bson.M{"$add": bson.A{value, offset}}, cells / span},
+		}}}}
+	}
+	return bson.A{
+		index(pointLon(point), 180, 360, lonBits),
+		index(pointLat(point), 90, 180, latBits),
+	}
+}
+
+// CellForKey geohash и центр его ячейки по номерам интервалов из KeyExpression
+func (g *GeohashGrid) CellForKey(lonIndex, latIndex int64) (string, float64, float64) {
+	hash := geohashFromIndexes(lonIndex, latIndex, g.precision)
+	centerLat, centerLon, _ := DecodeGeohash(hash)
+	return hash, centerLat, centerLon
+}
+
+// geohashFromIndexes собирает geohash, чередуя биты номеров интервалов долготы и широты
+func geohashFromIndexes(lonIndex, latIndex int64, precision int) string {
+	lonBits, latBits := geohashBits(precision)
+
+	var hash strings.Builder
+	ch := 0
+	for i := 0; i < 5*precision; i++ {
+		var bit int64
+		if i%2 == 0 {
+			bit = lonIndex >> (lonBits - 1 - i/2) & 1
+		} else {
+			bit = latIndex >> (latBits - 1 - i/2) & 1
+		}
+		ch = ch<<1 | int(bit)
+		if i%5 == 4 {
+			hash.WriteByte(geohashAlphabet[ch])
+			ch = 0
+		}
+	}
+	return hash.String()
+}
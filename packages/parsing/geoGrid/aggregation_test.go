@@ -0,0 +1,189 @@
+package geoGrid
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestGeohashFromIndexes номера интервалов по формуле KeyExpression дают тот же geohash, что EncodeGeohash
+func TestGeohashFromIndexes(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for precision := 1; precision <= 8; precision++ {
+		grid := NewGeohashGrid(precision)
+		lonBits, latBits := geohashBits(precision)
+		for i := 0; i < 1000; i++ {
+			lat := random.Float64()*180 - 90
+			lon := random.Float64()*360 - 180
+
+			lonIndex := int64(math.Floor((lon + 180) * math.Pow(2, float64(lonBits)) / 360))
+			latIndex := int64(math.Floor((lat + 90) * math.Pow(2, float64(latBits)) / 180))
+
+			want, wantLat, wantLon := grid.Cell(lat, lon)
+			got, gotLat, gotLon := grid.CellForKey(lonIndex, latIndex)
+			if got != want || gotLat != wantLat || gotLon != wantLon {
+				t.Fatalf("точка %.6f,%.6f precision %d: %s (%f,%f), ожидалось %s (%f,%f)",
+					lat, lon, precision, got, gotLat, gotLon, want, wantLat, wantLon)
+			}
+		}
+	}
+}
+
+// evalExpression вычисляет выражение агрегации MongoDB для документа с точкой point [lon, lat].
+// Поддерживаются только операторы, которые используют KeyExpression
+func evalExpression(t *testing.T, expr interface{}, point []float64, vars map[string]interface{}) interface{} {
+	t.Helper()
+	num := func(e interface{}) float64 {
+		switch v := evalExpression(t, e, point, vars).(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		}
+		t.Fatalf("ожидалось число: %v", e)
+		return 0
+	}
+
+	switch e := expr.(type) {
+	case float64:
+		return e
+	case int:
+		return float64(e)
+	case string:
+		if strings.HasPrefix(e, "$$") {
+			value, ok := vars[e[2:]]
+			if !ok {
+				t.Fatalf("неизвестная переменная %s", e)
+			}
+			return value
+		}
+		return point
+	case bson.A:
+		values := make([]interface{}, len(e))
+		for i := range e {
+			values[i] = evalExpression(t, e[i], point, vars)
+		}
+		return values
+	case bson.M:
+		if len(e) != 1 {
+			t.Fatalf("выражение должно содержать один оператор: %v", e)
+		}
+		for op, arg := range e {
+			args, _ := arg.(bson.A)
+			switch op {
+			case "$arrayElemAt":
+				return point[int(num(args[1]))]
+			case "$add":
+				return num(args[0]) + num(args[1])
+			case "$subtract":
+				return num(args[0]) - num(args[1])
+			case "$multiply":
+				return num(args[0]) * num(args[1])
+			case "$divide":
+				return num(args[0]) / num(args[1])
+			case "$min":
+				return math.Min(num(args[0]), num(args[1]))
+			case "$max":
+				return math.Max(num(args[0]), num(args[1]))
+			case "$floor":
+				return math.Floor(num(arg))
+			case "$ln":
+				return math.Log(num(arg))
+			case "$tan":
+				return math.Tan(num(arg))
+			case "$abs":
+				return math.Abs(num(arg))
+			case "$round":
+				return math.RoundToEven(num(args[0])) // как в MongoDB: половина до четного
+			case "$gt":
+				return num(args[0]) > num(args[1])
+			case "$and":
+				for _, a := range args {
+					if !evalExpression(t, a, point, vars).(bool) {
+						return false
+					}
+				}
+				return true
+			case "$cond":
+				c := arg.(bson.M)
+				if evalExpression(t, c["if"], point, vars).(bool) {
+					return evalExpression(t, c["then"], point, vars)
+				}
+				return evalExpression(t, c["else"], point, vars)
+			case "$let":
+				let := arg.(bson.M)
+				scope := make(map[string]interface{}, len(vars))
+				for name, value := range vars {
+					scope[name] = value
+				}
+				for name, value := range let["vars"].(bson.M) {
+					scope[name] = evalExpression(t, value, point, vars)
+				}
+				return evalExpression(t, let["in"], point, scope)
+			}
+			t.Fatalf("оператор %s не поддерживается", op)
+		}
+	}
+	t.Fatalf("неизвестное выражение %T", expr)
+	return nil
+}
+
+// evalKey ключ ячейки [a, b] из KeyExpression для точки lat, lon
+func evalKey(t *testing.T, grid Grid, lat, lon float64) (int64, int64) {
+	t.Helper()
+	key, ok := evalExpression(t, grid.KeyExpression("$depPoint.coordinates"), []float64{lon, lat}, nil).([]interface{})
+	if !ok || len(key) != 2 {
+		t.Fatalf("ключ не массив из двух чисел: %v", key)
+	}
+	a, b := key[0].(float64), key[1].(float64)
+	if a != math.Trunc(a) || b != math.Trunc(b) {
+		t.Fatalf("ключ не целый: %v", key)
+	}
+	return int64(a), int64(b)
+}
+
+// TestHexKeyExpression выражение агрегации дает тот же шестиугольник, что Cell
+func TestHexKeyExpression(t *testing.T) {
+	grid := NewHexGrid(HexSizeForZoom(6))
+
+	// Известные ключи: центр сетки и центры соседних шестиугольников
+	for _, fixture := range []struct{ q, r int64 }{{0, 0}, {1, 0}, {0, 1}, {-1, 1}, {7, -3}} {
+		_, lat, lon := grid.CellForKey(fixture.q, fixture.r)
+		if q, r := evalKey(t, grid, lat, lon); q != fixture.q || r != fixture.r {
+			t.Errorf("центр %d:%d: ключ %d:%d", fixture.q, fixture.r, q, r)
+		}
+	}
+
+	random := rand.New(rand.NewSource(1))
+	points := [][2]float64{{55.75, 37.62}, {43.1, 131.9}, {-33.9, 151.2}, {64.5, -170.3}, {89, 0}, {-89, 179.9}}
+	for i := 0; i < 1000; i++ {
+		points = append(points, [2]float64{random.Float64()*170 - 85, random.Float64()*360 - 180})
+	}
+	for _, point := range points {
+		id, lat, lon := grid.Cell(point[0], point[1])
+		gotID, gotLat, gotLon := grid.CellForKey(evalKey(t, grid, point[0], point[1]))
+		if gotID != id || gotLat != lat || gotLon != lon {
+			t.Fatalf("точка %v: %s, ожидалось %s", point, gotID, id)
+		}
+	}
+}
+
+// TestGeohashKeyExpression выражение агрегации дает тот же geohash, что Cell
+func TestGeohashKeyExpression(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	for precision := 1; precision <= 8; precision++ {
+		grid := NewGeohashGrid(precision)
+		for i := 0; i < 200; i++ {
+			lat := random.Float64()*180 - 90
+			lon := random.Float64()*360 - 180
+
+			want, _, _ := grid.Cell(lat, lon)
+			if got, _, _ := grid.CellForKey(evalKey(t, grid, lat, lon)); got != want {
+				t.Fatalf("точка %.6f,%.6f precision %d: %s, ожидалось %s", lat, lon, precision, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package geoGrid
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Grid разбиение плоскости на ячейки для агрегирования точек
+type Grid interface {
+	// Cell возвращает идентификатор ячейки точки и координаты центра ячейки
+	Cell(lat, lon float64) (id string, centerLat, centerLon float64)
+	// KeyExpression выражение агрегации MongoDB: ключ ячейки [a, b] для GeoJSON точки по пути point
+	KeyExpression(point string) interface{}
+	// CellForKey идентификатор и центр ячейки по ключу, вычисленному KeyExpression
+	CellForKey(a, b int64) (id string, centerLat, centerLon float64)
+}
+
+// earthRadius радиус сферы Web Mercator в метрах
+const earthRadius = 6378137.0
+
+// hexPixels примерный радиус шестиугольника на экране в пикселях
+const hexPixels = 20
+
+// maxMercatorLat предел широты проекции Web Mercator
+const maxMercatorLat = 85.05112878
+
+// HexSizeForZoom радиус шестиугольника в метрах проекции для уровня масштаба веб-карты
+func HexSizeForZoom(zoom int) float64 {
+	if zoom < 0 {
+		zoom = 0
+	}
+	metersPerPixel := 2 * math.Pi * earthRadius / 256 / math.Pow(2, float64(zoom))
+	return hexPixels * metersPerPixel
+}
+
+// HexGrid сетка правильных шестиугольников ("острым верхом") в проекции Web Mercator,
+// поэтому на карте ячейки выглядят одинаковыми на любой широте
+type HexGrid struct {
+	size float64
+}
+
+// NewHexGrid создает сетку с радиусом шестиугольника size метров проекции
+func NewHexGrid(size float64) *HexGrid {
+	return &HexGrid{size: size}
+}
+
+// Cell возвращает ячейку точки в осевых координатах q:r
+func (g *HexGrid) Cell(lat, lon float64) (string, float64, float64) {
+	x, y := mercator(lat, lon)
+
+	// Дробные осевые координаты
+	q := (math.Sqrt(3)/3*x - y/3) / g.size
+	r := (2.0 / 3 * y) / g.size
+
+	qi, ri := cubeRound(q, r)
+	centerLat, centerLon := g.center(qi, ri)
+
+	return fmt.Sprintf("%d:%d", qi, ri), centerLat, centerLon
+}
+
+// center центр шестиугольника q:r в градусах
+func (g *HexGrid) center(q, r int) (float64, float64) {
+	cx := g.size * math.Sqrt(3) * (float64(q) + float64(r)/2)
+	cy := g.size * 1.5 * float64(r)
+	return inverseMercator(cx, cy)
+}
+
+// cubeRound округляет дробные осевые координаты до ближайшего шестиугольника
+func cubeRound(q, r float64) (int, int) {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	return int(rq), int(rr)
+}
+
+func mercator(lat, lon float64) (float64, float64) {
+	lat = math.Max(-maxMercatorLat, math.Min(maxMercatorLat, lat))
+	x := earthRadius * lon * math.Pi / 180
+	y := earthRadius * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+	return x, y
+}
+
+func inverseMercator(x, y float64) (float64, float64) {
+	lon := x / earthRadius * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/earthRadius)) - math.Pi/2) * 180 / math.Pi
+	return lat, lon
+}
+
+// geohashAlphabet алфавит base32 для geohash
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeohashPrecisionForZoom длина geohash для уровня масштаба веб-карты
+func GeohashPrecisionForZoom(zoom int) int {
+	switch {
+	case zoom <= 2:
+		return 2
+	case zoom <= 4:
+		return 3
+	case zoom <= 7:
+		return 4
+	case zoom <= 9:
+		return 5
+	case zoom <= 12:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// GeohashGrid сетка ячеек geohash заданной длины
+type GeohashGrid struct {
+	precision int
+}
+
+// NewGeohashGrid создает сетку geohash с precision символами (1..12)
+func NewGeohashGrid(precision int) *GeohashGrid {
+	precision = int(math.Max(1, math.Min(12, float64(precision))))
+	return &GeohashGrid{precision: precision}
+}
+
+// Cell возвращает geohash точки и центр его ячейки
+func (g *GeohashGrid) Cell(lat, lon float64) (string, float64, float64) {
+	hash := EncodeGeohash(lat, lon, g.precision)
+	centerLat, centerLon, _ := DecodeGeohash(hash)
+	return hash, centerLat, centerLon
+}
+
+// EncodeGeohash кодирует точку в geohash заданной длины
+func EncodeGeohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bit, ch := 0, 0
+	even := true
+
+	for hash.Len() < precision {
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if lon >= mid {
+				ch |= 1 << (4 - bit)
+				lonRange[0] = mid
+			} else {
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				latRange[0] = mid
+			} else {
+				latRange[1] = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// DecodeGeohash возвращает центр ячейки geohash
+func DecodeGeohash(hash string) (float64, float64, error) {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+
+	for _, c := range hash {
+		index := strings.IndexRune(geohashAlphabet, c)
+		if index < 0 {
+			return 0, 0, fmt.Errorf("недопустимый символ geohash: %q", c)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := index&(1<<bit) != 0
+			if even {
+				mid := (lonRange[0] + lonRange[1]) / 2
+				if set {
+					lonRange[0] = mid
+				} else {
+					lonRange[1] = mid
+				}
+			} else {
+				mid := (latRange[0] + latRange[1]) / 2
+				if set {
+					latRange[0] = mid
+				} else {
+					latRange[1] = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, nil
+}
@@ -0,0 +1,292 @@
+package geoIndex
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"project/packages/parsing/geoMath"
+)
+
+// NormalizeAntimeridian разрезает полигоны, пересекающие 180-й меридиан
+// (ребро со скачком долготы больше 180° или долготы вне [-180, 180]),
+// на части по обе стороны меридиана: западная часть заканчивается на 180, восточная начинается с -180.
+// Исходные координаты не изменяются. Возвращает полигоны и признак того, что геометрия была разрезана
+func NormalizeAntimeridian(polygons [][][][]float64) ([][][][]float64, bool, error) {
+	var result [][][][]float64
+	split := false
+
+	for _, polygon := range polygons {
+		if !crossesAntimeridian(polygon) {
+			result = append(result, polygon)
+			continue
+		}
+
+		parts, err := splitPolygon(polygon)
+		if err != nil {
+			return nil, false, err
+		}
+		result = append(result, parts...)
+		split = true
+	}
+
+	return result, split, nil
+}
+
+// crossesAntimeridian есть ли у полигона ребро через 180° или долготы вне диапазона
+func crossesAntimeridian(polygon [][][]float64) bool {
+	for _, ring := range polygon {
+		for i, point := range ring {
+			if point[0] > 180 || point[0] < -180 {
+				return true
+			}
+			if i > 0 && math.Abs(point[0]-ring[i-1][0]) > 180 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwrapRing делает долготы кольца непрерывными, добавляя ±360 при скачках
+func unwrapRing(ring [][]float64) [][]float64 {
+	result := make([][]float64, len(ring))
+	offset := 0.0
+	for i, point := range ring {
+		if i > 0 {
+			if delta := point[0] - ring[i-1][0]; delta > 180 {
+				offset -= 360
+			} else if delta < -180 {
+				offset += 360
+			}
+		}
+		result[i] = []float64{point[0] + offset, point[1]}
+	}
+	return result
+}
+
+// splitPolygon разрезает полигон по меридиану ±180 и возвращает части в диапазоне [-180, 180]
+func splitPolygon(polygon [][][]float64) ([][][][]float64, error) {
+	outer := unwrapRing(polygon[0])
+	if len(outer) < 4 {
+		return nil, fmt.Errorf("кольцо из %d точек", len(outer))
+	}
+
+	bbox := geoMath.RingBBox(outer)
+	if bbox.MaxX-bbox.MinX >= 360 {
+		return nil, fmt.Errorf("полигон охватывает все долготы")
+	}
+
+	// Меридиан разреза и сдвиг для части за его пределами
+	meridian, shift := 180.0, -360.0
+	if bbox.MaxX <= 180 {
+		meridian, shift = -180.0, 360.0
+	}
+
+	west, east, err := splitRing(outer, meridian)
+	if err != nil {
+		return nil, err
+	}
+
+	// beyond - часть лежит за меридианом разреза и требует сдвига
+	var parts [][][][]float64
+	var beyond []bool
+	for _, ring := range west {
+		parts = append(parts, [][][]float64{ring})
+		beyond = append(beyond, shift > 0)
+	}
+	for _, ring := range east {
+		parts = append(parts, [][][]float64{ring})
+		beyond = append(beyond, shift < 0)
+	}
+
+	// Дыры относим к той части, в которую попадает их первая вершина
+	for _, hole := range polygon[1:] {
+		hole = unwrapRing(hole)
+		holeBox := geoMath.RingBBox(hole)
+		if holeBox.MaxX-holeBox.MinX >= 180 {
+			return nil, fmt.Errorf("дыра пересекает 180-й меридиан")
+		}
+		// Приводим дыру к диапазону долгот внешнего кольца
+		for holeBox.MinX > bbox.MaxX {
+			hole = shiftRing(hole, -360)
+			holeBox = geoMath.RingBBox(hole)
+		}
+		for holeBox.MaxX < bbox.MinX {
+			hole = shiftRing(hole, 360)
+			holeBox = geoMath.RingBBox(hole)
+		}
+		if holeBox.MinX < meridian && holeBox.MaxX > meridian {
+			return nil, fmt.Errorf("дыра пересекает 180-й меридиан")
+		}
+
+		for i, part := range parts {
+			if geoMath.PointInRing(hole[0][0], hole[0][1], part[0]) {
+				parts[i] = append(parts[i], hole)
+				break
+			}
+		}
+	}
+
+	// Возвращаем часть за меридианом в диапазон [-180, 180]
+	for i, part := range parts {
+		if !beyond[i] {
+			continue
+		}
+		for j, ring := range part {
+			part[j] = shiftRing(ring, shift)
+		}
+	}
+
+	return parts, nil
+}
+
+// shiftRing сдвигает кольцо по долготе
+func shiftRing(ring [][]float64, shift float64) [][]float64 {
+	result := make([][]float64, len(ring))
+	for i, point := range ring {
+		result[i] = []float64{point[0] + shift, point[1]}
+	}
+	return result
+}
+
+// ringChain участок кольца по одну сторону меридиана от пересечения до пересечения
+type ringChain struct {
+	east   bool
+	points [][]float64
+	start  int // Номер точки пересечения в начале участка
+	end    int // Номер точки пересечения в конце участка
+}
+
+// splitRing разрезает непрерывное замкнутое кольцо вертикальной линией x = meridian.
+// Точки пересечения вдоль меридиана попарно ограничивают отрезки внутри кольца,
+// по этим отрезкам участки каждой стороны сшиваются в замкнутые кольца
+func splitRing(ring [][]float64, meridian float64) (west, east [][][]float64, err error) {
+	points := ring[:len(ring)-1]
+	n := len(points)
+	side := func(p []float64) bool { return p[0] > meridian }
+
+	// Начинаем с первой смены стороны
+	first := -1
+	for i := 0; i < n; i++ {
+		if side(points[i]) != side(points[(i+n-1)%n]) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		if side(points[0]) {
+			return nil, [][][]float64{ring}, nil
+		}
+		return [][][]float64{ring}, nil, nil
+	}
+
+	var crossings [][]float64
+	intersect := func(a, b []float64) int {
+		t := (meridian - a[0]) / (b[0] - a[0])
+		crossings = append(crossings, []float64{meridian, a[1] + t*(b[1]-a[1])})
+		return len(crossings) - 1
+	}
+
+	var chains []*ringChain
+	current := &ringChain{
+		east:  side(points[first]),
+		start: intersect(points[(first+n-1)%n], points[first]),
+	}
+	current.points = append(current.points, crossings[current.start])
+
+	for k := 0; k < n; k++ {
+		point := points[(first+k)%n]
+		next := points[(first+k+1)%n]
+		current.points = append(current.points, point)
+
+		if side(next) != current.east {
+			if k == n-1 {
+				// Последнее ребро - то, с пересечения на котором начали обход
+				current.end = 0
+			} else {
+				current.end = intersect(point, next)
+			}
+			current.points = append(current.points, crossings[current.end])
+			chains = append(chains, current)
+			current = &ringChain{east: side(next), start: current.end, points: [][]float64{crossings[current.end]}}
+		}
+	}
+
+	if len(crossings)%2 != 0 {
+		return nil, nil, fmt.Errorf("нечетное число пересечений с меридианом")
+	}
+
+	// Пары точек пересечения, соседних вдоль меридиана
+	order := make([]int, len(crossings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return crossings[order[a]][1] < crossings[order[b]][1] })
+	partner := make(map[int]int, len(order))
+	for i := 0; i+1 < len(order); i += 2 {
+		partner[order[i]] = order[i+1]
+		partner[order[i+1]] = order[i]
+	}
+
+	for _, eastSide := range []bool{false, true} {
+		byStart := make(map[int]*ringChain)
+		var sideChains []*ringChain
+		for _, chain := range chains {
+			if chain.east == eastSide {
+				byStart[chain.start] = chain
+				sideChains = append(sideChains, chain)
+			}
+		}
+
+		used := make(map[*ringChain]bool)
+		for _, chain := range sideChains {
+			if used[chain] {
+				continue
+			}
+
+			var rebuilt [][]float64
+			for c := chain; ; {
+				used[c] = true
+				rebuilt = appendDistinct(rebuilt, c.points...)
+				next, ok := byStart[partner[c.end]]
+				if !ok {
+					return nil, nil, fmt.Errorf("не удалось сшить кольцо по меридиану")
+				}
+				if next == chain {
+					break
+				}
+				if used[next] {
+					return nil, nil, fmt.Errorf("не удалось сшить кольцо по меридиану")
+				}
+				c = next
+			}
+			rebuilt = appendDistinct(rebuilt, rebuilt[0])
+
+			if len(rebuilt) < 4 {
+				continue
+			}
+			if eastSide {
+				east = append(east, rebuilt)
+			} else {
+				west = append(west, rebuilt)
+			}
+		}
+	}
+
+	return west, east, nil
+}
+
+// appendDistinct добавляет точки, пропуская повторы подряд
+func appendDistinct(ring [][]float64, points ...[]float64) [][]float64 {
+	for _, point := range points {
+		if len(ring) > 0 {
+			last := ring[len(ring)-1]
+			if last[0] == point[0] && last[1] == point[1] {
+				continue
+			}
+		}
+		ring = append(ring, point)
+	}
+	return ring
+}
@@ -0,0 +1,129 @@
+package geoIndex
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"project/packages/parsing/geoMath"
+)
+
+// checkParts проверяет, что части замкнуты и лежат в диапазоне долгот [-180, 180]
+func checkParts(t *testing.T, polygons [][][][]float64) {
+	t.Helper()
+	for p, polygon := range polygons {
+		for r, ring := range polygon {
+			first, last := ring[0], ring[len(ring)-1]
+			if first[0] != last[0] || first[1] != last[1] {
+				t.Errorf("часть %d, кольцо %d не замкнуто", p, r)
+			}
+			for _, point := range ring {
+				if point[0] < -180 || point[0] > 180 {
+					t.Errorf("часть %d, кольцо %d: долгота вне диапазона: %v", p, r, point)
+				}
+			}
+		}
+	}
+}
+
+// copyPolygons глубокая копия для проверки неизменности входа
+func copyPolygons(polygons [][][][]float64) [][][][]float64 {
+	result := make([][][][]float64, len(polygons))
+	for p, polygon := range polygons {
+		for _, ring := range polygon {
+			copied := make([][]float64, len(ring))
+			for i, point := range ring {
+				copied[i] = append([]float64{}, point...)
+			}
+			result[p] = append(result[p], copied)
+		}
+	}
+	return result
+}
+
+func TestNormalizeAntimeridianChukotka(t *testing.T) {
+	// Кольцо, как у Чукотки: от 170° в.д. до 175° з.д.
+	input := [][][][]float64{{{{170, 65}, {-175, 65}, {-175, 70}, {170, 70}, {170, 65}}}}
+	original := copyPolygons(input)
+
+	result, split, err := NormalizeAntimeridian(input)
+	if err != nil {
+		t.Fatalf("NormalizeAntimeridian: %v", err)
+	}
+	if !split || len(result) != 2 {
+		t.Fatalf("ожидалось 2 части, получено %d (split=%v)", len(result), split)
+	}
+	checkParts(t, result)
+
+	var area float64
+	var west, east bool
+	for _, polygon := range result {
+		area += math.Abs(geoMath.PlanarArea(polygon[0]))
+		for _, point := range polygon[0] {
+			if point[0] == 180 {
+				west = true
+			}
+			if point[0] == -180 {
+				east = true
+			}
+		}
+	}
+	if math.Abs(area-75) > 1e-9 {
+		t.Errorf("площадь частей %v, ожидалось 75", area)
+	}
+	if !west || !east {
+		t.Errorf("части должны примыкать к 180 и -180 без сдвига: %v", result)
+	}
+	if !reflect.DeepEqual(input, original) {
+		t.Errorf("входные координаты изменены: %v", input)
+	}
+}
+
+func TestNormalizeAntimeridianHoles(t *testing.T) {
+	input := [][][][]float64{{
+		{{170, 60}, {-170, 60}, {-170, 70}, {170, 70}, {170, 60}},
+		{{172, 62}, {175, 62}, {175, 65}, {172, 65}, {172, 62}},
+		{{-178, 62}, {-175, 62}, {-175, 65}, {-178, 65}, {-178, 62}},
+	}}
+
+	result, _, err := NormalizeAntimeridian(input)
+	if err != nil {
+		t.Fatalf("NormalizeAntimeridian: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("ожидалось 2 части, получено %d", len(result))
+	}
+	checkParts(t, result)
+
+	for _, polygon := range result {
+		if len(polygon) != 2 {
+			t.Fatalf("у каждой части должна быть одна дыра: %v", polygon)
+		}
+		hole := polygon[1]
+		if !geoMath.PointInRing(hole[0][0], hole[0][1], polygon[0]) {
+			t.Errorf("дыра %v вне своей части %v", hole, polygon[0])
+		}
+	}
+}
+
+func TestNormalizeAntimeridianOddCrossings(t *testing.T) {
+	// Кольцо вокруг полюса пересекает 180-й меридиан один раз и не делится на части
+	input := [][][][]float64{{{{170, 80}, {-170, 80}, {-90, 80}, {0, 80}, {90, 80}, {170, 80}}}}
+	if _, _, err := NormalizeAntimeridian(input); err == nil {
+		t.Fatal("ожидалась ошибка для кольца с нечетным числом пересечений")
+	}
+}
+
+func TestNormalizeAntimeridianUntouched(t *testing.T) {
+	// Полигон с вершиной ровно на 180 не пересекает меридиан и не изменяется
+	input := [][][][]float64{{{{175, 60}, {180, 60}, {180, 65}, {175, 65}, {175, 60}}}}
+	original := copyPolygons(input)
+
+	result, split, err := NormalizeAntimeridian(input)
+	if err != nil || split {
+		t.Fatalf("NormalizeAntimeridian: split=%v err=%v", split, err)
+	}
+	if !reflect.DeepEqual(result, original) || !reflect.DeepEqual(input, original) {
+		t.Errorf("геометрия изменена: %v", result)
+	}
+}
@@ -0,0 +1,235 @@
+package geoIndex
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxZipEntrySize предельный размер файла внутри ZIP архива границ
+const maxZipEntrySize = 512 << 20
+
+// SourceFile файл набора границ: путь относительно корня набора ("MOW.geojson",
+// "districts/moscow.geojson", "population.csv") и содержимое
+type SourceFile struct {
+	Name string
+	Data []byte
+}
+
+// Роль файла в наборе границ
+const (
+	fileKindRegions   = "regions"
+	fileKindDistricts = "districts"
+	fileKindTable     = "table"
+)
+
+// FileReport результат обработки одного файла набора границ
+type FileReport struct {
+	Name     string   `bson:"name" json:"name"`
+	Kind     string   `bson:"kind" json:"kind"`
+	Features int      `bson:"features" json:"features"`
+	Loaded   int      `bson:"loaded" json:"loaded"`
+	Repaired int      `bson:"repaired" json:"repaired"` // Загружено после автоматического исправления геометрии
+	Errors   []string `bson:"errors,omitempty" json:"errors,omitempty"`
+	Failed   bool     `bson:"failed,omitempty" json:"failed,omitempty"` // Файл не разобран целиком
+	// FeatureReports результат проверки каждого объекта файла
+	FeatureReports []FeatureReport `bson:"featureReports,omitempty" json:"featureReports,omitempty"`
+}
+
+// BoundaryReport отчет о проверке набора границ
+type BoundaryReport struct {
+	Files     []FileReport `bson:"files" json:"files"`
+	Regions   int          `bson:"regions" json:"regions"`
+	Districts int          `bson:"districts" json:"districts"`
+	Repaired  int          `bson:"repaired" json:"repaired"`
+	Errors    int          `bson:"errors" json:"errors"`
+	Warnings  []string     `bson:"warnings,omitempty" json:"warnings,omitempty"`
+}
+
+// FailedFiles число файлов, которые не удалось разобрать
+func (r BoundaryReport) FailedFiles() int {
+	failed := 0
+	for _, file := range r.Files {
+		if file.Failed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// BoundarySet подготовленные к вставке документы регионов и районов
+// с таблицами атрибутов из того же набора
+type BoundarySet struct {
+	Regions          []interface{}
+	Districts        []interface{}
+	Population       []byte
+	FederalDistricts []byte
+	Report           BoundaryReport
+}
+
+// fileKind роль файла по пути внутри набора; пустая строка - файл не относится к границам
+func fileKind(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	dirs := strings.Split(path.Dir(name), "/")
+	for _, dir := range dirs {
+		if dir == AirspaceDirName || dir == GazetteerDirName || strings.HasPrefix(dir, "__MACOSX") {
+			return ""
+		}
+	}
+
+	base := path.Base(name)
+	if base == PopulationFileName || base == FederalDistrictsFileName {
+		return fileKindTable
+	}
+	if strings.HasPrefix(base, ".") {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(base)) {
+	case ".geojson", ".json":
+		for _, dir := range dirs {
+			if dir == DistrictsDirName {
+				return fileKindDistricts
+			}
+		}
+		return fileKindRegions
+	}
+	return ""
+}
+
+// ReadDirFiles читает набор границ из папки: GeoJSON регионов, подпапку районов и таблицы атрибутов
+func ReadDirFiles(dir string) ([]SourceFile, error) {
+	var files []SourceFile
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		if info.IsDir() {
+			if filePath != dir && (info.Name() == AirspaceDirName || info.Name() == GazetteerDirName) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fileKind(relative) == "" {
+			return nil
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения файла %s: %v", relative, err)
+		}
+		files = append(files, SourceFile{Name: relative, Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// ReadZip читает набор границ из ZIP архива
+func ReadZip(data []byte) ([]SourceFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ZIP архива: %v", err)
+	}
+
+	var files []SourceFile
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || fileKind(entry.Name) == "" {
+			continue
+		}
+		if entry.UncompressedSize64 > maxZipEntrySize {
+			return nil, fmt.Errorf("файл %s слишком большой", entry.Name)
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения %s: %v", entry.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(reader, maxZipEntrySize))
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения %s: %v", entry.Name, err)
+		}
+		files = append(files, SourceFile{Name: entry.Name, Data: content})
+	}
+	return files, nil
+}
+
+// BuildBoundarySet разбирает и проверяет файлы набора границ. Некорректные объекты
+// пропускаются и попадают в отчет; субъекты районов определяются по регионам этого же набора
+func BuildBoundarySet(files []SourceFile) *BoundarySet {
+	set := &BoundarySet{}
+	var districtFiles []SourceFile
+
+	for _, file := range files {
+		switch fileKind(file.Name) {
+		case fileKindRegions:
+			report := FileReport{Name: file.Name, Kind: fileKindRegions}
+			var geoJSONFile GeoJSONFile
+			if err := json.Unmarshal(file.Data, &geoJSONFile); err != nil {
+				report.Failed = true
+				report.Errors = append(report.Errors, fmt.Sprintf("ошибка парсинга JSON: %v", err))
+				log.Printf("⚠️ Ошибка обработки файла %s: %v", file.Name, err)
+			} else {
+				processGeoJSON(file.Name, &geoJSONFile, &set.Regions, &report)
+			}
+			set.Report.Files = append(set.Report.Files, report)
+		case fileKindDistricts:
+			districtFiles = append(districtFiles, file)
+		case fileKindTable:
+			if path.Base(file.Name) == PopulationFileName {
+				set.Population = file.Data
+			} else {
+				set.FederalDistricts = file.Data
+			}
+			set.Report.Files = append(set.Report.Files, FileReport{Name: file.Name, Kind: fileKindTable})
+		}
+	}
+
+	// Районы связываются с субъектами, поэтому разбираются после всех регионов
+	if len(districtFiles) > 0 {
+		fmt.Printf("🏘️ Загружаем муниципальные районы: %d файлов\n", len(districtFiles))
+		shapes := subjectShapes(set.Regions)
+		for _, file := range districtFiles {
+			report := FileReport{Name: file.Name, Kind: fileKindDistricts}
+			var geoJSONFile GeoJSONFile
+			if err := json.Unmarshal(file.Data, &geoJSONFile); err != nil {
+				report.Failed = true
+				report.Errors = append(report.Errors, fmt.Sprintf("ошибка парсинга JSON: %v", err))
+				log.Printf("⚠️ Ошибка обработки файла %s: %v", file.Name, err)
+			} else {
+				processDistricts(&geoJSONFile, shapes, &set.Districts, &report)
+			}
+			set.Report.Files = append(set.Report.Files, report)
+		}
+	}
+
+	// Одинаковые названия делают поиск региона неоднозначным
+	seen := make(map[string]bool)
+	for _, doc := range set.Regions {
+		name, _ := doc.(bson.M)["name"].(string)
+		if seen[name] {
+			set.Report.Warnings = append(set.Report.Warnings, fmt.Sprintf("регион %s встречается несколько раз", name))
+		}
+		seen[name] = true
+	}
+
+	set.Report.Regions = len(set.Regions)
+	set.Report.Districts = len(set.Districts)
+	for _, file := range set.Report.Files {
+		set.Report.Errors += len(file.Errors)
+		set.Report.Repaired += file.Repaired
+	}
+	return set
+}
@@ -0,0 +1,140 @@
+package geoIndex
+
+import (
+	"fmt"
+	"log"
+
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// DistrictsDirName подпапка geojsonFiles с границами муниципальных районов
+	DistrictsDirName = "districts"
+	// DistrictsCollectionName коллекция муниципальных районов
+	DistrictsCollectionName = "districtsGeo"
+	// AirspaceDirName подпапка geojsonFiles с зонами ограничения полетов (пакет airspace)
+	AirspaceDirName = "airspace"
+	// GazetteerDirName подпапка geojsonFiles со справочником населенных пунктов (пакет gazetteer)
+	GazetteerDirName = "gazetteer"
+	// parentSampleCount число вершин района, по которым голосованием определяется субъект
+	parentSampleCount = 7
+)
+
+// Поля properties, в которых может быть указан родительский субъект
+var parentKeys = []string{"parent", "is_in:region", "is_in:state", "addr:region"}
+
+// subjectShape субъект РФ с геометрией для определения родителя района
+type subjectShape struct {
+	name     string
+	polygons [][][][]float64
+}
+
+// processDistricts разбирает муниципальные районы (admin_level 6/8) одного GeoJSON файла
+// и связывает их с субъектами
+func processDistricts(geoJSONFile *GeoJSONFile, shapes []subjectShape, districtsToInsert *[]interface{}, report *FileReport) {
+	report.Features = len(geoJSONFile.Features)
+
+	for i, feature := range geoJSONFile.Features {
+		districtName := FeatureName(feature.Properties, i)
+		featureReport := FeatureReport{Index: i, Name: districtName}
+
+		mongoGeometry, err := convertGeoJSONGeometry(feature.Geometry)
+		if err != nil || mongoGeometry == nil {
+			log.Printf("⚠️ Ошибка конвертации геометрии для района %s: %v", districtName, err)
+			report.reject(featureReport, fmt.Sprintf("ошибка конвертации геометрии: %v", err))
+			continue
+		}
+
+		repairs, err := validateGeometry(mongoGeometry)
+		if err != nil {
+			log.Printf("⚠️ Невалидная геометрия для района %s: %v", districtName, err)
+			report.reject(featureReport, err.Error())
+			continue
+		}
+
+		parent := extractParentName(feature.Properties)
+		if parent == "" {
+			parent = findParentSubject(geometryPolygons(mongoGeometry), shapes)
+		}
+		if parent == "" {
+			log.Printf("⚠️ Не удалось определить субъект для района %s", districtName)
+			report.reject(featureReport, "не удалось определить субъект")
+			continue
+		}
+
+		*districtsToInsert = append(*districtsToInsert, bson.M{
+			"name":       districtName,
+			"parent":     parent,
+			"adminLevel": feature.Properties["admin_level"],
+			"geometry":   mongoGeometry,
+		})
+		report.accept(featureReport, repairs)
+	}
+}
+
+// extractParentName извлекает название субъекта из properties района
+func extractParentName(properties map[string]interface{}) string {
+	for _, key := range parentKeys {
+		if value, ok := properties[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// subjectShapes извлекает геометрию субъектов из подготовленных к вставке документов
+func subjectShapes(subjects []interface{}) []subjectShape {
+	var shapes []subjectShape
+	for _, doc := range subjects {
+		subject, ok := doc.(bson.M)
+		if !ok {
+			continue
+		}
+		name, _ := subject["name"].(string)
+		geometry, _ := subject["geometry"].(bson.M)
+		shapes = append(shapes, subjectShape{name: name, polygons: geometryPolygons(geometry)})
+	}
+	return shapes
+}
+
+// geometryPolygons приводит Polygon и MultiPolygon к списку полигонов
+func geometryPolygons(geometry bson.M) [][][][]float64 {
+	switch coords := geometry["coordinates"].(type) {
+	case [][][]float64:
+		return [][][][]float64{coords}
+	case [][][][]float64:
+		return coords
+	default:
+		return nil
+	}
+}
+
+// findParentSubject определяет субъект голосованием по вершинам внешнего кольца района
+func findParentSubject(polygons [][][][]float64, shapes []subjectShape) string {
+	votes := make(map[string]int)
+	best := ""
+
+	for _, polygon := range polygons {
+		if len(polygon) == 0 || len(polygon[0]) == 0 {
+			continue
+		}
+		ring := polygon[0]
+		step := max(1, len(ring)/parentSampleCount)
+		for i := 0; i < len(ring); i += step {
+			x, y := ring[i][0], ring[i][1]
+			for _, shape := range shapes {
+				if geoMath.PointInMultiPolygon(x, y, shape.polygons) {
+					votes[shape.name]++
+					if votes[shape.name] > votes[best] {
+						best = shape.name
+					}
+					break
+				}
+			}
+		}
+	}
+
+	return best
+}
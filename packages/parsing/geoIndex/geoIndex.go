@@ -1,14 +1,13 @@
 package geoIndex
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -31,116 +30,71 @@ type GeoJSONFile struct {
 	CRS      interface{}      `json:"crs,omitempty"`
 }
 
-// LoadRegionsToMongo загружает регионы из множества GeoJSON файлов в MongoDB
-func LoadRegionsToMongo(regionsCollection *mongo.Collection) error {
-
+// RegionsDir возвращает путь к папке geojsonFiles рядом с исполняемым файлом
+func RegionsDir() (string, error) {
 	// Получаем путь к директории исполняемого файла
 	exePath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("ошибка получения пути исполняемого файла: %v", err)
+		return "", fmt.Errorf("ошибка получения пути исполняемого файла: %v", err)
 	}
 
 	// Строим путь к папке regions-new относительно исполняемого файла
-	regionsDir := filepath.Join(filepath.Dir(exePath), "geojsonFiles")
+	return filepath.Join(filepath.Dir(exePath), "geojsonFiles"), nil
+}
+
+// LoadRegionsToMongo загружает регионы и районы из папки geojsonFiles как новую версию
+// набора границ и делает ее активной. Рабочие коллекции заменяются только после
+// успешной проверки и построения 2dsphere индексов
+func LoadRegionsToMongo(regionsCollection *mongo.Collection) error {
+
+	regionsDir, err := RegionsDir()
+	if err != nil {
+		return err
+	}
 
 	if regionsDir == "" {
 		log.Println("не найден путь к файлам GEOJSON")
 		return fmt.Errorf("не найден путь к файлам GEOJSON ")
 	}
 
-	ctx := context.Background()
-
 	fmt.Printf("🗺️ Загружаем регионы из папки: %s\n", regionsDir)
 
-	// Получаем список всех GeoJSON файлов в папке
-	files, err := getGeoJSONFiles(regionsDir)
+	files, err := ReadDirFiles(regionsDir)
 	if err != nil {
 		return fmt.Errorf("ошибка чтения папки: %v", err)
 	}
 
-	fmt.Printf("📁 Найдено %d GeoJSON файлов\n", len(files))
+	fmt.Printf("📁 Найдено %d файлов набора границ\n", len(files))
 
-	// Очищаем коллекцию
-	_, err = regionsCollection.DeleteMany(ctx, bson.M{})
-	if err != nil {
-		return fmt.Errorf("ошибка очистки коллекции: %v", err)
-	}
-
-	var regionsToInsert []interface{}
-	var totalLoadedCount int
-	var totalErrorCount int
+	set := BuildBoundarySet(files)
 
-	// Обрабатываем каждый файл
-	for _, file := range files {
-
-		loadedCount, errorCount, err := processGeoJSONFile(file, &regionsToInsert)
-		if err != nil {
-			log.Printf("⚠️ Ошибка обработки файла %s: %v", file, err)
-			totalErrorCount++
-			continue
+	fmt.Printf("\n📊 ИТОГИ:\n")
+	fmt.Printf("   Успешно обработано: %d регионов, %d районов\n", set.Report.Regions, set.Report.Districts)
+	fmt.Printf("   Исправлена геометрия: %d объектов\n", set.Report.Repaired)
+	fmt.Printf("   С ошибками: %d объектов\n", set.Report.Errors)
+
+	// Как и при загрузке через API: набор с неразобранными файлами не становится рабочим
+	if failed := set.Report.FailedFiles(); failed > 0 {
+		for _, file := range set.Report.Files {
+			if file.Failed {
+				fmt.Printf("   ❌ %s: %s\n", file.Name, strings.Join(file.Errors, "; "))
+			}
 		}
-
-		totalLoadedCount += loadedCount
-		totalErrorCount += errorCount
-
+		return fmt.Errorf("набор границ не прошел проверку: файлов с ошибками %d", failed)
 	}
-
-	fmt.Printf("\n📊 ИТОГИ:\n")
-	fmt.Printf("   Успешно обработано: %d регионов\n", totalLoadedCount)
-	fmt.Printf("   С ошибками: %d регионов\n", totalErrorCount)
-
-	if totalLoadedCount == 0 {
+	if len(set.Regions) == 0 {
 		return fmt.Errorf("не удалось загрузить ни одного региона")
 	}
 
-	// Вставляем регионы
-	fmt.Printf("\n💾 Вставляем %d регионов в MongoDB...\n", len(regionsToInsert))
-	result, err := regionsCollection.InsertMany(ctx, regionsToInsert)
+	version, err := SaveVersion(regionsCollection.Database(), DirVersionName(), VersionSourceDir, set)
 	if err != nil {
-		return fmt.Errorf("ошибка вставки регионов: %v", err)
+		return err
 	}
-	fmt.Printf("✅ В MongoDB загружено %d регионов\n", len(result.InsertedIDs))
-
-	// СОЗДАЕМ 2DSPHERE ИНДЕКС!
-	fmt.Println("🔧 Создаем 2dsphere индекс...")
-
-	indexModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "geometry", Value: "2dsphere"},
-		},
-	}
-
-	// Создаем индекс с таймаутом
-	ctxIndex, cancel := context.WithTimeout(ctx, 60*time.Second)
-	defer cancel()
-
-	indexName, err := regionsCollection.Indexes().CreateOne(ctxIndex, indexModel)
-	if err != nil {
-		return fmt.Errorf("ошибка создания 2dsphere индекса: %v", err)
-	}
-
-	fmt.Printf("✅ 2dsphere индекс создан успешно: %s\n", indexName)
-
-	// Проверяем что индекс создался
-	cursor, err := regionsCollection.Indexes().List(ctx)
-	if err == nil {
-		var indexes []bson.M
-		cursor.All(ctx, &indexes)
-		fmt.Printf("🔍 Создано индексов в коллекции: %d\n", len(indexes))
-		for _, index := range indexes {
-			if key, ok := index["key"].(bson.M); ok {
-				if _, hasGeo := key["geometry"]; hasGeo {
-					fmt.Printf("✅ Найден 2dsphere индекс: %v\n", index["name"])
-				}
-			}
-		}
-	}
-
-	return nil
+	return ActivateVersion(regionsCollection, version.Name)
 }
 
-// getGeoJSONFiles возвращает список всех GeoJSON файлов в папке
-func getGeoJSONFiles(dirPath string) ([]string, error) {
+// GetGeoJSONFiles возвращает список всех GeoJSON файлов в папке
+func GetGeoJSONFiles(dirPath string) ([]string, error) {
 	var files []string
 
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
@@ -148,8 +102,12 @@ func getGeoJSONFiles(dirPath string) ([]string, error) {
 			return err
 		}
 
-		// Пропускаем директории
+		// Пропускаем директории, папки районов, зон ограничения и населенных пунктов загружаются отдельно
 		if info.IsDir() {
+			if path != dirPath && (info.Name() == DistrictsDirName || info.Name() == AirspaceDirName ||
+				info.Name() == GazetteerDirName) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -169,70 +127,81 @@ func getGeoJSONFiles(dirPath string) ([]string, error) {
 	return files, nil
 }
 
-// processGeoJSONFile обрабатывает один GeoJSON файл
-func processGeoJSONFile(filePath string, regionsToInsert *[]interface{}) (int, int, error) {
-	// Читаем GeoJSON файл
+// ReadGeoJSONFile читает и разбирает один GeoJSON файл
+func ReadGeoJSONFile(filePath string) (*GeoJSONFile, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return 0, 0, fmt.Errorf("ошибка чтения файла: %v", err)
+		return nil, fmt.Errorf("ошибка чтения файла: %v", err)
 	}
 
 	var geoJSONFile GeoJSONFile
-	err = json.Unmarshal(data, &geoJSONFile)
-	if err != nil {
-		return 0, 0, fmt.Errorf("ошибка парсинга JSON: %v", err)
+	if err := json.Unmarshal(data, &geoJSONFile); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON: %v", err)
 	}
 
-	var loadedCount int
-	var errorCount int
+	return &geoJSONFile, nil
+}
+
+// FeatureName возвращает название региона: "official_name:ru" или альтернативные поля
+func FeatureName(properties map[string]interface{}, index int) string {
+	if regionName := extractOfficialName(properties); regionName != "" {
+		return regionName
+	}
+	return extractRegionName(properties, index)
+}
+
+// processGeoJSON разбирает регионы одного GeoJSON файла набора границ
+func processGeoJSON(fileName string, geoJSONFile *GeoJSONFile, regionsToInsert *[]interface{}, report *FileReport) {
+	report.Features = len(geoJSONFile.Features)
 
 	// Обрабатываем каждую фичу в файле
 	for i, feature := range geoJSONFile.Features {
 		// Извлекаем название региона из поля "official_name:ru"
-		regionName := extractOfficialName(feature.Properties)
+		regionName := FeatureName(feature.Properties, i)
+		featureReport := FeatureReport{Index: i, Name: regionName}
 		if regionName == "" {
-			// Если нет официального названия, используем альтернативные
-			regionName = extractRegionName(feature.Properties, i)
-			if regionName == "" {
-				log.Printf("⚠️ Не найдено название региона в файле %s", filepath.Base(filePath))
-				errorCount++
-				continue
-			}
+			log.Printf("⚠️ Не найдено название региона в файле %s", path.Base(fileName))
+			report.reject(featureReport, "не найдено название региона")
+			continue
 		}
 
 		// Конвертируем геометрию в формат MongoDB
 		mongoGeometry, err := convertGeoJSONGeometry(feature.Geometry)
 		if err != nil {
 			log.Printf("⚠️ Ошибка конвертации геометрии для региона %s: %v", regionName, err)
-			errorCount++
+			report.reject(featureReport, err.Error())
 			continue
 		}
 
 		if mongoGeometry == nil {
 			log.Printf("⚠️ Пустая геометрия для региона %s", regionName)
-			errorCount++
+			report.reject(featureReport, "пустая геометрия")
 			continue
 		}
 
-		// Валидируем геометрию
-		if err := validateGeometry(mongoGeometry); err != nil {
+		// Валидируем и исправляем геометрию
+		repairs, err := validateGeometry(mongoGeometry)
+		if err != nil {
 			log.Printf("⚠️ Невалидная геометрия для региона %s: %v", regionName, err)
-			errorCount++
+			report.reject(featureReport, err.Error())
 			continue
 		}
+		if len(repairs) > 0 {
+			log.Printf("🔧 Геометрия региона %s исправлена: %v", regionName, repairs)
+		}
 
 		// Создаем документ региона
 		regionDoc := bson.M{
 			"name":     regionName,
 			"geometry": mongoGeometry,
 		}
+		for key, value := range RegionMetadata(fileName, feature.Properties, mongoGeometry) {
+			regionDoc[key] = value
+		}
 
 		*regionsToInsert = append(*regionsToInsert, regionDoc)
-		loadedCount++
-
+		report.accept(featureReport, repairs)
 	}
-
-	return loadedCount, errorCount, nil
 }
 
 // extractOfficialName извлекает официальное название региона из поля "official_name:ru"
@@ -316,14 +285,14 @@ func convertGeoJSONGeometry(geometry GeoJSONGeometry) (bson.M, error) {
 		if err := json.Unmarshal(geometry.Coordinates, &coords); err != nil {
 			return nil, fmt.Errorf("ошибка парсинга Polygon координат: %v", err)
 		}
-		result["coordinates"] = coords
+		return polygonalGeometry(geometry.Type, [][][][]float64{coords})
 
 	case "MultiPolygon":
 		var coords [][][][]float64
 		if err := json.Unmarshal(geometry.Coordinates, &coords); err != nil {
 			return nil, fmt.Errorf("ошибка парсинга MultiPolygon координат: %v", err)
 		}
-		result["coordinates"] = coords
+		return polygonalGeometry(geometry.Type, coords)
 
 	default:
 		return nil, fmt.Errorf("неподдерживаемый тип геометрии: %s", geometry.Type)
@@ -332,53 +301,29 @@ func convertGeoJSONGeometry(geometry GeoJSONGeometry) (bson.M, error) {
 	return result, nil
 }
 
-// validateGeometry проверяет геометрию перед вставкой в MongoDB
-func validateGeometry(geometry bson.M) error {
-	if geometry == nil {
-		return fmt.Errorf("геометрия не может быть nil")
+// polygonalGeometry собирает Polygon/MultiPolygon после разрезания по 180-му меридиану.
+// Разрезанный Polygon сохраняется как MultiPolygon
+func polygonalGeometry(geoType string, polygons [][][][]float64) (bson.M, error) {
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for _, point := range ring {
+				if len(point) < 2 {
+					return nil, fmt.Errorf("неверная точка: %v", point)
+				}
+			}
+		}
 	}
 
-	geoType, ok := geometry["type"].(string)
-	if !ok {
-		return fmt.Errorf("отсутствует тип геометрии")
+	polygons, split, err := NormalizeAntimeridian(polygons)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разрезания по 180-му меридиану: %v", err)
 	}
-
-	coords, ok := geometry["coordinates"]
-	if !ok {
-		return fmt.Errorf("отсутствуют координаты")
+	if split {
+		fmt.Printf("✂️ Геометрия разрезана по 180-му меридиану: %d частей\n", len(polygons))
 	}
 
-	switch geoType {
-	case "Point":
-		if points, ok := coords.([]float64); !ok || len(points) != 2 {
-			return fmt.Errorf("неверные координаты для Point")
-		}
-	case "LineString":
-		if points, ok := coords.([][]float64); !ok || len(points) < 2 {
-			return fmt.Errorf("неверные координаты для LineString")
-		}
-	case "Polygon":
-		if rings, ok := coords.([][][]float64); !ok || len(rings) == 0 {
-			return fmt.Errorf("неверные координаты для Polygon")
-		}
-		if rings, ok := coords.([][][]float64); ok && len(rings) > 0 {
-			outerRing := rings[0]
-			if len(outerRing) < 4 {
-				return fmt.Errorf("полигон должен иметь минимум 4 точки")
-			}
-			first := outerRing[0]
-			last := outerRing[len(outerRing)-1]
-			if first[0] != last[0] || first[1] != last[1] {
-				return fmt.Errorf("полигон не замкнут")
-			}
-		}
-	case "MultiPolygon":
-		if polygons, ok := coords.([][][][]float64); !ok || len(polygons) == 0 {
-			return fmt.Errorf("неверные координаты для MultiPolygon")
-		}
-	default:
-		return fmt.Errorf("неподдерживаемый тип геометрии: %s", geoType)
+	if geoType == "Polygon" && len(polygons) == 1 {
+		return bson.M{"type": "Polygon", "coordinates": polygons[0]}, nil
 	}
-
-	return nil
+	return bson.M{"type": "MultiPolygon", "coordinates": polygons}, nil
 }
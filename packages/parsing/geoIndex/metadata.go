@@ -0,0 +1,354 @@
+package geoIndex
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PopulationFileName необязательная таблица численности населения в папке geojsonFiles
+const PopulationFileName = "population.csv"
+
+// FederalDistrictsFileName необязательная таблица соответствия субъектов федеральным округам
+// в папке geojsonFiles; переопределяет встроенное соответствие и свойства GeoJSON
+const FederalDistrictsFileName = "federal_districts.csv"
+
+// FederalDistrictCodes краткие обозначения федеральных округов
+var FederalDistrictCodes = map[string]string{
+	"Центральный":       "ЦФО",
+	"Северо-Западный":   "СЗФО",
+	"Южный":             "ЮФО",
+	"Северо-Кавказский": "СКФО",
+	"Приволжский":       "ПФО",
+	"Уральский":         "УФО",
+	"Сибирский":         "СФО",
+	"Дальневосточный":   "ДФО",
+}
+
+// federalDistricts федеральный округ субъекта по коду файла границ
+var federalDistricts = map[string]string{}
+
+func init() {
+	districts := map[string][]string{
+		"Центральный": {"BEL", "BRY", "VLA", "VOR", "IVA", "KLU", "KOS", "KRS", "LIP",
+			"MOW", "MOS", "ORL", "RYA", "SMO", "TAM", "TVE", "TUL", "YAR"},
+		"Северо-Западный":   {"KR", "KO", "ARK", "NEN", "VLG", "KGD", "LEN", "SPE", "MUR", "NGR", "PSK"},
+		"Южный":             {"AD", "KL", "KRY", "KDA", "AST", "VGG", "ROS", "SEV", "DNR", "LNR", "ZAP", "KHE"},
+		"Северо-Кавказский": {"DA", "IN", "KB", "KC", "SE", "CE", "STA"},
+		"Приволжский": {"BA", "ME", "MO", "TA", "UD", "CU", "PER", "KIR", "NIZ",
+			"ORE", "PNZ", "SAM", "SAR", "ULY"},
+		"Уральский":       {"KGN", "SVE", "TYU", "KHM", "YAN", "CHE"},
+		"Сибирский":       {"AL", "TY", "KK", "ALT", "KYA", "IRK", "KEM", "NVS", "OMS", "TOM"},
+		"Дальневосточный": {"BU", "SA", "ZAB", "KAM", "PRI", "KHA", "AMU", "MAG", "SAK", "YEV", "CHU"},
+	}
+	for district, codes := range districts {
+		for _, code := range codes {
+			federalDistricts[code] = district
+		}
+	}
+}
+
+// NormalizeFederalDistrict приводит название округа к виду "Центральный": принимает
+// краткое обозначение (ЦФО) и полное название ("Центральный федеральный округ").
+// Пустая строка, если округ неизвестен
+func NormalizeFederalDistrict(value string) string {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "федеральный округ"))
+	for name, code := range FederalDistrictCodes {
+		if strings.EqualFold(value, code) || strings.EqualFold(value, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// fileCode код субъекта по имени файла границ: "MOW.geojson" -> "MOW"
+func fileCode(filePath string) string {
+	return strings.ToUpper(strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)))
+}
+
+// RegionMetadata атрибуты субъекта: код ISO 3166-2, краткое название, федеральный округ,
+// часовой пояс и площадь в км², рассчитанная по геометрии
+func RegionMetadata(filePath string, properties map[string]interface{}, geometry bson.M) bson.M {
+	code := fileCode(filePath)
+
+	iso, _ := properties["ISO3166-2"].(string)
+	if iso == "" {
+		iso = "RU-" + code
+	}
+
+	metadata := bson.M{
+		"iso":     strings.ToUpper(iso),
+		"areaKm2": math.Round(geoMath.MultiPolygonAreaKm2(geometryPolygons(geometry))),
+	}
+	for _, key := range []string{"name:ru", "name"} {
+		if shortName, ok := properties[key].(string); ok && shortName != "" {
+			metadata["shortName"] = shortName
+			break
+		}
+	}
+	if district := propertyFederalDistrict(properties); district != "" {
+		metadata["federalDistrict"] = district
+	} else if district, ok := federalDistricts[strings.TrimPrefix(metadata["iso"].(string), "RU-")]; ok {
+		metadata["federalDistrict"] = district
+	} else if district, ok := federalDistricts[code]; ok {
+		metadata["federalDistrict"] = district
+	}
+	if timezone := RegionTimezone(filePath, properties); timezone != "" {
+		metadata["timezone"] = timezone
+	}
+	return metadata
+}
+
+// propertyFederalDistrict федеральный округ из свойств GeoJSON, если он там указан
+func propertyFederalDistrict(properties map[string]interface{}) string {
+	for _, key := range []string{"federal_district", "federalDistrict"} {
+		if value, ok := properties[key].(string); ok {
+			if district := NormalizeFederalDistrict(value); district != "" {
+				return district
+			}
+		}
+	}
+	return ""
+}
+
+// ApplyMetadata дополняет атрибутами регионы, загруженные до их появления,
+// и подгружает таблицы федеральных округов и населения, если они лежат рядом с GeoJSON файлами.
+// Если активна версия, загруженная через API, папка не используется: атрибуты сохранены
+// вместе с версией и не должны перезаписываться таблицами другого набора
+func ApplyMetadata(regionsCollection *mongo.Collection) error {
+	ctx := context.Background()
+
+	active, err := activeVersion(regionsCollection.Database())
+	if err != nil {
+		return err
+	}
+	if active != nil && active.Source != VersionSourceDir {
+		return nil
+	}
+
+	regionsDir, err := RegionsDir()
+	if err != nil {
+		return err
+	}
+
+	missing, err := regionsCollection.CountDocuments(ctx, bson.M{"$or": []bson.M{
+		{"iso": bson.M{"$exists": false}},
+		{"areaKm2": bson.M{"$exists": false}},
+		{"timezone": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return err
+	}
+
+	if missing > 0 {
+		files, err := GetGeoJSONFiles(regionsDir)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения папки: %v", err)
+		}
+
+		updated := 0
+		for _, file := range files {
+			geoJSONFile, err := ReadGeoJSONFile(file)
+			if err != nil {
+				continue
+			}
+			for i, feature := range geoJSONFile.Features {
+				geometry, err := convertGeoJSONGeometry(feature.Geometry)
+				if err != nil || geometry == nil {
+					continue
+				}
+				result, err := regionsCollection.UpdateMany(ctx,
+					bson.M{"name": FeatureName(feature.Properties, i)},
+					bson.M{"$set": RegionMetadata(file, feature.Properties, geometry)})
+				if err != nil {
+					return fmt.Errorf("ошибка обновления атрибутов региона: %v", err)
+				}
+				updated += int(result.ModifiedCount)
+			}
+		}
+		fmt.Printf("🏷️ Атрибуты проставлены %d регионам\n", updated)
+	}
+
+	if err := loadFederalDistrictsFile(regionsCollection, regionsDir); err != nil {
+		return err
+	}
+
+	withoutPopulation, err := regionsCollection.CountDocuments(ctx, bson.M{"population": bson.M{"$exists": false}})
+	if err != nil || withoutPopulation == 0 {
+		return err
+	}
+	return loadPopulationFile(regionsCollection, regionsDir)
+}
+
+// loadPopulationFile загружает population.csv из папки границ, если файл есть
+func loadPopulationFile(regionsCollection *mongo.Collection, regionsDir string) error {
+	file, err := os.Open(filepath.Join(regionsDir, PopulationFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	updated, unmatched, err := ImportPopulation(regionsCollection, file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("👥 Население загружено из %s: %d регионов, не сопоставлено %d\n", PopulationFileName, updated, len(unmatched))
+	return nil
+}
+
+// loadFederalDistrictsFile загружает federal_districts.csv из папки границ, если файл есть
+func loadFederalDistrictsFile(regionsCollection *mongo.Collection, regionsDir string) error {
+	file, err := os.Open(filepath.Join(regionsDir, FederalDistrictsFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	updated, unmatched, err := ImportFederalDistricts(regionsCollection, file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🏛️ Федеральные округа загружены из %s: %d регионов, не сопоставлено %d\n", FederalDistrictsFileName, updated, len(unmatched))
+	return nil
+}
+
+// ImportFederalDistricts загружает соответствие субъектов федеральным округам: в каждой строке
+// регион (код ISO 3166-2 или название) и округ (название или обозначение вроде ЦФО).
+// Возвращает число обновленных регионов и несопоставленные строки
+func ImportFederalDistricts(regionsCollection *mongo.Collection, reader io.Reader) (int, []string, error) {
+	ctx := context.Background()
+
+	records, err := ReadTable(reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка разбора таблицы федеральных округов: %v", err)
+	}
+
+	updated := 0
+	var unmatched []string
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		key := strings.TrimSpace(record[0])
+		district := NormalizeFederalDistrict(record[1])
+		if district == "" {
+			if i > 0 {
+				unmatched = append(unmatched, key)
+			}
+			continue // Строка заголовка или неизвестный округ
+		}
+
+		result, err := regionsCollection.UpdateMany(ctx, regionKeyFilter(key),
+			bson.M{"$set": bson.M{"federalDistrict": district}})
+		if err != nil {
+			return updated, unmatched, fmt.Errorf("ошибка обновления федерального округа: %v", err)
+		}
+		if result.MatchedCount == 0 {
+			unmatched = append(unmatched, key)
+			continue
+		}
+		updated += int(result.MatchedCount)
+	}
+
+	return updated, unmatched, nil
+}
+
+// ImportPopulation загружает таблицу населения: в каждой строке регион (код ISO 3166-2
+// или название) и численность. Разделитель - запятая или точка с запятой, строка заголовка
+// пропускается. Возвращает число обновленных регионов и несопоставленные строки
+func ImportPopulation(regionsCollection *mongo.Collection, reader io.Reader) (int, []string, error) {
+	ctx := context.Background()
+
+	records, err := ReadTable(reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка разбора таблицы населения: %v", err)
+	}
+
+	updated := 0
+	var unmatched []string
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		key := strings.TrimSpace(record[0])
+		population, err := strconv.ParseInt(strings.NewReplacer(" ", "", "\u00a0", "").Replace(record[1]), 10, 64)
+		if err != nil || population <= 0 {
+			if i > 0 {
+				unmatched = append(unmatched, key)
+			}
+			continue // Строка заголовка или некорректное значение
+		}
+
+		result, err := regionsCollection.UpdateMany(ctx, regionKeyFilter(key),
+			bson.M{"$set": bson.M{"population": population}})
+		if err != nil {
+			return updated, unmatched, fmt.Errorf("ошибка обновления населения: %v", err)
+		}
+		if result.MatchedCount == 0 {
+			unmatched = append(unmatched, key)
+			continue
+		}
+		updated += int(result.MatchedCount)
+	}
+
+	return updated, unmatched, nil
+}
+
+// regionKeyFilter фильтр региона по коду ISO 3166-2 или названию
+func regionKeyFilter(key string) bson.M {
+	return bson.M{"$or": []bson.M{{"iso": strings.ToUpper(key)}, {"name": key}, {"shortName": key}}}
+}
+
+// ReadTable читает CSV таблицу с разделителем "," или ";" (определяется по первой строке)
+func ReadTable(reader io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+
+	csvReader := csv.NewReader(strings.NewReader(text))
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	if firstLine, _, _ := strings.Cut(text, "\n"); strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		csvReader.Comma = ';'
+	}
+	return csvReader.ReadAll()
+}
+
+// ParseDecimal число из ячейки таблицы с десятичной точкой или запятой
+func ParseDecimal(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(value), ",", ".", 1), 64)
+}
+
+// FirstProperty первое непустое строковое или числовое свойство GeoJSON объекта из списка ключей
+func FirstProperty(properties map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch value := properties[key].(type) {
+		case string:
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
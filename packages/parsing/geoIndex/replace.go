@@ -0,0 +1,49 @@
+package geoIndex
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// replaceBatchSize размер пачки вставки в промежуточную коллекцию
+const replaceBatchSize = 1000
+
+// ReplaceCollection заменяет содержимое live документами docs без промежуточного пустого или
+// частично заполненного состояния: документы вставляются в промежуточную коллекцию, на ней
+// создаются индексы (indexes, может быть nil), затем она переименовывается на место live.
+// При любой ошибке до переименования live остается прежней
+func ReplaceCollection(ctx context.Context, live *mongo.Collection, docs []interface{},
+	indexes func(context.Context, *mongo.Collection) error) error {
+	stagingName := live.Name() + stagingSuffix
+	staging := live.Database().Collection(stagingName)
+	if err := staging.Drop(ctx); err != nil {
+		return fmt.Errorf("ошибка очистки %s: %v", stagingName, err)
+	}
+	// Коллекция создается явно: при пустом наборе документов переименовывать нечего
+	if err := live.Database().CreateCollection(ctx, stagingName); err != nil {
+		return fmt.Errorf("ошибка создания %s: %v", stagingName, err)
+	}
+
+	fail := func(format string, err error) error {
+		staging.Drop(ctx)
+		return fmt.Errorf(format, stagingName, err)
+	}
+
+	for start := 0; start < len(docs); start += replaceBatchSize {
+		end := min(start+replaceBatchSize, len(docs))
+		if _, err := staging.InsertMany(ctx, docs[start:end]); err != nil {
+			return fail("ошибка вставки в %s: %v", err)
+		}
+	}
+	if indexes != nil {
+		if err := indexes(ctx, staging); err != nil {
+			return fail("ошибка создания индексов %s: %v", err)
+		}
+	}
+	if err := renameCollection(ctx, staging, live.Name()); err != nil {
+		return fail("ошибка переименования %s: %v", err)
+	}
+	return nil
+}
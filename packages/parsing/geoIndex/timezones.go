@@ -0,0 +1,46 @@
+package geoIndex
+
+import "time"
+
+// regionTimezones часовой пояс IANA субъекта по коду файла границ (ISO 3166-2 без "RU-").
+// Для субъектов с несколькими поясами (Якутия) указан пояс административного центра
+var regionTimezones = map[string]string{
+	"AD": "Europe/Moscow", "AL": "Asia/Barnaul", "ALT": "Asia/Barnaul", "AMU": "Asia/Yakutsk",
+	"ARK": "Europe/Moscow", "AST": "Europe/Astrakhan", "BA": "Asia/Yekaterinburg", "BEL": "Europe/Moscow",
+	"BRY": "Europe/Moscow", "BU": "Asia/Irkutsk", "CE": "Europe/Moscow", "CHE": "Asia/Yekaterinburg",
+	"CHU": "Asia/Anadyr", "CU": "Europe/Moscow", "DA": "Europe/Moscow", "DNR": "Europe/Moscow",
+	"IN": "Europe/Moscow", "IRK": "Asia/Irkutsk", "IVA": "Europe/Moscow", "KAM": "Asia/Kamchatka",
+	"KB": "Europe/Moscow", "KC": "Europe/Moscow", "KDA": "Europe/Moscow", "KEM": "Asia/Novokuznetsk",
+	"KGD": "Europe/Kaliningrad", "KGN": "Asia/Yekaterinburg", "KHA": "Asia/Vladivostok", "KHE": "Europe/Moscow",
+	"KHM": "Asia/Yekaterinburg", "KIR": "Europe/Kirov", "KK": "Asia/Krasnoyarsk", "KL": "Europe/Moscow",
+	"KLU": "Europe/Moscow", "KO": "Europe/Moscow", "KOS": "Europe/Moscow", "KR": "Europe/Moscow",
+	"KRS": "Europe/Moscow", "KRY": "Europe/Simferopol", "KYA": "Asia/Krasnoyarsk", "LEN": "Europe/Moscow",
+	"LIP": "Europe/Moscow", "LNR": "Europe/Moscow", "MAG": "Asia/Magadan", "ME": "Europe/Moscow",
+	"MO": "Europe/Moscow", "MOS": "Europe/Moscow", "MOW": "Europe/Moscow", "MUR": "Europe/Moscow",
+	"NEN": "Europe/Moscow", "NGR": "Europe/Moscow", "NIZ": "Europe/Moscow", "NVS": "Asia/Novosibirsk",
+	"OMS": "Asia/Omsk", "ORE": "Asia/Yekaterinburg", "ORL": "Europe/Moscow", "PER": "Asia/Yekaterinburg",
+	"PNZ": "Europe/Moscow", "PRI": "Asia/Vladivostok", "PSK": "Europe/Moscow", "ROS": "Europe/Moscow",
+	"RYA": "Europe/Moscow", "SA": "Asia/Yakutsk", "SAK": "Asia/Sakhalin", "SAM": "Europe/Samara",
+	"SAR": "Europe/Saratov", "SE": "Europe/Moscow", "SEV": "Europe/Simferopol", "SMO": "Europe/Moscow",
+	"SPE": "Europe/Moscow", "STA": "Europe/Moscow", "SVE": "Asia/Yekaterinburg", "TA": "Europe/Moscow",
+	"TAM": "Europe/Moscow", "TOM": "Asia/Tomsk", "TUL": "Europe/Moscow", "TVE": "Europe/Moscow",
+	"TY": "Asia/Krasnoyarsk", "TYU": "Asia/Yekaterinburg", "UD": "Europe/Samara", "ULY": "Europe/Ulyanovsk",
+	"VGG": "Europe/Volgograd", "VLA": "Europe/Moscow", "VLG": "Europe/Moscow", "VOR": "Europe/Moscow",
+	"YAN": "Asia/Yekaterinburg", "YAR": "Europe/Moscow", "YEV": "Asia/Vladivostok", "ZAB": "Asia/Chita",
+	"ZAP": "Europe/Moscow",
+}
+
+// RegionTimezone часовой пояс субъекта: по коду файла границ, иначе из свойства "timezone" GeoJSON.
+// Пустая строка, если пояс неизвестен
+func RegionTimezone(filePath string, properties map[string]interface{}) string {
+	if timezone, ok := regionTimezones[fileCode(filePath)]; ok {
+		return timezone
+	}
+
+	if value, ok := properties["timezone"].(string); ok && value != "" {
+		if _, err := time.LoadLocation(value); err == nil {
+			return value
+		}
+	}
+	return ""
+}
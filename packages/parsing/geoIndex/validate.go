@@ -0,0 +1,458 @@
+package geoIndex
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Результат проверки объекта GeoJSON
+const (
+	FeatureOK       = "ok"
+	FeatureRepaired = "repaired" // Геометрия исправлена автоматически
+	FeatureInvalid  = "invalid"  // Объект пропущен
+)
+
+// FeatureReport результат проверки одного объекта файла
+type FeatureReport struct {
+	Index   int      `bson:"index" json:"index"`
+	Name    string   `bson:"name,omitempty" json:"name,omitempty"`
+	Status  string   `bson:"status" json:"status"`
+	Repairs []string `bson:"repairs,omitempty" json:"repairs,omitempty"`
+	Errors  []string `bson:"errors,omitempty" json:"errors,omitempty"`
+}
+
+// label подпись объекта в сообщениях: название или номер
+func (f FeatureReport) label() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return fmt.Sprintf("объект %d", f.Index)
+}
+
+// accept объект загружен, возможно после исправлений
+func (r *FileReport) accept(feature FeatureReport, repairs []string) {
+	feature.Status = FeatureOK
+	if len(repairs) > 0 {
+		feature.Status = FeatureRepaired
+		feature.Repairs = repairs
+		r.Repaired++
+	}
+	r.Loaded++
+	r.FeatureReports = append(r.FeatureReports, feature)
+}
+
+// reject объект пропущен из-за ошибки
+func (r *FileReport) reject(feature FeatureReport, message string) {
+	feature.Status = FeatureInvalid
+	feature.Errors = append(feature.Errors, message)
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %s", feature.label(), message))
+	r.FeatureReports = append(r.FeatureReports, feature)
+}
+
+// repairCounts исправления, выполненные при проверке геометрии
+type repairCounts struct {
+	closed     int
+	duplicates int
+	spikes     int
+	winding    int
+	holes      int
+	polygons   int
+}
+
+// messages описание исправлений для отчета
+func (c repairCounts) messages() []string {
+	var messages []string
+	if c.closed > 0 {
+		messages = append(messages, fmt.Sprintf("замкнуто колец: %d", c.closed))
+	}
+	if c.duplicates > 0 {
+		messages = append(messages, fmt.Sprintf("удалено повторяющихся вершин: %d", c.duplicates))
+	}
+	if c.spikes > 0 {
+		messages = append(messages, fmt.Sprintf("удалено выбросов (возвратов в ту же точку): %d", c.spikes))
+	}
+	if c.winding > 0 {
+		messages = append(messages, fmt.Sprintf("исправлен порядок обхода колец: %d", c.winding))
+	}
+	if c.holes > 0 {
+		messages = append(messages, fmt.Sprintf("удалено вырожденных дыр: %d", c.holes))
+	}
+	if c.polygons > 0 {
+		messages = append(messages, fmt.Sprintf("удалено вырожденных полигонов: %d", c.polygons))
+	}
+	return messages
+}
+
+// RepairPolygons проверяет полигоны перед построением 2dsphere индекса и исправляет простые
+// ошибки: незамкнутые кольца, повторяющиеся вершины, выбросы, вырожденные кольца и порядок
+// обхода (внешнее кольцо против часовой стрелки, дыры по часовой, RFC 7946).
+// Самопересечения, дыры вне внешнего кольца и перекрытие полигонов мультиполигона
+// не исправляются и возвращаются ошибкой.
+// Возвращает исправленные полигоны и список выполненных исправлений
+func RepairPolygons(polygons [][][][]float64) ([][][][]float64, []string, error) {
+	var counts repairCounts
+	result := make([][][][]float64, 0, len(polygons))
+
+	for p, polygon := range polygons {
+		if len(polygon) == 0 {
+			counts.polygons++
+			continue
+		}
+
+		var rings [][][]float64
+		degenerateOuter := false
+		for r, ring := range polygon {
+			for _, point := range ring {
+				if len(point) < 2 || math.IsNaN(point[0]) || math.IsNaN(point[1]) ||
+					math.Abs(point[0]) > 180 || math.Abs(point[1]) > 90 {
+					return nil, nil, fmt.Errorf("полигон %d: неверная точка %v", p+1, point)
+				}
+			}
+
+			cleaned := cleanRing(ring, &counts)
+			// Нулевая площадь у кольца-восьмерки - это самопересечение, а не вырожденное кольцо
+			if len(cleaned) >= 4 && geoMath.PlanarArea(cleaned) == 0 {
+				if err := checkPolygonTopology([][][]float64{cleaned}); err != nil {
+					return nil, nil, fmt.Errorf("полигон %d: %v", p+1, err)
+				}
+			}
+			if len(cleaned) < 4 || geoMath.PlanarArea(cleaned) == 0 {
+				if r == 0 {
+					degenerateOuter = true
+					break
+				}
+				counts.holes++
+				continue
+			}
+
+			// Внешнее кольцо против часовой стрелки, дыры - по часовой
+			if (r == 0) != (geoMath.PlanarArea(cleaned) > 0) {
+				cleaned = geoMath.ReverseRing(cleaned)
+				counts.winding++
+			}
+			rings = append(rings, cleaned)
+		}
+		if degenerateOuter {
+			counts.polygons++
+			continue
+		}
+
+		if err := checkPolygonTopology(rings); err != nil {
+			return nil, nil, fmt.Errorf("полигон %d: %v", p+1, err)
+		}
+		result = append(result, rings)
+	}
+
+	if len(result) == 0 {
+		return nil, nil, fmt.Errorf("нет ни одного полигона минимум из 3 различных точек")
+	}
+	if err := checkPolygonsOverlap(result); err != nil {
+		return nil, nil, err
+	}
+	return result, counts.messages(), nil
+}
+
+// cleanRing замыкает кольцо и удаляет повторяющиеся подряд вершины и выбросы A-B-A
+func cleanRing(ring [][]float64, counts *repairCounts) [][]float64 {
+	if len(ring) == 0 {
+		return ring
+	}
+
+	open := ring
+	if samePoint(ring[0], ring[len(ring)-1]) && len(ring) > 1 {
+		open = ring[:len(ring)-1]
+	} else {
+		counts.closed++
+	}
+
+	points := make([][]float64, 0, len(open))
+	for _, point := range open {
+		if len(points) > 0 && samePoint(points[len(points)-1], point) {
+			counts.duplicates++
+			continue
+		}
+		points = append(points, point[:2])
+	}
+	for len(points) > 1 && samePoint(points[0], points[len(points)-1]) {
+		points = points[:len(points)-1]
+		counts.duplicates++
+	}
+
+	// Выброс: соседи вершины совпадают, ребро уходит и возвращается по тому же пути
+	for changed := true; changed && len(points) >= 3; {
+		changed = false
+		for i := range points {
+			n := len(points)
+			prev, next := points[(i+n-1)%n], points[(i+1)%n]
+			if !samePoint(prev, next) {
+				continue
+			}
+			if i+1 < n {
+				points = append(points[:i], points[i+2:]...)
+			} else {
+				points = points[1:i]
+			}
+			counts.spikes++
+			changed = true
+			break
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	return append(points, points[0])
+}
+
+// ringSegment ребро кольца для поиска пересечений
+type ringSegment struct {
+	polygon     int
+	ring, index int
+	minX, maxX  float64
+	minY, maxY  float64
+	a, b        []float64
+}
+
+// checkPolygonTopology проверяет, что кольца полигона не пересекают себя и друг друга
+// и что дыры лежат внутри внешнего кольца и не вложены друг в друга
+func checkPolygonTopology(rings [][][]float64) error {
+	var segments []ringSegment
+	for r, ring := range rings {
+		for i := 1; i < len(ring); i++ {
+			a, b := ring[i-1], ring[i]
+			segments = append(segments, ringSegment{
+				ring: r, index: i - 1,
+				minX: math.Min(a[0], b[0]), maxX: math.Max(a[0], b[0]),
+				minY: math.Min(a[1], b[1]), maxY: math.Max(a[1], b[1]),
+				a: a, b: b,
+			})
+		}
+	}
+
+	// Заметание по долготе: сравниваются только ребра с перекрывающимися проекциями
+	sort.Slice(segments, func(i, j int) bool { return segments[i].minX < segments[j].minX })
+	var active []ringSegment
+	for _, segment := range segments {
+		kept := active[:0]
+		for _, other := range active {
+			if other.maxX >= segment.minX {
+				kept = append(kept, other)
+			}
+		}
+		active = kept
+
+		for _, other := range active {
+			if other.maxY < segment.minY || other.minY > segment.maxY {
+				continue
+			}
+			if segment.ring == other.ring && adjacentSegments(segment.index, other.index, len(rings[segment.ring])-1) {
+				continue
+			}
+			if geoMath.SegmentsIntersect(segment.a, segment.b, other.a, other.b) {
+				point := segment.a
+				if segment.ring == other.ring {
+					return fmt.Errorf("самопересечение кольца %d около (%.6f, %.6f)", segment.ring+1, point[0], point[1])
+				}
+				return fmt.Errorf("пересечение колец %d и %d около (%.6f, %.6f)", other.ring+1, segment.ring+1, point[0], point[1])
+			}
+		}
+		active = append(active, segment)
+	}
+
+	// Границы не пересекаются, поэтому положение дыры определяется любой ее вершиной
+	for h := 1; h < len(rings); h++ {
+		point := rings[h][0]
+		if !geoMath.PointInRing(point[0], point[1], rings[0]) {
+			return fmt.Errorf("дыра %d лежит вне внешнего кольца", h)
+		}
+		for other := 1; other < len(rings); other++ {
+			if other != h && geoMath.PointInRing(point[0], point[1], rings[other]) {
+				return fmt.Errorf("дыра %d лежит внутри дыры %d", h, other)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPolygonsOverlap проверяет, что полигоны мультиполигона не перекрываются:
+// их границы не пересекаются (касание допускается) и ни один не лежит внутри другого
+// вне его дыр. Полигон, лежащий в дыре другого (остров в озере), допустим
+func checkPolygonsOverlap(polygons [][][][]float64) error {
+	if len(polygons) < 2 {
+		return nil
+	}
+
+	var segments []ringSegment
+	bboxes := make([]geoMath.BBox, len(polygons))
+	for p, polygon := range polygons {
+		bboxes[p] = geoMath.RingBBox(polygon[0])
+		for r, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				a, b := ring[i-1], ring[i]
+				segments = append(segments, ringSegment{
+					polygon: p, ring: r, index: i - 1,
+					minX: math.Min(a[0], b[0]), maxX: math.Max(a[0], b[0]),
+					minY: math.Min(a[1], b[1]), maxY: math.Max(a[1], b[1]),
+					a: a, b: b,
+				})
+			}
+		}
+	}
+
+	// Заметание по долготе, как в checkPolygonTopology, но сравниваются ребра разных полигонов
+	sort.Slice(segments, func(i, j int) bool { return segments[i].minX < segments[j].minX })
+	var active []ringSegment
+	for _, segment := range segments {
+		kept := active[:0]
+		for _, other := range active {
+			if other.maxX >= segment.minX {
+				kept = append(kept, other)
+			}
+		}
+		active = kept
+
+		for _, other := range active {
+			if other.polygon == segment.polygon || other.maxY < segment.minY || other.minY > segment.maxY {
+				continue
+			}
+			if segmentsCross(segment.a, segment.b, other.a, other.b) {
+				point := segment.a
+				return fmt.Errorf("полигоны %d и %d пересекаются около (%.6f, %.6f)",
+					other.polygon+1, segment.polygon+1, point[0], point[1])
+			}
+		}
+		active = append(active, segment)
+	}
+
+	// Границы не пересекаются: полигон целиком внутри или снаружи другого,
+	// поэтому достаточно одной его точки, не лежащей на границе другого
+	for inner := range polygons {
+		for outer := range polygons {
+			if inner == outer || !bboxes[outer].Intersects(bboxes[inner]) {
+				continue
+			}
+			point, ok := pointOffBoundary(polygons[inner][0], polygons[outer])
+			if !ok || geoMath.PointInPolygon(point[0], point[1], polygons[outer]) {
+				return fmt.Errorf("полигон %d перекрывает полигон %d", inner+1, outer+1)
+			}
+		}
+	}
+	return nil
+}
+
+// pointOffBoundary вершина или середина ребра кольца, не лежащая на границе полигона;
+// false, если все такие точки лежат на границе (кольцо совпадает с границей полигона)
+func pointOffBoundary(ring [][]float64, polygon [][][]float64) ([]float64, bool) {
+	for _, point := range ring {
+		if !onPolygonBoundary(point, polygon) {
+			return point, true
+		}
+	}
+	for i := 1; i < len(ring); i++ {
+		middle := []float64{(ring[i-1][0] + ring[i][0]) / 2, (ring[i-1][1] + ring[i][1]) / 2}
+		if !onPolygonBoundary(middle, polygon) {
+			return middle, true
+		}
+	}
+	return nil, false
+}
+
+// onPolygonBoundary лежит ли точка на ребре одного из колец полигона
+func onPolygonBoundary(point []float64, polygon [][][]float64) bool {
+	for _, ring := range polygon {
+		for i := 1; i < len(ring); i++ {
+			a, b := ring[i-1], ring[i]
+			if cross(a, b, point) == 0 &&
+				math.Min(a[0], b[0]) <= point[0] && point[0] <= math.Max(a[0], b[0]) &&
+				math.Min(a[1], b[1]) <= point[1] && point[1] <= math.Max(a[1], b[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// segmentsCross ребра AB и CD пересекаются во внутренних точках обоих (касание не считается)
+func segmentsCross(a, b, c, d []float64) bool {
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// cross векторное произведение OP x OQ
+func cross(o, p, q []float64) float64 {
+	return (p[0]-o[0])*(q[1]-o[1]) - (p[1]-o[1])*(q[0]-o[0])
+}
+
+// adjacentSegments ребра i и j кольца из count ребер имеют общую вершину
+func adjacentSegments(i, j, count int) bool {
+	if i > j {
+		i, j = j, i
+	}
+	return j-i == 1 || (i == 0 && j == count-1)
+}
+
+// samePoint совпадение точек по долготе и широте
+func samePoint(a, b []float64) bool {
+	return a[0] == b[0] && a[1] == b[1]
+}
+
+// validateGeometry проверяет геометрию перед вставкой в MongoDB. Полигоны исправляются
+// на месте через RepairPolygons; возвращает список выполненных исправлений
+func validateGeometry(geometry bson.M) ([]string, error) {
+	if geometry == nil {
+		return nil, fmt.Errorf("геометрия не может быть nil")
+	}
+
+	geoType, ok := geometry["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("отсутствует тип геометрии")
+	}
+
+	coords, ok := geometry["coordinates"]
+	if !ok {
+		return nil, fmt.Errorf("отсутствуют координаты")
+	}
+
+	switch geoType {
+	case "Point":
+		if points, ok := coords.([]float64); !ok || len(points) != 2 {
+			return nil, fmt.Errorf("неверные координаты для Point")
+		}
+	case "LineString":
+		if points, ok := coords.([][]float64); !ok || len(points) < 2 {
+			return nil, fmt.Errorf("неверные координаты для LineString")
+		}
+	case "Polygon":
+		rings, ok := coords.([][][]float64)
+		if !ok || len(rings) == 0 {
+			return nil, fmt.Errorf("неверные координаты для Polygon")
+		}
+		polygons, repairs, err := RepairPolygons([][][][]float64{rings})
+		if err != nil {
+			return nil, err
+		}
+		geometry["coordinates"] = polygons[0]
+		return repairs, nil
+	case "MultiPolygon":
+		polygons, ok := coords.([][][][]float64)
+		if !ok || len(polygons) == 0 {
+			return nil, fmt.Errorf("неверные координаты для MultiPolygon")
+		}
+		polygons, repairs, err := RepairPolygons(polygons)
+		if err != nil {
+			return nil, err
+		}
+		geometry["coordinates"] = polygons
+		return repairs, nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип геометрии: %s", geoType)
+	}
+
+	return nil, nil
+}
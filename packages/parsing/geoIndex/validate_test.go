@@ -0,0 +1,111 @@
+package geoIndex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"project/packages/parsing/geoMath"
+)
+
+// square квадрат со стороной size от точки (x, y) против часовой стрелки
+func square(x, y, size float64) [][]float64 {
+	return [][]float64{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+}
+
+func TestRepairPolygons(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    [][][][]float64
+		want     [][][][]float64 // nil - не проверяется
+		repairs  []string
+		errorHas string
+	}{
+		{
+			name:     "восьмерка",
+			input:    [][][][]float64{{{{0, 0}, {2, 2}, {2, 0}, {0, 2}, {0, 0}}}},
+			errorHas: "самопересечение",
+		},
+		{
+			name:    "незамкнутое кольцо",
+			input:   [][][][]float64{{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}},
+			want:    [][][][]float64{{square(0, 0, 1)}},
+			repairs: []string{"замкнуто колец: 1"},
+		},
+		{
+			name: "обход по часовой стрелке",
+			input: [][][][]float64{{
+				{{0, 0}, {0, 4}, {4, 4}, {4, 0}, {0, 0}},
+				square(1, 1, 1),
+			}},
+			want: [][][][]float64{{
+				square(0, 0, 4),
+				geoMath.ReverseRing(square(1, 1, 1)),
+			}},
+			repairs: []string{"исправлен порядок обхода колец: 2"},
+		},
+		{
+			name:     "дыра вне внешнего кольца",
+			input:    [][][][]float64{{square(0, 0, 1), geoMath.ReverseRing(square(5, 5, 1))}},
+			errorHas: "вне внешнего кольца",
+		},
+		{
+			name:    "выброс A-B-A",
+			input:   [][][][]float64{{{{0, 0}, {1, 0}, {1, 1}, {3, 3}, {1, 1}, {0, 1}, {0, 0}}}},
+			want:    [][][][]float64{{square(0, 0, 1)}},
+			repairs: []string{"удалено выбросов (возвратов в ту же точку): 1"},
+		},
+		{
+			name:     "пересекающиеся полигоны",
+			input:    [][][][]float64{{square(0, 0, 2)}, {square(1, 1, 2)}},
+			errorHas: "пересекаются",
+		},
+		{
+			name:     "полигон внутри другого",
+			input:    [][][][]float64{{square(0, 0, 4)}, {square(1, 1, 1)}},
+			errorHas: "перекрывает",
+		},
+		{
+			name:     "совпадающие полигоны",
+			input:    [][][][]float64{{square(0, 0, 1)}, {square(0, 0, 1)}},
+			errorHas: "перекрывает",
+		},
+		{
+			name:  "соседние полигоны с общей стороной",
+			input: [][][][]float64{{square(0, 0, 1)}, {square(1, 0, 1)}},
+			want:  [][][][]float64{{square(0, 0, 1)}, {square(1, 0, 1)}},
+		},
+		{
+			name: "остров в дыре",
+			input: [][][][]float64{
+				{square(0, 0, 4), geoMath.ReverseRing(square(1, 1, 2))},
+				{square(1.5, 1.5, 1)},
+			},
+			want: [][][][]float64{
+				{square(0, 0, 4), geoMath.ReverseRing(square(1, 1, 2))},
+				{square(1.5, 1.5, 1)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, repairs, err := RepairPolygons(test.input)
+			if test.errorHas != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errorHas) {
+					t.Fatalf("ожидалась ошибка %q, получено %v", test.errorHas, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RepairPolygons: %v", err)
+			}
+			if test.want != nil && !reflect.DeepEqual(result, test.want) {
+				t.Errorf("результат %v, ожидалось %v", result, test.want)
+			}
+			if !reflect.DeepEqual(repairs, test.repairs) {
+				t.Errorf("исправления %q, ожидалось %q", repairs, test.repairs)
+			}
+		})
+	}
+}
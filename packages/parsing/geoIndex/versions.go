@@ -0,0 +1,380 @@
+package geoIndex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// VersionsCollectionName коллекция описаний версий наборов границ
+	VersionsCollectionName = "boundaryVersions"
+	// Коллекции с регионами и районами версии: префикс + название версии
+	versionRegionsPrefix   = "regionsGeo_v_"
+	versionDistrictsPrefix = "districtsGeo_v_"
+	// stagingSuffix суффикс промежуточной коллекции при переключении версии
+	stagingSuffix = "_staging"
+	// previousSuffix суффикс копии рабочих регионов на время переключения версии
+	previousSuffix = "_previous"
+	// copyBatchSize размер пачки копирования документов версии
+	copyBatchSize = 200
+)
+
+// Источник версии набора границ
+const (
+	VersionSourceDir    = "geojsonFiles" // Папка рядом с исполняемым файлом
+	VersionSourceUpload = "upload"       // Загрузка через API
+)
+
+// ErrVersionNotFound версия набора границ не найдена
+var ErrVersionNotFound = errors.New("версия набора границ не найдена")
+
+// activateMutex активации выполняются по одной: они используют общие промежуточные коллекции
+// (_staging, _previous)
+var activateMutex sync.Mutex
+
+// versionNameRegex допустимые названия версий: используются в именах коллекций
+var versionNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,48}$`)
+
+// BoundaryVersion сохраненная версия набора границ
+type BoundaryVersion struct {
+	Name        string         `bson:"name" json:"name"`
+	Source      string         `bson:"source" json:"source"`
+	Comment     string         `bson:"comment,omitempty" json:"comment,omitempty"`
+	CreatedAt   time.Time      `bson:"createdAt" json:"createdAt"`
+	ActivatedAt *time.Time     `bson:"activatedAt,omitempty" json:"activatedAt,omitempty"`
+	Active      bool           `bson:"active" json:"active"`
+	Regions     int            `bson:"regions" json:"regions"`
+	Districts   int            `bson:"districts" json:"districts"`
+	Report      BoundaryReport `bson:"report" json:"report"`
+}
+
+// ValidateVersionName проверяет название версии
+func ValidateVersionName(name string) error {
+	if !versionNameRegex.MatchString(name) {
+		return fmt.Errorf("название версии может содержать только латиницу, цифры, '.', '_' и '-' (до 48 символов)")
+	}
+	return nil
+}
+
+// DirVersionName название версии, загружаемой из папки geojsonFiles
+func DirVersionName() string {
+	return VersionSourceDir + "-" + time.Now().Format("20060102-150405")
+}
+
+// UploadVersionName название по умолчанию для загруженной версии
+func UploadVersionName() string {
+	return VersionSourceUpload + "-" + time.Now().Format("20060102-150405")
+}
+
+// SaveVersion сохраняет набор границ как новую версию. Регионы и районы записываются
+// в отдельные коллекции версии с 2dsphere индексами: геометрию, которую не принимает MongoDB,
+// версия не сохраняется, а рабочие коллекции не затрагиваются
+func SaveVersion(db *mongo.Database, name, source string, set *BoundarySet) (*BoundaryVersion, error) {
+	if err := ValidateVersionName(name); err != nil {
+		return nil, err
+	}
+	if len(set.Regions) == 0 {
+		return nil, fmt.Errorf("в наборе нет ни одного региона")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	versions := db.Collection(VersionsCollectionName)
+	if count, err := versions.CountDocuments(ctx, bson.M{"name": name}); err != nil {
+		return nil, err
+	} else if count > 0 {
+		return nil, fmt.Errorf("версия %s уже существует", name)
+	}
+
+	regions := db.Collection(versionRegionsPrefix + name)
+	districts := db.Collection(versionDistrictsPrefix + name)
+	cleanup := func() {
+		regions.Drop(context.Background())
+		districts.Drop(context.Background())
+	}
+	cleanup()
+
+	fmt.Printf("\n💾 Сохраняем версию %s: %d регионов, %d районов...\n", name, len(set.Regions), len(set.Districts))
+	if _, err := regions.InsertMany(ctx, set.Regions); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("ошибка вставки регионов: %v", err)
+	}
+
+	// Таблицы атрибутов из набора
+	if set.FederalDistricts != nil {
+		if _, _, err := ImportFederalDistricts(regions, bytes.NewReader(set.FederalDistricts)); err != nil {
+			set.Report.Warnings = append(set.Report.Warnings, fmt.Sprintf("%s: %v", FederalDistrictsFileName, err))
+		}
+	}
+	if set.Population != nil {
+		if _, _, err := ImportPopulation(regions, bytes.NewReader(set.Population)); err != nil {
+			set.Report.Warnings = append(set.Report.Warnings, fmt.Sprintf("%s: %v", PopulationFileName, err))
+		}
+	}
+
+	if err := ensureRegionIndexes(ctx, regions); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("MongoDB отклонил геометрию регионов: %v", err)
+	}
+
+	if len(set.Districts) > 0 {
+		if _, err := districts.InsertMany(ctx, set.Districts); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("ошибка вставки районов: %v", err)
+		}
+		if err := ensureDistrictIndexes(ctx, districts); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("MongoDB отклонил геометрию районов: %v", err)
+		}
+	}
+
+	version := &BoundaryVersion{
+		Name:      name,
+		Source:    source,
+		CreatedAt: time.Now(),
+		Regions:   len(set.Regions),
+		Districts: len(set.Districts),
+		Report:    set.Report,
+	}
+	if _, err := versions.InsertOne(ctx, version); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("ошибка сохранения версии: %v", err)
+	}
+
+	fmt.Printf("✅ Версия набора границ %s сохранена\n", name)
+	return version, nil
+}
+
+// ActivateVersion делает версию рабочей: регионы и районы версии копируются в промежуточные
+// коллекции с индексами, которые переименовываются в рабочие (regionsCollection и districtsGeo)
+// только после подготовки обеих. Рабочие регионы перед переключением копируются: если не удалось
+// переключить районы, прежние регионы возвращаются на место, и рабочий набор остается согласованным.
+// Атрибуты регионов (федеральные округа, население) берутся из версии, как они были сохранены
+func ActivateVersion(regionsCollection *mongo.Collection, name string) error {
+	activateMutex.Lock()
+	defer activateMutex.Unlock()
+
+	db := regionsCollection.Database()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	versions := db.Collection(VersionsCollectionName)
+	var version BoundaryVersion
+	if err := versions.FindOne(ctx, bson.M{"name": name}).Decode(&version); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrVersionNotFound
+		}
+		return err
+	}
+
+	regionsStaging, err := stageCollection(ctx, db.Collection(versionRegionsPrefix+name), regionsCollection.Name()+stagingSuffix)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки регионов версии %s: %v", name, err)
+	}
+	if err := ensureRegionIndexes(ctx, regionsStaging); err != nil {
+		regionsStaging.Drop(context.Background())
+		return fmt.Errorf("ошибка создания индексов регионов: %v", err)
+	}
+
+	districtsStaging, err := stageCollection(ctx, db.Collection(versionDistrictsPrefix+name), DistrictsCollectionName+stagingSuffix)
+	if err != nil {
+		regionsStaging.Drop(context.Background())
+		return fmt.Errorf("ошибка подготовки районов версии %s: %v", name, err)
+	}
+	if err := ensureDistrictIndexes(ctx, districtsStaging); err != nil {
+		regionsStaging.Drop(context.Background())
+		districtsStaging.Drop(context.Background())
+		return fmt.Errorf("ошибка создания индексов районов: %v", err)
+	}
+
+	dropStaging := func() {
+		regionsStaging.Drop(context.Background())
+		districtsStaging.Drop(context.Background())
+	}
+
+	// Копия рабочих регионов для отката, если переключение районов не удастся
+	previous, err := stageCollection(ctx, regionsCollection, regionsCollection.Name()+previousSuffix)
+	if err != nil {
+		dropStaging()
+		return fmt.Errorf("ошибка копирования рабочих регионов: %v", err)
+	}
+	defer previous.Drop(context.Background())
+	if err := ensureRegionIndexes(ctx, previous); err != nil {
+		dropStaging()
+		return fmt.Errorf("ошибка создания индексов копии регионов: %v", err)
+	}
+
+	// Переключение: каждая коллекция заменяется атомарно
+	if err := renameCollection(ctx, regionsStaging, regionsCollection.Name()); err != nil {
+		dropStaging()
+		return fmt.Errorf("ошибка переключения регионов: %v", err)
+	}
+	if err := renameCollection(ctx, districtsStaging, DistrictsCollectionName); err != nil {
+		districtsStaging.Drop(context.Background())
+		if restoreErr := renameCollection(context.Background(), previous, regionsCollection.Name()); restoreErr != nil {
+			return fmt.Errorf("ошибка переключения районов: %v; прежние регионы не восстановлены: %v", err, restoreErr)
+		}
+		return fmt.Errorf("ошибка переключения районов: %v; прежние регионы восстановлены", err)
+	}
+
+	now := time.Now()
+	if _, err := versions.UpdateMany(ctx, bson.M{"name": bson.M{"$ne": name}}, bson.M{"$set": bson.M{"active": false}}); err != nil {
+		return err
+	}
+	if _, err := versions.UpdateOne(ctx, bson.M{"name": name}, bson.M{"$set": bson.M{"active": true, "activatedAt": now}}); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Активна версия набора границ %s: %d регионов, %d районов\n", name, version.Regions, version.Districts)
+	return nil
+}
+
+// ActiveRegionsCollection коллекция регионов активной версии набора границ; nil, если активной версии нет.
+// Атрибуты, загружаемые в рабочие регионы, записываются и сюда, чтобы не потеряться при повторной активации
+func ActiveRegionsCollection(db *mongo.Database) (*mongo.Collection, error) {
+	version, err := activeVersion(db)
+	if err != nil || version == nil {
+		return nil, err
+	}
+	return db.Collection(versionRegionsPrefix + version.Name), nil
+}
+
+// activeVersion активная версия набора границ без отчета; nil, если активной версии нет
+func activeVersion(db *mongo.Database) (*BoundaryVersion, error) {
+	var version BoundaryVersion
+	err := db.Collection(VersionsCollectionName).FindOne(context.Background(), bson.M{"active": true},
+		options.FindOne().SetProjection(bson.M{"report": 0})).Decode(&version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// stageCollection копирует документы версии в пустую промежуточную коллекцию
+func stageCollection(ctx context.Context, source *mongo.Collection, stagingName string) (*mongo.Collection, error) {
+	staging := source.Database().Collection(stagingName)
+	if err := staging.Drop(ctx); err != nil {
+		return nil, err
+	}
+	// Коллекция создается явно: у версии без районов документов нет
+	if err := source.Database().CreateCollection(ctx, stagingName); err != nil {
+		return nil, err
+	}
+
+	cursor, err := source.Find(ctx, bson.M{}, options.Find().SetBatchSize(copyBatchSize))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]interface{}, 0, copyBatchSize)
+	for cursor.Next(ctx) {
+		batch = append(batch, bson.Raw(append([]byte{}, cursor.Current...)))
+		if len(batch) == copyBatchSize {
+			if _, err := staging.InsertMany(ctx, batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(batch) > 0 {
+		if _, err := staging.InsertMany(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+	return staging, nil
+}
+
+// renameCollection атомарно заменяет коллекцию target коллекцией source
+func renameCollection(ctx context.Context, source *mongo.Collection, target string) error {
+	db := source.Database()
+	return db.Client().Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + source.Name()},
+		{Key: "to", Value: db.Name() + "." + target},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+}
+
+// ensureRegionIndexes 2dsphere индекс регионов
+func ensureRegionIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "geometry", Value: "2dsphere"}},
+	})
+	return err
+}
+
+// ensureDistrictIndexes 2dsphere индекс и индекс по субъекту у районов
+func ensureDistrictIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "geometry", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "parent", Value: 1}}},
+	})
+	return err
+}
+
+// ListVersions версии наборов границ, новые первыми
+func ListVersions(db *mongo.Database) ([]BoundaryVersion, error) {
+	ctx := context.Background()
+	cursor, err := db.Collection(VersionsCollectionName).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetProjection(bson.M{"report.files": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []BoundaryVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetVersion версия набора границ с полным отчетом проверки
+func GetVersion(db *mongo.Database, name string) (*BoundaryVersion, error) {
+	var version BoundaryVersion
+	err := db.Collection(VersionsCollectionName).FindOne(context.Background(), bson.M{"name": name}).Decode(&version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// DeleteVersion удаляет неактивную версию набора границ
+func DeleteVersion(db *mongo.Database, name string) error {
+	version, err := GetVersion(db, name)
+	if err != nil {
+		return err
+	}
+	if version.Active {
+		return fmt.Errorf("нельзя удалить активную версию %s", name)
+	}
+
+	ctx := context.Background()
+	if err := db.Collection(versionRegionsPrefix + name).Drop(ctx); err != nil {
+		return err
+	}
+	if err := db.Collection(versionDistrictsPrefix + name).Drop(ctx); err != nil {
+		return err
+	}
+	_, err = db.Collection(VersionsCollectionName).DeleteOne(ctx, bson.M{"name": name})
+	return err
+}
@@ -0,0 +1,175 @@
+package geoMath
+
+import (
+	"math"
+	"sort"
+)
+
+// dissolveVertex вершина как ключ карты
+type dissolveVertex [2]float64
+
+// dissolveEdge ребро без направления: вершины упорядочены
+type dissolveEdge [2]dissolveVertex
+
+func newDissolveEdge(a, b dissolveVertex) dissolveEdge {
+	if b[0] < a[0] || (b[0] == a[0] && b[1] < a[1]) {
+		a, b = b, a
+	}
+	return dissolveEdge{a, b}
+}
+
+// DissolvePolygons объединяет мультиполигоны соседних областей в один мультиполигон.
+// Условие применимости: общие границы проходят по одинаковым вершинам (как в исходных
+// границах субъектов и после их согласованного упрощения). Остаются только ребра,
+// встречающиеся нечетное число раз; если у соседей вершины общей границы не совпадают,
+// ребра не сокращаются и граница между ними остается в результате.
+// Оставшиеся ребра сшиваются в кольца: кольца с четной глубиной вложенности
+// становятся внешними, с нечетной - дырами ближайшего охватывающего кольца
+func DissolvePolygons(shapes [][][][][]float64) [][][][]float64 {
+	counts := make(map[dissolveEdge]int)
+	for _, polygons := range shapes {
+		for _, polygon := range polygons {
+			for _, ring := range polygon {
+				n := len(ring)
+				for i := 0; i < n; i++ {
+					a := dissolveVertex{ring[i][0], ring[i][1]}
+					b := dissolveVertex{ring[(i+1)%n][0], ring[(i+1)%n][1]}
+					if a != b {
+						counts[newDissolveEdge(a, b)]++
+					}
+				}
+			}
+		}
+	}
+
+	// Граница объединения - ребра, принадлежащие нечетному числу колец
+	var edges []dissolveEdge
+	for edge, count := range counts {
+		if count%2 == 1 {
+			edges = append(edges, edge)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		for k := 0; k < 2; k++ {
+			if a[k] != b[k] {
+				return a[k][0] < b[k][0] || (a[k][0] == b[k][0] && a[k][1] < b[k][1])
+			}
+		}
+		return false
+	})
+
+	// Соседи заполняются по отсортированным ребрам, чтобы результат не зависел от порядка обхода карты
+	adjacency := make(map[dissolveVertex][]dissolveVertex)
+	for _, edge := range edges {
+		adjacency[edge[0]] = append(adjacency[edge[0]], edge[1])
+		adjacency[edge[1]] = append(adjacency[edge[1]], edge[0])
+	}
+
+	return assembleRings(stitchRings(edges, adjacency))
+}
+
+// stitchRings сшивает ребра в замкнутые кольца; незамкнутые цепочки отбрасываются
+func stitchRings(edges []dissolveEdge, adjacency map[dissolveVertex][]dissolveVertex) [][][]float64 {
+	used := make(map[dissolveEdge]bool, len(edges))
+	var rings [][][]float64
+
+	for _, first := range edges {
+		if used[first] {
+			continue
+		}
+		used[first] = true
+
+		start, current := first[0], first[1]
+		ring := [][]float64{{start[0], start[1]}, {current[0], current[1]}}
+		for current != start {
+			next, found := dissolveVertex{}, false
+			for _, candidate := range adjacency[current] {
+				if edge := newDissolveEdge(current, candidate); !used[edge] {
+					used[edge] = true
+					next, found = candidate, true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+			ring = append(ring, []float64{next[0], next[1]})
+			current = next
+		}
+
+		if current == start && len(ring) >= 4 {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
+
+// assembleRings раскладывает кольца по полигонам по глубине вложенности
+// и ориентирует их по RFC 7946: внешние против часовой стрелки, дыры по часовой
+func assembleRings(rings [][][]float64) [][][][]float64 {
+	areas := make([]float64, len(rings))
+	for i, ring := range rings {
+		areas[i] = PlanarArea(ring)
+	}
+	order := make([]int, len(rings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return math.Abs(areas[order[i]]) > math.Abs(areas[order[j]]) })
+
+	bboxes := make([]BBox, len(rings))
+	for i, ring := range rings {
+		bboxes[i] = RingBBox(ring)
+	}
+
+	var polygons [][][][]float64
+	polygonOf := make(map[int]int, len(rings))
+	for position, index := range order {
+		ring := rings[index]
+		// Середина первого ребра лежит только на этом кольце
+		x, y := (ring[0][0]+ring[1][0])/2, (ring[0][1]+ring[1][1])/2
+
+		depth, parent := 0, -1
+		for _, other := range order[:position] {
+			if bboxes[other].Contains(x, y) && PointInRing(x, y, rings[other]) {
+				depth++
+				parent = other
+			}
+		}
+
+		if depth%2 == 0 {
+			if areas[index] < 0 {
+				ring = ReverseRing(ring)
+			}
+			polygonOf[index] = len(polygons)
+			polygons = append(polygons, [][][]float64{ring})
+			continue
+		}
+		if areas[index] > 0 {
+			ring = ReverseRing(ring)
+		}
+		if target, ok := polygonOf[parent]; ok {
+			polygons[target] = append(polygons[target], ring)
+		}
+	}
+	return polygons
+}
+
+// PlanarArea площадь кольца в квадратных градусах со знаком: положительная против часовой стрелки
+func PlanarArea(ring [][]float64) float64 {
+	var sum float64
+	for i := 1; i < len(ring); i++ {
+		sum += ring[i-1][0]*ring[i][1] - ring[i][0]*ring[i-1][1]
+	}
+	return sum / 2
+}
+
+// ReverseRing кольцо с обратным направлением обхода
+func ReverseRing(ring [][]float64) [][]float64 {
+	reversed := make([][]float64, len(ring))
+	for i, point := range ring {
+		reversed[len(ring)-1-i] = point
+	}
+	return reversed
+}
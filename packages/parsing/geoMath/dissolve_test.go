@@ -0,0 +1,93 @@
+package geoMath
+
+import "testing"
+
+// square квадрат со стороной size от (x, y) против часовой стрелки
+func square(x, y, size float64) [][]float64 {
+	return [][]float64{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+}
+
+// polygonArea площадь полигона с учетом дыр
+func polygonArea(polygon [][][]float64) float64 {
+	area := 0.0
+	for _, ring := range polygon {
+		area += PlanarArea(ring)
+	}
+	return area
+}
+
+func TestDissolveAdjacentPair(t *testing.T) {
+	polygons := DissolvePolygons([][][][][]float64{
+		{{square(0, 0, 1)}},
+		{{square(1, 0, 1)}},
+	})
+	if len(polygons) != 1 || len(polygons[0]) != 1 {
+		t.Fatalf("ожидался один полигон без дыр, получено %v", polygons)
+	}
+	if area := PlanarArea(polygons[0][0]); area != 2 {
+		t.Errorf("площадь %v, ожидалось 2 (против часовой стрелки)", area)
+	}
+	if hasEdgeAlongX(polygons, 1) {
+		t.Errorf("общая граница осталась: %v", polygons)
+	}
+}
+
+func TestDissolveRingFormsHole(t *testing.T) {
+	// Четыре области вокруг квадрата (1,1)-(2,2), который никому не принадлежит;
+	// на общих сторонах у соседей одинаковые вершины
+	polygons := DissolvePolygons([][][][][]float64{
+		{{{{0, 0}, {3, 0}, {3, 1}, {2, 1}, {1, 1}, {0, 1}, {0, 0}}}}, // низ
+		{{{{0, 2}, {1, 2}, {2, 2}, {3, 2}, {3, 3}, {0, 3}, {0, 2}}}}, // верх
+		{{{{0, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 1}}}},                 // лево
+		{{{{2, 1}, {3, 1}, {3, 2}, {2, 2}, {2, 1}}}},                 // право
+	})
+	if len(polygons) != 1 || len(polygons[0]) != 2 {
+		t.Fatalf("ожидался один полигон с дырой, получено %v", polygons)
+	}
+	if area := PlanarArea(polygons[0][0]); area != 9 {
+		t.Errorf("площадь внешнего кольца %v, ожидалось 9", area)
+	}
+	if area := PlanarArea(polygons[0][1]); area != -1 {
+		t.Errorf("площадь дыры %v, ожидалось -1 (по часовой стрелке)", area)
+	}
+	if area := polygonArea(polygons[0]); area != 8 {
+		t.Errorf("площадь полигона %v, ожидалось 8", area)
+	}
+}
+
+func TestDissolveSeparateMembers(t *testing.T) {
+	polygons := DissolvePolygons([][][][][]float64{
+		{{square(0, 0, 1)}, {square(5, 5, 1)}},
+	})
+	if len(polygons) != 2 {
+		t.Fatalf("ожидалось два полигона, получено %d", len(polygons))
+	}
+}
+
+func TestDissolveMisalignedVertices(t *testing.T) {
+	// У правого квадрата на общей стороне есть лишняя вершина (1, 0.5): ребра не совпадают,
+	// поэтому граница между соседями не удаляется (условие применимости DissolvePolygons)
+	right := [][]float64{{1, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 0.5}, {1, 0}}
+	polygons := DissolvePolygons([][][][][]float64{
+		{{square(0, 0, 1)}},
+		{{right}},
+	})
+
+	if !hasEdgeAlongX(polygons, 1) {
+		t.Errorf("граница между соседями с разными вершинами пропала: %v", polygons)
+	}
+}
+
+// hasEdgeAlongX есть ли в результате ребро на вертикали x
+func hasEdgeAlongX(polygons [][][][]float64, x float64) bool {
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				if ring[i-1][0] == x && ring[i][0] == x {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package geoMath
+
+import "math"
+
+// EarthRadiusMeters средний радиус Земли
+const EarthRadiusMeters = 6371008.8
+
+// toRad переводит градусы в радианы
+func toRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// HaversineMeters расстояние по большому кругу между двумя точками в метрах
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BBox прямоугольник в координатах lon/lat
+type BBox struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// EmptyBBox возвращает пустой прямоугольник, готовый к расширению
+func EmptyBBox() BBox {
+	return BBox{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+}
+
+// Extend расширяет прямоугольник точкой
+func (b *BBox) Extend(x, y float64) {
+	b.MinX = math.Min(b.MinX, x)
+	b.MinY = math.Min(b.MinY, y)
+	b.MaxX = math.Max(b.MaxX, x)
+	b.MaxY = math.Max(b.MaxY, y)
+}
+
+// Union расширяет прямоугольник другим прямоугольником
+func (b *BBox) Union(o BBox) {
+	b.Extend(o.MinX, o.MinY)
+	b.Extend(o.MaxX, o.MaxY)
+}
+
+// Contains проверяет попадание точки в прямоугольник
+func (b BBox) Contains(x, y float64) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY
+}
+
+// Intersects проверяет пересечение прямоугольников
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinX <= o.MaxX && o.MinX <= b.MaxX && b.MinY <= o.MaxY && o.MinY <= b.MaxY
+}
+
+// RingBBox охватывающий прямоугольник кольца
+func RingBBox(ring [][]float64) BBox {
+	b := EmptyBBox()
+	for _, p := range ring {
+		if len(p) >= 2 {
+			b.Extend(p[0], p[1])
+		}
+	}
+	return b
+}
+
+// Point точка с идентификатором для пакетных запросов
+type Point struct {
+	ID  string
+	Lat float64
+	Lon float64
+}
+
+// PointInRing проверка попадания точки в кольцо методом трассировки луча
+func PointInRing(x, y float64, ring [][]float64) bool {
+	inside := false
+	n := len(ring)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		if len(ring[i]) < 2 || len(ring[j]) < 2 {
+			continue
+		}
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PointInPolygon проверяет попадание точки во внешнее кольцо с учетом дыр
+func PointInPolygon(x, y float64, rings [][][]float64) bool {
+	if len(rings) == 0 || !PointInRing(x, y, rings[0]) {
+		return false
+	}
+	for _, hole := range rings[1:] {
+		if PointInRing(x, y, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// PointInMultiPolygon проверяет попадание точки в любой из полигонов
+func PointInMultiPolygon(x, y float64, polygons [][][][]float64) bool {
+	for _, polygon := range polygons {
+		if len(polygon) > 0 && RingBBox(polygon[0]).Contains(x, y) && PointInPolygon(x, y, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// metersPerDegree длина градуса широты в метрах
+const metersPerDegree = 111320.0
+
+// DegreesForMeters переводит расстояние в метрах в приращения широты и долготы около точки
+func DegreesForMeters(lat, meters float64) (dLat, dLon float64) {
+	dLat = meters / metersPerDegree
+	cosLat := math.Max(math.Cos(toRad(lat)), 0.01)
+	dLon = math.Min(dLat/cosLat, 180)
+	return dLat, dLon
+}
+
+// DistanceToRingMeters кратчайшее расстояние от точки до границы кольца
+// в локальной равнопромежуточной проекции (достаточно для расстояний до десятков км)
+func DistanceToRingMeters(lat, lon float64, ring [][]float64) float64 {
+	cosLat := math.Cos(toRad(lat))
+	best := math.Inf(1)
+
+	for i := 1; i < len(ring); i++ {
+		ax := (ring[i-1][0] - lon) * cosLat * metersPerDegree
+		ay := (ring[i-1][1] - lat) * metersPerDegree
+		bx := (ring[i][0] - lon) * cosLat * metersPerDegree
+		by := (ring[i][1] - lat) * metersPerDegree
+
+		// Проекция начала координат (точки) на отрезок AB
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if lenSq := dx*dx + dy*dy; lenSq > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+		}
+		px, py := ax+t*dx, ay+t*dy
+		best = math.Min(best, math.Hypot(px, py))
+	}
+
+	return best
+}
+
+// RingAreaMeters площадь кольца на сфере в м² (без учета направления обхода)
+func RingAreaMeters(ring [][]float64) float64 {
+	if len(ring) < 4 {
+		return 0
+	}
+
+	var sum float64
+	for i := 1; i < len(ring); i++ {
+		lon1, lat1 := toRad(ring[i-1][0]), toRad(ring[i-1][1])
+		lon2, lat2 := toRad(ring[i][0]), toRad(ring[i][1])
+		sum += (lon2 - lon1) * (2 + math.Sin(lat1) + math.Sin(lat2))
+	}
+	return math.Abs(sum * EarthRadiusMeters * EarthRadiusMeters / 2)
+}
+
+// MultiPolygonAreaKm2 площадь полигонов в км²: внешние кольца за вычетом дыр
+func MultiPolygonAreaKm2(polygons [][][][]float64) float64 {
+	var area float64
+	for _, polygon := range polygons {
+		for i, ring := range polygon {
+			if i == 0 {
+				area += RingAreaMeters(ring)
+			} else {
+				area -= RingAreaMeters(ring)
+			}
+		}
+	}
+	return area / 1e6
+}
+
+// SegmentsIntersect проверяет пересечение отрезков AB и CD на плоскости lon/lat (включая касание)
+func SegmentsIntersect(a, b, c, d []float64) bool {
+	cross := func(o, p, q []float64) float64 {
+		return (p[0]-o[0])*(q[1]-o[1]) - (p[1]-o[1])*(q[0]-o[0])
+	}
+	onSegment := func(p, q, r []float64) bool {
+		return math.Min(p[0], r[0]) <= q[0] && q[0] <= math.Max(p[0], r[0]) &&
+			math.Min(p[1], r[1]) <= q[1] && q[1] <= math.Max(p[1], r[1])
+	}
+
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(c, a, d)) || (d2 == 0 && onSegment(c, b, d)) ||
+		(d3 == 0 && onSegment(a, c, b)) || (d4 == 0 && onSegment(a, d, b))
+}
@@ -8,6 +8,12 @@ import (
 	"sync"
 	"time"
 
+	"project/packages/parsing"
+	coorinates "project/packages/parsing/coordinates"
+	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoMath"
+	"project/packages/parsing/geoTree"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,54 +26,96 @@ import (
 type FlightData struct {
 	ID        primitive.ObjectID `bson:"_id"`
 	Departure struct {
-		Coordinates *Coordinate `bson:"coordinates,omitempty"`
+		Coordinates       *Coordinate `bson:"coordinates,omitempty"`
+		CoordinatesSource string      `bson:"coordinatesSource,omitempty"`
 	} `bson:"dep"`
+	Arrival struct {
+		Coordinates *Coordinate `bson:"coordinates,omitempty"`
+	} `bson:"arr"`
 	SHRData struct {
 		CoordinatesDep *Coordinate `bson:"coordinatesDep,omitempty"`
+		CoordinatesArr *Coordinate `bson:"coordinatesArr,omitempty"`
 	} `bson:"shr"`
 }
 
 // UpdateOperation операция обновления
 type UpdateOperation struct {
-	ID      primitive.ObjectID
-	Subject string
+	ID         primitive.ObjectID
+	Subject    string
+	District   string
+	ArrSubject string
+	Match      parsing.RegionMatch
 }
 
 // Coordinate структура координат
-type Coordinate struct {
-	Lat float64 `bson:"lat"`
-	Lon float64 `bson:"lon"`
-}
+type Coordinate = coorinates.Coordinate
 
 // GeoService сервис для геопространственного поиска с 2dsphere индексом
 type GeoService struct {
 	client            *mongo.Client
 	regionsCollection *mongo.Collection
-	cache             *RegionCache // Кэш для часто запрашиваемых регионов
+	cache             *RegionCache                // Общий LRU-кэш результатов поиска по коллекции
+	geometries        *GeometryCache              // Подготовленные геометрии регионов для пакетного поиска
+	undefinedName     string                      // Значение, если точка не попала ни в один регион
+	sharedIndex       func() *geoTree.RegionIndex // Индекс в памяти, если загружен
+	districts         *GeoService                 // Поиск муниципальных районов (nil, если районы не загружены)
 }
 
-// RegionCache кэш для хранения результатов поиска регионов
-type RegionCache struct {
-	sync.RWMutex
-	data map[CacheKey]string
-}
-
-type CacheKey struct {
-	lat float64
-	lon float64
+// GeometryCache кэш подготовленных геометрий регионов по названию
+type GeometryCache struct {
+	sync.Mutex
+	data map[string]*geoTree.PreparedRegion
 }
 
 // NewGeoService создает новый геосервис
 func NewGeoService(client *mongo.Client) *GeoService {
+	gs := newGeoService(client, "regionsGeo", "Регион не определен", geoTree.Shared)
+
+	// Муниципальные районы подключаем, только если они загружены
+	districts := newGeoService(client, geoIndex.DistrictsCollectionName, "Район не определен", func() *geoTree.RegionIndex {
+		if index := geoTree.Shared(); index != nil {
+			return index.Districts()
+		}
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if count, err := districts.regionsCollection.EstimatedDocumentCount(ctx); err == nil && count > 0 {
+		gs.districts = districts
+	}
+
+	return gs
+}
+
+func newGeoService(client *mongo.Client, collectionName, undefinedName string, sharedIndex func() *geoTree.RegionIndex) *GeoService {
 	return &GeoService{
 		client:            client,
-		regionsCollection: client.Database("admin").Collection("regionsGeo"),
-		cache: &RegionCache{
-			data: make(map[CacheKey]string),
+		regionsCollection: client.Database("admin").Collection(collectionName),
+		cache:             sharedRegionCache(collectionName),
+		geometries: &GeometryCache{
+			data: make(map[string]*geoTree.PreparedRegion),
 		},
+		undefinedName: undefinedName,
+		sharedIndex:   sharedIndex,
 	}
 }
 
+// FindDistrictForPoint ищет муниципальный район точки
+func (gs *GeoService) FindDistrictForPoint(lat, lon float64) (string, error) {
+	if gs.districts == nil {
+		return "", nil
+	}
+	return gs.districts.FindRegionForPoint(lat, lon)
+}
+
+// FindDistrictsForPointsBatch пакетный поиск муниципальных районов
+func (gs *GeoService) FindDistrictsForPointsBatch(points []geoMath.Point) (map[string]string, error) {
+	if gs.districts == nil {
+		return map[string]string{}, nil
+	}
+	return gs.districts.FindRegionsForPointsBatch(points)
+}
+
 // FindRegionForPoint использует 2dsphere индекс для поиска региона (ОПТИМИЗИРОВАННАЯ ВЕРСИЯ)
 func (gs *GeoService) FindRegionForPoint(lat, lon float64) (string, error) {
 	// Проверяем кэш сначала
@@ -75,6 +123,13 @@ func (gs *GeoService) FindRegionForPoint(lat, lon float64) (string, error) {
 		return cached, nil
 	}
 
+	// Индекс в памяти отвечает без обращения к базе
+	if index := gs.sharedIndex(); index != nil {
+		regionName, _ := index.FindRegionForPoint(lat, lon)
+		gs.setCache(lat, lon, regionName)
+		return regionName, nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second) // Уменьшаем таймаут
 	defer cancel()
 
@@ -97,7 +152,7 @@ func (gs *GeoService) FindRegionForPoint(lat, lon float64) (string, error) {
 		SetProjection(bson.M{"name": 1}).
 		SetMaxTime(3 * time.Second) // Ограничиваем время выполнения
 
-	regionName := "Регион не определен"
+	regionName := gs.undefinedName
 
 	var result bson.M
 	if err := gs.regionsCollection.FindOne(ctx, filter, opts).Decode(&result); err != nil {
@@ -114,59 +169,103 @@ func (gs *GeoService) FindRegionForPoint(lat, lon float64) (string, error) {
 	return regionName, nil
 }
 
-// FindRegionsForPointsBatch пакетный поиск регионов для нескольких точек
-func (gs *GeoService) FindRegionsForPointsBatch(points []struct {
-	Lat float64
-	Lon float64
-	ID  primitive.ObjectID
-}) (map[primitive.ObjectID]string, error) {
-	results := make(map[primitive.ObjectID]string)
+// FindNearestRegion ищет ближайший регион не дальше maxMeters от точки через $geoNear.
+// Используется для точек, не попавших ни в один полигон (побережье, упрощенные границы)
+func (gs *GeoService) FindNearestRegion(lat, lon, maxMeters float64) (string, float64, error) {
+	if index := gs.sharedIndex(); index != nil {
+		return index.FindNearestRegion(lat, lon, maxMeters)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          bson.M{"type": "Point", "coordinates": []float64{lon, lat}},
+			"key":           "geometry",
+			"distanceField": "distance",
+			"maxDistance":   maxMeters,
+			"spherical":     true,
+		}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$project", Value: bson.M{"name": 1, "distance": 1}}},
+	}
+
+	cursor, err := gs.regionsCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return "", 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Name     string  `bson:"name"`
+		Distance float64 `bson:"distance"`
+	}
+	if !cursor.Next(ctx) {
+		return "", 0, cursor.Err()
+	}
+	if err := cursor.Decode(&result); err != nil {
+		return "", 0, err
+	}
+
+	return result.Name, result.Distance, nil
+}
+
+// FindRegionsForPointsBatch пакетный поиск регионов для нескольких точек.
+// Одним запросом $geoIntersects с MultiPoint находим регионы-кандидаты,
+// затем сопоставляем точки с регионами в памяти по их геометрии
+func (gs *GeoService) FindRegionsForPointsBatch(points []geoMath.Point) (map[string]string, error) {
+	results := make(map[string]string, len(points))
 
 	// Сначала проверяем кэш
+	var pending []geoMath.Point
 	for _, point := range points {
 		if cached := gs.getFromCache(point.Lat, point.Lon); cached != "" {
 			results[point.ID] = cached
+		} else {
+			pending = append(pending, point)
 		}
 	}
 
 	// Если все точки найдены в кэше, возвращаем результат
-	if len(results) == len(points) {
+	if len(pending) == 0 {
 		return results, nil
 	}
 
-	// Ищем оставшиеся точки в базе
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Создаем условия для $or запроса
-	var orConditions []bson.M
-	pointMap := make(map[string]primitive.ObjectID) // Для сопоставления точек с ID
+	// Индекс в памяти отвечает без обращения к базе
+	if index := gs.sharedIndex(); index != nil {
+		for _, point := range pending {
+			regionName, _ := index.FindRegionForPoint(point.Lat, point.Lon)
+			results[point.ID] = regionName
+			gs.setCache(point.Lat, point.Lon, regionName)
+		}
+		return results, nil
+	}
 
-	for _, point := range points {
-		if _, exists := results[point.ID]; exists {
-			continue // Уже найдено в кэше
+	// Уникальные координаты для MultiPoint
+	seen := make(map[CacheKey]bool)
+	var multiPoint [][]float64
+	for _, point := range pending {
+		key := CacheKey{lat: point.Lat, lon: point.Lon}
+		if !seen[key] {
+			seen[key] = true
+			multiPoint = append(multiPoint, []float64{point.Lon, point.Lat})
 		}
+	}
 
-		pointKey := fmt.Sprintf("%.6f:%.6f", point.Lat, point.Lon)
-		pointMap[pointKey] = point.ID
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		orConditions = append(orConditions, bson.M{
-			"geometry": bson.M{
-				"$geoIntersects": bson.M{
-					"$geometry": bson.M{
-						"type":        "Point",
-						"coordinates": []float64{point.Lon, point.Lat},
-					},
+	filter := bson.M{
+		"geometry": bson.M{
+			"$geoIntersects": bson.M{
+				"$geometry": bson.M{
+					"type":        "MultiPoint",
+					"coordinates": multiPoint,
 				},
 			},
-		})
-	}
-
-	if len(orConditions) == 0 {
-		return results, nil
+		},
 	}
-
-	filter := bson.M{"$or": orConditions}
 	opts := options.Find().SetProjection(bson.M{"name": 1})
 
 	cursor, err := gs.regionsCollection.Find(ctx, filter, opts)
@@ -175,45 +274,78 @@ func (gs *GeoService) FindRegionsForPointsBatch(points []struct {
 	}
 	defer cursor.Close(ctx)
 
-	// Обрабатываем результаты
+	var candidateNames []string
 	for cursor.Next(ctx) {
-		var doc bson.M
+		var doc struct {
+			Name string `bson:"name"`
+		}
 		if err := cursor.Decode(&doc); err != nil {
 			continue
 		}
+		candidateNames = append(candidateNames, doc.Name)
+	}
 
-		// Здесь нужна дополнительная логика для сопоставления результатов с точками
-		// В реальном сценарии нужно выполнять отдельные запросы или использовать $geoWithin
+	candidates, err := gs.preparedRegions(candidateNames)
+	if err != nil {
+		return results, err
+	}
+
+	// Сопоставляем точки с регионами-кандидатами
+	for _, point := range pending {
+		regionName := gs.undefinedName
+		for _, region := range candidates {
+			if region.Contains(point.Lat, point.Lon) {
+				regionName = region.Name
+				break
+			}
+		}
+		results[point.ID] = regionName
+		gs.setCache(point.Lat, point.Lon, regionName)
 	}
 
 	return results, nil
 }
 
-// getFromCache получает значение из кэша
-func (gs *GeoService) getFromCache(lat, lon float64) string {
-	gs.cache.RLock()
-	defer gs.cache.RUnlock()
+// preparedRegions возвращает подготовленные геометрии регионов, загружая недостающие из базы
+func (gs *GeoService) preparedRegions(names []string) ([]*geoTree.PreparedRegion, error) {
+	gs.geometries.Lock()
+	defer gs.geometries.Unlock()
 
-	key := CacheKey{lat: lat, lon: lon}
-	if value, exists := gs.cache.data[key]; exists {
-		return value
+	var missing []string
+	for _, name := range names {
+		if _, ok := gs.geometries.data[name]; !ok {
+			missing = append(missing, name)
+		}
 	}
-	return ""
+
+	if len(missing) > 0 {
+		regions, err := geoTree.LoadRegions(gs.regionsCollection, bson.M{"name": bson.M{"$in": missing}})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки геометрии регионов: %v", err)
+		}
+		for _, region := range regions {
+			gs.geometries.data[region.Name] = geoTree.PrepareRegion(region)
+		}
+	}
+
+	result := make([]*geoTree.PreparedRegion, 0, len(names))
+	for _, name := range names {
+		if region, ok := gs.geometries.data[name]; ok {
+			result = append(result, region)
+		}
+	}
+	return result, nil
+}
+
+// getFromCache получает значение из кэша
+func (gs *GeoService) getFromCache(lat, lon float64) string {
+	value, _ := gs.cache.Get(lat, lon)
+	return value
 }
 
 // setCache сохраняет значение в кэш
 func (gs *GeoService) setCache(lat, lon float64, value string) {
-	gs.cache.Lock()
-	defer gs.cache.Unlock()
-
-	key := CacheKey{lat: lat, lon: lon}
-	gs.cache.data[key] = value
-
-	// Ограничиваем размер кэша (опционально)
-	if len(gs.cache.data) > 10000 {
-		// Простая стратегия: очищаем кэш когда он становится слишком большим
-		gs.cache.data = make(map[CacheKey]string)
-	}
+	gs.cache.Set(lat, lon, value)
 }
 
 // UpdateFlightRegions ОПТИМИЗИРОВАННАЯ версия
@@ -228,6 +360,7 @@ func UpdateFlightRegions(client *mongo.Client) error {
 	opts := options.Find().SetProjection(bson.M{
 		"_id": 1,
 		"dep": 1,
+		"arr": 1,
 		"shr": 1,
 	})
 
@@ -309,6 +442,9 @@ func UpdateFlightRegions(client *mongo.Client) error {
 	fmt.Printf("   Обработано документов: %d\n", sentCount)
 	fmt.Printf("   Общее время: %v\n", totalTime.Round(time.Second))
 	fmt.Printf("   Средняя скорость: %.1f документов/сек\n", float64(sentCount)/totalTime.Seconds())
+	stats := geoService.cache.Stats()
+	fmt.Printf("   Кэш регионов: %d записей, попаданий %d, промахов %d (%.1f%%)\n",
+		stats.Size, stats.Hits, stats.Misses, stats.HitRate*100)
 
 	return nil
 }
@@ -340,31 +476,89 @@ func (gs *GeoService) regionWorkerOptimized(id int, jobs <-chan FlightData, resu
 	fmt.Printf("  ✅ Worker %d завершил (%d записей)\n", id, processed)
 }
 
-// processBatch обрабатывает батч точек
+// processBatch обрабатывает батч точек одним пакетным запросом
 func (gs *GeoService) processBatch(batch []FlightData, results chan<- UpdateOperation) {
+	var points, altPoints, arrPoints []geoMath.Point
+	depPoints := make(map[string]geoMath.Point)
+	sources := make(map[string][]parsing.RegionPoint)
+	arrByID := make(map[string]geoMath.Point)
 	for _, job := range batch {
-		var subjectName string = "Регион не определен"
-
-		// Определяем координаты
-		var lat, lon float64
-		if job.Departure.Coordinates != nil {
-			lat = job.Departure.Coordinates.Lat
-			lon = job.Departure.Coordinates.Lon
-		} else if job.SHRData.CoordinatesDep != nil {
-			lat = job.SHRData.CoordinatesDep.Lat
-			lon = job.SHRData.CoordinatesDep.Lon
+		// Определяем координаты: основной источник и второй для сверки региона
+		departure := parsing.DeparturePoints(job.Departure.Coordinates, job.Departure.CoordinatesSource, job.SHRData.CoordinatesDep)
+		if len(departure) > 0 {
+			point := geoMath.Point{ID: job.ID.Hex(), Lat: departure[0].Lat, Lon: departure[0].Lon}
+			points = append(points, point)
+			depPoints[point.ID] = point
+			sources[point.ID] = departure
+		}
+		if len(departure) > 1 {
+			altPoints = append(altPoints, geoMath.Point{ID: job.ID.Hex(), Lat: departure[1].Lat, Lon: departure[1].Lon})
+		}
+
+		// Координаты посадки: из arr, затем DEST/ из SHR
+		arr := job.Arrival.Coordinates
+		if arr == nil {
+			arr = job.SHRData.CoordinatesArr
+		}
+		if arr != nil && (arr.Lat != 0 || arr.Lon != 0) {
+			point := geoMath.Point{ID: job.ID.Hex(), Lat: arr.Lat, Lon: arr.Lon}
+			arrPoints = append(arrPoints, point)
+			arrByID[point.ID] = point
 		}
+	}
+
+	// Ищем регионы через 2dsphere индекс
+	regions, err := gs.FindRegionsForPointsBatch(points)
+	if err != nil {
+		log.Printf("⚠️ Ошибка пакетного поиска регионов: %v", err)
+	}
+
+	// При ошибке сверка пропускается, как в CreateFlightDataWithRegion: пустой регион дал бы ложный конфликт
+	altRegions, err := gs.FindRegionsForPointsBatch(altPoints)
+	if err != nil {
+		log.Printf("⚠️ Ошибка пакетного поиска регионов по координатам SHR: %v", err)
+		altRegions = nil
+	}
+
+	districts, err := gs.FindDistrictsForPointsBatch(points)
+	if err != nil {
+		log.Printf("⚠️ Ошибка пакетного поиска районов: %v", err)
+	}
+
+	arrRegions, err := gs.FindRegionsForPointsBatch(arrPoints)
+	if err != nil {
+		log.Printf("⚠️ Ошибка пакетного поиска регионов посадки: %v", err)
+	}
 
-		// Ищем регион через 2dsphere индекс
-		if lat != 0 || lon != 0 {
-			if regionName, err := gs.FindRegionForPoint(lat, lon); err == nil {
-				subjectName = regionName
+	for _, job := range batch {
+		subjectName := gs.undefinedName
+		match := parsing.RegionMatch{Method: parsing.MatchNone, Confidence: parsing.ConfidenceLow}
+		if point, ok := depPoints[job.ID.Hex()]; ok {
+			// Для точек вне полигонов пробуем ближайший регион
+			subjectName, match = parsing.MatchRegion(gs, regions[job.ID.Hex()], point.Lat, point.Lon)
+
+			// Сверка со вторым источником координат
+			departure := sources[job.ID.Hex()]
+			var alternative *parsing.RegionPoint
+			var altRegion string
+			if found, ok := altRegions[job.ID.Hex()]; ok && len(departure) > 1 {
+				alternative = &departure[1]
+				altRegion, _ = parsing.MatchRegion(gs, found, alternative.Lat, alternative.Lon)
 			}
+			match.SetSources(subjectName, departure[0], alternative, altRegion)
+		}
+
+		arrSubject := arrRegions[job.ID.Hex()]
+		if point, ok := arrByID[job.ID.Hex()]; ok && arrSubject == gs.undefinedName {
+			arrSubject, _ = parsing.MatchRegion(gs, arrSubject, point.Lat, point.Lon)
 		}
 
 		results <- UpdateOperation{
-			ID:      job.ID,
-			Subject: subjectName,
+			ID:         job.ID,
+			Subject:    subjectName,
+			District:   districts[job.ID.Hex()],
+			ArrSubject: arrSubject,
+			Match:      match,
 		}
 	}
 }
@@ -383,9 +577,20 @@ func (gs *GeoService) resultCollectorOptimized(
 	for result := range results {
 		processedCount++
 
+		set := bson.M{"region": result.Subject, "regionMatch": result.Match}
+		if result.District != "" {
+			set["district"] = result.District
+		}
+		if result.ArrSubject != "" {
+			set["arrRegion"] = result.ArrSubject
+		}
+		// Межрегиональный полет: оба региона определены и различаются
+		set["crossRegion"] = result.ArrSubject != "" && result.ArrSubject != gs.undefinedName &&
+			result.Subject != gs.undefinedName && result.ArrSubject != result.Subject
+
 		update := mongo.NewUpdateOneModel().
 			SetFilter(bson.M{"_id": result.ID}).
-			SetUpdate(bson.M{"$set": bson.M{"region": result.Subject}})
+			SetUpdate(bson.M{"$set": set})
 		operations = append(operations, update)
 
 		if len(operations) >= batchSize {
@@ -0,0 +1,182 @@
+package geoSearch
+
+import (
+	"container/list"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// defaultRegionCacheSize размер кэша по умолчанию (REGION_CACHE_SIZE)
+const defaultRegionCacheSize = 100000
+
+// CacheKey ключ кэша: координаты точки, округленные до узла сетки квантования
+type CacheKey struct {
+	lat float64
+	lon float64
+}
+
+// cacheEntry элемент списка LRU
+type cacheEntry struct {
+	key   CacheKey
+	value string
+}
+
+// CacheStats метрики кэша регионов
+type CacheStats struct {
+	Size      int     `json:"size"`
+	Capacity  int     `json:"capacity"`
+	Quantum   float64 `json:"quantum"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hitRate"`
+}
+
+// RegionCache ограниченный LRU-кэш результатов поиска регионов.
+// При quantum > 0 координаты округляются до сетки с шагом quantum градусов,
+// и близкие точки получают общий результат. Для точек у самой границы
+// регион может определиться по соседней точке сетки - шаг 1e-4° (~11 м) это допускает
+type RegionCache struct {
+	sync.Mutex
+	capacity  int
+	quantum   float64
+	items     map[CacheKey]*list.Element
+	order     *list.List // Начало списка - недавно использованные
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// NewRegionCache создает кэш на capacity записей с шагом квантования quantum (0 - без квантования)
+func NewRegionCache(capacity int, quantum float64) *RegionCache {
+	if capacity < 1 {
+		capacity = defaultRegionCacheSize
+	}
+	return &RegionCache{
+		capacity: capacity,
+		quantum:  math.Max(quantum, 0),
+		items:    make(map[CacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// key ключ точки с учетом квантования
+func (c *RegionCache) key(lat, lon float64) CacheKey {
+	if c.quantum == 0 {
+		return CacheKey{lat: lat, lon: lon}
+	}
+	return CacheKey{lat: math.Round(lat / c.quantum), lon: math.Round(lon / c.quantum)}
+}
+
+// Get возвращает регион точки и отмечает запись как недавно использованную
+func (c *RegionCache) Get(lat, lon float64) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	element, ok := c.items[c.key(lat, lon)]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true
+}
+
+// Set сохраняет регион точки, вытесняя давно не использованные записи
+func (c *RegionCache) Set(lat, lon float64, value string) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := c.key(lat, lon)
+	if element, ok := c.items[key]; ok {
+		element.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Reset очищает кэш и метрики (после перезагрузки границ регионов)
+func (c *RegionCache) Reset() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.items = make(map[CacheKey]*list.Element)
+	c.order.Init()
+	c.hits, c.misses, c.evictions = 0, 0, 0
+}
+
+// Stats возвращает текущие метрики кэша
+func (c *RegionCache) Stats() CacheStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := CacheStats{
+		Size:      c.order.Len(),
+		Capacity:  c.capacity,
+		Quantum:   c.quantum,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = math.Round(float64(c.hits)/float64(total)*1000) / 1000
+	}
+	return stats
+}
+
+// sharedCaches кэши, общие для всех экземпляров GeoService: по одному на коллекцию границ.
+// uploadFiles создает новый GeoService на каждую загрузку, а кэш при этом сохраняется
+var sharedCaches = struct {
+	sync.Mutex
+	data map[string]*RegionCache
+}{data: make(map[string]*RegionCache)}
+
+// sharedRegionCache возвращает общий кэш коллекции, создавая его при первом обращении.
+// Размер и шаг квантования задаются REGION_CACHE_SIZE и REGION_CACHE_QUANTUM
+func sharedRegionCache(collectionName string) *RegionCache {
+	sharedCaches.Lock()
+	defer sharedCaches.Unlock()
+
+	if cache, ok := sharedCaches.data[collectionName]; ok {
+		return cache
+	}
+
+	capacity, _ := strconv.Atoi(os.Getenv("REGION_CACHE_SIZE"))
+	quantum, _ := strconv.ParseFloat(os.Getenv("REGION_CACHE_QUANTUM"), 64)
+	cache := NewRegionCache(capacity, quantum)
+	sharedCaches.data[collectionName] = cache
+	return cache
+}
+
+// CacheStatsByCollection метрики общих кэшей по коллекциям границ
+func CacheStatsByCollection() map[string]CacheStats {
+	sharedCaches.Lock()
+	defer sharedCaches.Unlock()
+
+	stats := make(map[string]CacheStats, len(sharedCaches.data))
+	for name, cache := range sharedCaches.data {
+		stats[name] = cache.Stats()
+	}
+	return stats
+}
+
+// ResetCaches очищает общие кэши, например после загрузки новых границ
+func ResetCaches() {
+	sharedCaches.Lock()
+	defer sharedCaches.Unlock()
+
+	for _, cache := range sharedCaches.data {
+		cache.Reset()
+	}
+}
@@ -0,0 +1,326 @@
+package geoTree
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Region регион с геометрией в виде набора полигонов (кольца lon/lat)
+type Region struct {
+	Name     string
+	Polygons [][][][]float64
+}
+
+// PreparedRegion регион, подготовленный к быстрой проверке попадания точки
+type PreparedRegion struct {
+	Name     string
+	boxes    []geoMath.BBox
+	polygons []preparedPolygon
+}
+
+// PrepareRegion раскладывает рёбра полигонов региона по полосам
+func PrepareRegion(region Region) *PreparedRegion {
+	pr := &PreparedRegion{Name: region.Name}
+	for _, polygon := range region.Polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		pr.boxes = append(pr.boxes, geoMath.RingBBox(polygon[0]))
+		pr.polygons = append(pr.polygons, preparePolygon(polygon))
+	}
+	return pr
+}
+
+// Contains проверяет попадание точки в регион
+func (pr *PreparedRegion) Contains(lat, lon float64) bool {
+	for i := range pr.polygons {
+		if pr.boxes[i].Contains(lon, lat) && pr.polygons[i].contains(lon, lat) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegionIndex индекс регионов в памяти: R-дерево по полигонам
+// и точная проверка попадания трассировкой луча с учетом дыр
+type RegionIndex struct {
+	regions   []*PreparedRegion
+	root      *rtreeNode
+	undefined string       // Значение, если точка не попала ни в один регион
+	districts *RegionIndex // Индекс муниципальных районов (может отсутствовать)
+}
+
+// NewRegionIndex строит индекс по списку регионов
+func NewRegionIndex(regions []Region) *RegionIndex {
+	prepared := make([]*PreparedRegion, len(regions))
+	var entries []rtreeEntry
+	for ri, region := range regions {
+		prepared[ri] = PrepareRegion(region)
+		for pi, box := range prepared[ri].boxes {
+			entries = append(entries, rtreeEntry{box: box, region: ri, polygon: pi})
+		}
+	}
+
+	return &RegionIndex{
+		regions:   prepared,
+		root:      buildRTree(entries),
+		undefined: "Регион не определен",
+	}
+}
+
+// WithUndefinedName задает значение для точек вне всех регионов
+func (ri *RegionIndex) WithUndefinedName(name string) *RegionIndex {
+	ri.undefined = name
+	return ri
+}
+
+// SetDistricts подключает индекс муниципальных районов
+func (ri *RegionIndex) SetDistricts(districts *RegionIndex) {
+	ri.districts = districts
+}
+
+// Districts возвращает индекс муниципальных районов
+func (ri *RegionIndex) Districts() *RegionIndex {
+	return ri.districts
+}
+
+// Len количество регионов в индексе
+func (ri *RegionIndex) Len() int {
+	return len(ri.regions)
+}
+
+// Lookup возвращает регион, содержащий точку
+func (ri *RegionIndex) Lookup(lat, lon float64) (string, bool) {
+	found := -1
+	ri.root.search(lon, lat, func(e rtreeEntry) {
+		// При перекрытии границ выбираем регион с меньшим индексом для детерминированности
+		if found != -1 && e.region >= found {
+			return
+		}
+		if ri.regions[e.region].polygons[e.polygon].contains(lon, lat) {
+			found = e.region
+		}
+	})
+
+	if found == -1 {
+		return "", false
+	}
+	return ri.regions[found].Name, true
+}
+
+// FindRegionForPoint реализует parsing.GeoServiceInterface без обращения к MongoDB
+func (ri *RegionIndex) FindRegionForPoint(lat, lon float64) (string, error) {
+	if name, ok := ri.Lookup(lat, lon); ok {
+		return name, nil
+	}
+	return ri.undefined, nil
+}
+
+// FindRegionsForPointsBatch определяет регионы для набора точек, ключ результата - ID точки
+func (ri *RegionIndex) FindRegionsForPointsBatch(points []geoMath.Point) (map[string]string, error) {
+	results := make(map[string]string, len(points))
+	for _, point := range points {
+		results[point.ID], _ = ri.FindRegionForPoint(point.Lat, point.Lon)
+	}
+	return results, nil
+}
+
+// FindNearestRegion ищет ближайший регион не дальше maxMeters от точки.
+// Возвращает название и расстояние до границы в метрах
+func (ri *RegionIndex) FindNearestRegion(lat, lon, maxMeters float64) (string, float64, error) {
+	dLat, dLon := geoMath.DegreesForMeters(lat, maxMeters)
+	box := geoMath.BBox{MinX: lon - dLon, MinY: lat - dLat, MaxX: lon + dLon, MaxY: lat + dLat}
+
+	bestName := ""
+	bestDistance := math.Inf(1)
+	ri.root.searchBox(box, func(e rtreeEntry) {
+		for _, ring := range ri.regions[e.region].polygons[e.polygon].rings() {
+			if d := geoMath.DistanceToRingMeters(lat, lon, ring); d < bestDistance {
+				bestDistance = d
+				bestName = ri.regions[e.region].Name
+			}
+		}
+	})
+
+	if bestName == "" || bestDistance > maxMeters {
+		return "", 0, nil
+	}
+	return bestName, bestDistance, nil
+}
+
+// FindDistrictForPoint ищет муниципальный район точки
+func (ri *RegionIndex) FindDistrictForPoint(lat, lon float64) (string, error) {
+	if ri.districts == nil {
+		return "", nil
+	}
+	return ri.districts.FindRegionForPoint(lat, lon)
+}
+
+// FindDistrictsForPointsBatch пакетный поиск муниципальных районов
+func (ri *RegionIndex) FindDistrictsForPointsBatch(points []geoMath.Point) (map[string]string, error) {
+	if ri.districts == nil {
+		return map[string]string{}, nil
+	}
+	return ri.districts.FindRegionsForPointsBatch(points)
+}
+
+// LoadFromDir загружает регионы из GeoJSON файлов папки,
+// а муниципальные районы - из её подпапки districts, если она есть
+func LoadFromDir(dir string) (*RegionIndex, error) {
+	index, err := loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	districtsDir := filepath.Join(dir, geoIndex.DistrictsDirName)
+	if _, err := os.Stat(districtsDir); err == nil {
+		districts, err := loadDir(districtsDir)
+		if err != nil {
+			log.Printf("⚠️ Ошибка загрузки муниципальных районов: %v", err)
+		} else {
+			index.SetDistricts(districts.WithUndefinedName("Район не определен"))
+		}
+	}
+
+	return index, nil
+}
+
+// loadDir строит индекс по GeoJSON файлам папки
+func loadDir(dir string) (*RegionIndex, error) {
+	files, err := geoIndex.GetGeoJSONFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения папки: %v", err)
+	}
+
+	var regions []Region
+	for _, file := range files {
+		geoJSONFile, err := geoIndex.ReadGeoJSONFile(file)
+		if err != nil {
+			log.Printf("⚠️ Ошибка обработки файла %s: %v", file, err)
+			continue
+		}
+
+		for i, feature := range geoJSONFile.Features {
+			name := geoIndex.FeatureName(feature.Properties, i)
+			polygons, err := decodePolygons(feature.Geometry.Type, func(v any) error {
+				return json.Unmarshal(feature.Geometry.Coordinates, v)
+			})
+			if err == nil {
+				polygons, _, err = geoIndex.NormalizeAntimeridian(polygons)
+			}
+			if err != nil {
+				log.Printf("⚠️ Ошибка геометрии региона %s в файле %s: %v", name, filepath.Base(file), err)
+				continue
+			}
+			regions = append(regions, Region{Name: name, Polygons: polygons})
+		}
+	}
+
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("не удалось загрузить ни одного региона из %s", dir)
+	}
+
+	return NewRegionIndex(regions), nil
+}
+
+// LoadFromCollection загружает все регионы из коллекции regionsGeo
+func LoadFromCollection(collection *mongo.Collection) (*RegionIndex, error) {
+	regions, err := LoadRegions(collection, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("коллекция регионов пуста")
+	}
+
+	return NewRegionIndex(regions), nil
+}
+
+// LoadRegions читает регионы с геометрией из коллекции по фильтру
+func LoadRegions(collection *mongo.Collection, filter bson.M) ([]Region, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска регионов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var regions []Region
+	for cursor.Next(ctx) {
+		var doc struct {
+			Name     string `bson:"name"`
+			Geometry struct {
+				Type        string        `bson:"type"`
+				Coordinates bson.RawValue `bson:"coordinates"`
+			} `bson:"geometry"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("⚠️ Ошибка декодирования региона: %v", err)
+			continue
+		}
+
+		polygons, err := decodePolygons(doc.Geometry.Type, doc.Geometry.Coordinates.Unmarshal)
+		if err != nil {
+			log.Printf("⚠️ Ошибка геометрии региона %s: %v", doc.Name, err)
+			continue
+		}
+		regions = append(regions, Region{Name: doc.Name, Polygons: polygons})
+	}
+
+	return regions, nil
+}
+
+// decodePolygons приводит Polygon и MultiPolygon к списку полигонов
+func decodePolygons(geoType string, decode func(v any) error) ([][][][]float64, error) {
+	switch geoType {
+	case "Polygon":
+		var coords [][][]float64
+		if err := decode(&coords); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга Polygon координат: %v", err)
+		}
+		return [][][][]float64{coords}, nil
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := decode(&coords); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга MultiPolygon координат: %v", err)
+		}
+		return coords, nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип геометрии: %s", geoType)
+	}
+}
+
+var (
+	sharedMu sync.RWMutex
+	shared   *RegionIndex
+)
+
+// Shared возвращает общий индекс регионов процесса (nil, если не загружен)
+func Shared() *RegionIndex {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return shared
+}
+
+// SetShared атомарно заменяет общий индекс регионов
+func SetShared(ri *RegionIndex) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = ri
+}
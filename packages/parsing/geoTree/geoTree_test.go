@@ -0,0 +1,107 @@
+package geoTree
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// benchmarkPoints случайные точки в охвате России, одинаковые для всех прогонов
+func benchmarkPoints(n int) [][2]float64 {
+	rng := rand.New(rand.NewSource(1))
+	points := make([][2]float64, n)
+	for i := range points {
+		points[i] = [2]float64{41 + rng.Float64()*37, 19 + rng.Float64()*161}
+	}
+	return points
+}
+
+// loadBenchmarkIndex индекс по geojsonFiles из корня репозитория
+func loadBenchmarkIndex(b *testing.B) *RegionIndex {
+	dir := filepath.Join("..", "..", "..", "geojsonFiles")
+	if _, err := os.Stat(dir); err != nil {
+		b.Skipf("нет папки %s", dir)
+	}
+	index, err := LoadFromDir(dir)
+	if err != nil {
+		b.Fatalf("LoadFromDir: %v", err)
+	}
+	return index
+}
+
+// BenchmarkRegionIndexFindRegionForPoint поиск региона в памяти; сравнивается
+// с BenchmarkGeoServiceFindRegionForPoint (запрос $geoIntersects к MongoDB) в geoSearch
+func BenchmarkRegionIndexFindRegionForPoint(b *testing.B) {
+	index := loadBenchmarkIndex(b)
+	points := benchmarkPoints(10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		point := points[i%len(points)]
+		index.FindRegionForPoint(point[0], point[1])
+	}
+}
+
+// rect прямоугольник [minX, maxX] x [minY, maxY] против часовой стрелки
+func rect(minX, minY, maxX, maxY float64) [][]float64 {
+	return [][]float64{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}
+}
+
+// TestRegionIndexLookup индекс в памяти дает те же регионы, что $geoIntersects:
+// точки на границе (внешней и дыр) попадают в регион, на общей границе - в один из соседей
+func TestRegionIndexLookup(t *testing.T) {
+	regions := []Region{
+		{Name: "A", Polygons: [][][][]float64{{rect(0, 0, 10, 10), rect(4, 4, 6, 6)}}}, // с дырой
+		{Name: "B", Polygons: [][][][]float64{{rect(10, 0, 20, 10)}}},                  // общая сторона с A
+		{Name: "C", Polygons: [][][][]float64{{rect(30, 0, 32, 2)}, {rect(40, 0, 42, 2)}}},
+		{Name: "D", Polygons: [][][][]float64{{rect(4.5, 4.5, 5.5, 5.5)}}}, // остров в дыре A
+	}
+	index := NewRegionIndex(regions).WithUndefinedName("-")
+
+	tests := []struct {
+		name     string
+		lon, lat float64
+		want     []string // допустимые ответы
+	}{
+		{"внутри", 2, 2, []string{"A"}},
+		{"в дыре", 4.2, 4.2, []string{"-"}},
+		{"остров в дыре", 5, 5, []string{"D"}},
+		{"граница дыры", 4, 5, []string{"A"}},
+		{"вершина дыры", 6, 6, []string{"A"}},
+		{"общая сторона", 10, 5, []string{"A", "B"}},
+		{"левая сторона", 0, 5, []string{"A"}},
+		{"правая сторона", 20, 5, []string{"B"}},
+		{"верхняя сторона", 15, 10, []string{"B"}},
+		{"угол", 0, 0, []string{"A"}},
+		{"первый полигон мультиполигона", 31, 1, []string{"C"}},
+		{"второй полигон мультиполигона", 41, 1, []string{"C"}},
+		{"граница второго полигона", 42, 2, []string{"C"}},
+		{"между полигонами", 35, 1, []string{"-"}},
+		{"снаружи", -1, 5, []string{"-"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _ := index.FindRegionForPoint(test.lat, test.lon)
+			if !slices.Contains(test.want, got) {
+				t.Errorf("FindRegionForPoint(%v, %v) = %q, ожидалось одно из %q", test.lat, test.lon, got, test.want)
+			}
+
+			// Путь через MongoDB проверяет кандидатов PreparedRegion.Contains
+			var containing []string
+			for _, region := range regions {
+				if PrepareRegion(region).Contains(test.lat, test.lon) {
+					containing = append(containing, region.Name)
+				}
+			}
+			if len(containing) == 0 {
+				containing = []string{"-"}
+			}
+			if !slices.Equal(containing, test.want) {
+				t.Errorf("PreparedRegion.Contains: %q, ожидалось %q", containing, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package geoTree
+
+import "math"
+
+// preparedRing кольцо с рёбрами, разложенными по горизонтальным полосам,
+// чтобы трассировка луча проверяла только рёбра полосы точки
+type preparedRing struct {
+	ring  [][]float64
+	minY  float64
+	maxY  float64
+	step  float64
+	bands [][]int32
+}
+
+// preparedPolygon полигон: внешнее кольцо и дыры
+type preparedPolygon struct {
+	outer preparedRing
+	holes []preparedRing
+}
+
+// edgesPerBand среднее число рёбер на полосу
+const edgesPerBand = 8
+
+func prepareRing(ring [][]float64) preparedRing {
+	pr := preparedRing{ring: ring, minY: math.Inf(1), maxY: math.Inf(-1)}
+	for _, p := range ring {
+		pr.minY = math.Min(pr.minY, p[1])
+		pr.maxY = math.Max(pr.maxY, p[1])
+	}
+
+	n := len(ring)
+	if n < 3 {
+		return pr
+	}
+
+	bandCount := max(1, n/edgesPerBand)
+	pr.step = (pr.maxY - pr.minY) / float64(bandCount)
+	if pr.step <= 0 {
+		pr.step = 1
+		bandCount = 1
+	}
+	pr.bands = make([][]int32, bandCount)
+
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		lo := math.Min(ring[i][1], ring[j][1])
+		hi := math.Max(ring[i][1], ring[j][1])
+		for b := pr.band(lo); b <= pr.band(hi); b++ {
+			pr.bands[b] = append(pr.bands[b], int32(i))
+		}
+	}
+
+	return pr
+}
+
+func (pr *preparedRing) band(y float64) int {
+	b := int((y - pr.minY) / pr.step)
+	return min(max(b, 0), len(pr.bands)-1)
+}
+
+// contains трассировка луча по рёбрам полосы
+func (pr *preparedRing) contains(x, y float64) bool {
+	if len(pr.bands) == 0 || y < pr.minY || y > pr.maxY {
+		return false
+	}
+
+	inside := false
+	n := len(pr.ring)
+	for _, idx := range pr.bands[pr.band(y)] {
+		i := int(idx)
+		j := (i + n - 1) % n
+		xi, yi := pr.ring[i][0], pr.ring[i][1]
+		xj, yj := pr.ring[j][0], pr.ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// onBoundary точка лежит на ребре кольца
+func (pr *preparedRing) onBoundary(x, y float64) bool {
+	if len(pr.bands) == 0 || y < pr.minY || y > pr.maxY {
+		return false
+	}
+
+	n := len(pr.ring)
+	for _, idx := range pr.bands[pr.band(y)] {
+		i := int(idx)
+		j := (i + n - 1) % n
+		xi, yi := pr.ring[i][0], pr.ring[i][1]
+		xj, yj := pr.ring[j][0], pr.ring[j][1]
+		if (xj-xi)*(y-yi)-(yj-yi)*(x-xi) == 0 &&
+			math.Min(xi, xj) <= x && x <= math.Max(xi, xj) && math.Min(yi, yj) <= y && y <= math.Max(yi, yj) {
+			return true
+		}
+	}
+	return false
+}
+
+func preparePolygon(rings [][][]float64) preparedPolygon {
+	pp := preparedPolygon{outer: prepareRing(rings[0])}
+	for _, hole := range rings[1:] {
+		pp.holes = append(pp.holes, prepareRing(hole))
+	}
+	return pp
+}
+
+// contains точка внутри внешнего кольца и вне дыр. Точка на границе (в том числе дыры)
+// считается попавшей, как в $geoIntersects
+func (pp *preparedPolygon) contains(x, y float64) bool {
+	if pp.outer.onBoundary(x, y) {
+		return true
+	}
+	if !pp.outer.contains(x, y) {
+		return false
+	}
+	for i := range pp.holes {
+		if pp.holes[i].onBoundary(x, y) {
+			return true
+		}
+		if pp.holes[i].contains(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// rings исходные кольца полигона: внешнее и дыры
+func (pp *preparedPolygon) rings() [][][]float64 {
+	rings := [][][]float64{pp.outer.ring}
+	for _, hole := range pp.holes {
+		rings = append(rings, hole.ring)
+	}
+	return rings
+}
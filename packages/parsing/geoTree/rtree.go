@@ -0,0 +1,130 @@
+package geoTree
+
+import (
+	"math"
+	"sort"
+
+	"project/packages/parsing/geoMath"
+)
+
+// rtreeNodeSize максимальное число потомков узла R-дерева
+const rtreeNodeSize = 16
+
+// rtreeEntry элемент листа: один полигон региона
+type rtreeEntry struct {
+	box     geoMath.BBox
+	region  int
+	polygon int
+}
+
+// rtreeNode узел статического R-дерева
+type rtreeNode struct {
+	box      geoMath.BBox
+	children []*rtreeNode
+	entries  []rtreeEntry
+}
+
+// buildRTree строит R-дерево упаковкой Sort-Tile-Recursive
+func buildRTree(entries []rtreeEntry) *rtreeNode {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Листья
+	var level []*rtreeNode
+	for _, chunk := range strPack(len(entries), func(i int) geoMath.BBox { return entries[i].box }, func(perm []int) {
+		sorted := make([]rtreeEntry, len(perm))
+		for i, p := range perm {
+			sorted[i] = entries[p]
+		}
+		copy(entries, sorted)
+	}) {
+		leaf := &rtreeNode{box: geoMath.EmptyBBox(), entries: entries[chunk[0]:chunk[1]]}
+		for _, e := range leaf.entries {
+			leaf.box.Union(e.box)
+		}
+		level = append(level, leaf)
+	}
+
+	// Внутренние узлы
+	for len(level) > 1 {
+		nodes := level
+		var next []*rtreeNode
+		for _, chunk := range strPack(len(nodes), func(i int) geoMath.BBox { return nodes[i].box }, func(perm []int) {
+			sorted := make([]*rtreeNode, len(perm))
+			for i, p := range perm {
+				sorted[i] = nodes[p]
+			}
+			copy(nodes, sorted)
+		}) {
+			parent := &rtreeNode{box: geoMath.EmptyBBox(), children: nodes[chunk[0]:chunk[1]]}
+			for _, child := range parent.children {
+				parent.box.Union(child.box)
+			}
+			next = append(next, parent)
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+// strPack упорядочивает элементы по плиткам и возвращает границы групп [from, to)
+func strPack(n int, box func(i int) geoMath.BBox, reorder func(perm []int)) [][2]int {
+	centerX := func(b geoMath.BBox) float64 { return (b.MinX + b.MaxX) / 2 }
+	centerY := func(b geoMath.BBox) float64 { return (b.MinY + b.MaxY) / 2 }
+
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+
+	// Вертикальные полосы по X, внутри полосы - сортировка по Y
+	leafCount := int(math.Ceil(float64(n) / rtreeNodeSize))
+	sliceCount := int(math.Ceil(math.Sqrt(float64(leafCount))))
+	sliceSize := sliceCount * rtreeNodeSize
+
+	sort.Slice(perm, func(a, b int) bool { return centerX(box(perm[a])) < centerX(box(perm[b])) })
+	for start := 0; start < n; start += sliceSize {
+		end := min(start+sliceSize, n)
+		slice := perm[start:end]
+		sort.Slice(slice, func(a, b int) bool { return centerY(box(slice[a])) < centerY(box(slice[b])) })
+	}
+	reorder(perm)
+
+	var chunks [][2]int
+	for start := 0; start < n; start += rtreeNodeSize {
+		chunks = append(chunks, [2]int{start, min(start+rtreeNodeSize, n)})
+	}
+	return chunks
+}
+
+// search обходит элементы, прямоугольник которых содержит точку
+func (n *rtreeNode) search(x, y float64, visit func(e rtreeEntry)) {
+	if n == nil || !n.box.Contains(x, y) {
+		return
+	}
+	for _, e := range n.entries {
+		if e.box.Contains(x, y) {
+			visit(e)
+		}
+	}
+	for _, child := range n.children {
+		child.search(x, y, visit)
+	}
+}
+
+// searchBox обходит элементы, прямоугольник которых пересекается с заданным
+func (n *rtreeNode) searchBox(box geoMath.BBox, visit func(e rtreeEntry)) {
+	if n == nil || !n.box.Intersects(box) {
+		return
+	}
+	for _, e := range n.entries {
+		if e.box.Intersects(box) {
+			visit(e)
+		}
+	}
+	for _, child := range n.children {
+		child.searchBox(box, visit)
+	}
+}
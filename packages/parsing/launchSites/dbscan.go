@@ -0,0 +1,125 @@
+package launchSites
+
+import (
+	"math"
+
+	"project/packages/parsing/geoMath"
+)
+
+// Noise метка точки, не попавшей ни в один кластер
+const Noise = -1
+
+// weightedPoint уникальная точка с числом полетов из нее
+type weightedPoint struct {
+	lat, lon float64
+	weight   int
+}
+
+// cellKey ячейка сетки для поиска соседей
+type cellKey struct {
+	row, col int
+}
+
+// neighborGrid сетка с ячейками не меньше epsilon по обеим осям.
+// Ширина ячейки по долготе рассчитывается для каждой полосы широт отдельно
+type neighborGrid struct {
+	points  []weightedPoint
+	epsilon float64
+	dLat    float64
+	cells   map[cellKey][]int
+}
+
+func newNeighborGrid(points []weightedPoint, epsilon float64) *neighborGrid {
+	g := &neighborGrid{
+		points:  points,
+		epsilon: epsilon,
+		dLat:    epsilon / 111320,
+		cells:   make(map[cellKey][]int),
+	}
+	for i, p := range points {
+		key := g.key(p.lat, p.lon)
+		g.cells[key] = append(g.cells[key], i)
+	}
+	return g
+}
+
+// rowWidth ширина ячейки по долготе в полосе row: по самой северной широте полосы
+func (g *neighborGrid) rowWidth(row int) float64 {
+	lat := math.Max(math.Abs(float64(row)*g.dLat), math.Abs(float64(row+1)*g.dLat))
+	_, dLon := geoMath.DegreesForMeters(lat, g.epsilon)
+	return dLon
+}
+
+func (g *neighborGrid) key(lat, lon float64) cellKey {
+	row := int(math.Floor(lat / g.dLat))
+	return cellKey{row: row, col: int(math.Floor(lon / g.rowWidth(row)))}
+}
+
+// neighbors индексы точек не дальше epsilon (включая саму точку) и их суммарный вес
+func (g *neighborGrid) neighbors(i int) ([]int, int) {
+	p := g.points[i]
+	row := int(math.Floor(p.lat / g.dLat))
+
+	var result []int
+	weight := 0
+	for r := row - 1; r <= row+1; r++ {
+		col := int(math.Floor(p.lon / g.rowWidth(r)))
+		for c := col - 1; c <= col+1; c++ {
+			for _, j := range g.cells[cellKey{row: r, col: c}] {
+				q := g.points[j]
+				if geoMath.HaversineMeters(p.lat, p.lon, q.lat, q.lon) <= g.epsilon {
+					result = append(result, j)
+					weight += q.weight
+				}
+			}
+		}
+	}
+	return result, weight
+}
+
+// dbscan кластеризует точки: ядро - точка, у которой в радиусе epsilon
+// не меньше minPoints полетов (с учетом повторов координат).
+// Возвращает номер кластера для каждой точки (с 1) или Noise и число кластеров
+func dbscan(points []weightedPoint, epsilon float64, minPoints int) ([]int, int) {
+	labels := make([]int, len(points))
+	visited := make([]bool, len(points))
+	grid := newNeighborGrid(points, epsilon)
+
+	clusters := 0
+	for i := range points {
+		if visited[i] {
+			continue
+		}
+		visited[i] = true
+
+		neighbors, weight := grid.neighbors(i)
+		if weight < minPoints {
+			labels[i] = Noise
+			continue
+		}
+
+		clusters++
+		labels[i] = clusters
+
+		queue := neighbors
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+
+			if labels[j] == Noise {
+				labels[j] = clusters // Пограничная точка
+			}
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			labels[j] = clusters
+
+			if next, nextWeight := grid.neighbors(j); nextWeight >= minPoints {
+				queue = append(queue, next...)
+			}
+		}
+	}
+
+	return labels, clusters
+}
@@ -0,0 +1,345 @@
+package launchSites
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"project/packages/parsing/geoIndex"
+	"project/packages/parsing/geoMath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName коллекция реестра мест запуска
+const CollectionName = "launchSites"
+
+// topOperatorsLimit сколько операторов сохранять для места запуска
+const topOperatorsLimit = 5
+
+// updateChunkSize размер пачки идентификаторов полетов в одном обновлении
+const updateChunkSize = 5000
+
+// Config параметры кластеризации
+type Config struct {
+	EpsilonMeters float64 // Радиус соседства
+	MinPoints     int     // Минимум полетов в радиусе для ядра кластера
+}
+
+// DefaultConfig параметры по умолчанию, переопределяются LAUNCH_SITE_EPSILON_M и LAUNCH_SITE_MIN_POINTS
+func DefaultConfig() Config {
+	cfg := Config{EpsilonMeters: 300, MinPoints: 5}
+	if value, err := strconv.ParseFloat(os.Getenv("LAUNCH_SITE_EPSILON_M"), 64); err == nil && value > 0 {
+		cfg.EpsilonMeters = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("LAUNCH_SITE_MIN_POINTS")); err == nil && value > 0 {
+		cfg.MinPoints = value
+	}
+	return cfg
+}
+
+// OperatorCount количество полетов оператора
+type OperatorCount struct {
+	Operator string `bson:"operator" json:"operator"`
+	Count    int    `bson:"count" json:"count"`
+}
+
+// Site место запуска
+type Site struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Lat           float64            `bson:"lat" json:"lat"`
+	Lon           float64            `bson:"lon" json:"lon"`
+	Location      bson.M             `bson:"location" json:"-"`
+	RadiusMeters  float64            `bson:"radiusMeters" json:"radiusMeters"`
+	FlightCount   int                `bson:"flightCount" json:"flightCount"`
+	DateFrom      *time.Time         `bson:"dateFrom,omitempty" json:"dateFrom,omitempty"`
+	DateTo        *time.Time         `bson:"dateTo,omitempty" json:"dateTo,omitempty"`
+	TopOperators  []OperatorCount    `bson:"topOperators" json:"topOperators"`
+	Region        string             `bson:"region,omitempty" json:"region,omitempty"`
+	EpsilonMeters float64            `bson:"epsilonMeters" json:"epsilonMeters"`
+	MinPoints     int                `bson:"minPoints" json:"minPoints"`
+	BuiltAt       time.Time          `bson:"builtAt" json:"builtAt"`
+}
+
+// Summary итог построения реестра
+type Summary struct {
+	Flights        int     `json:"flights"`
+	UniquePoints   int     `json:"uniquePoints"`
+	Sites          int     `json:"sites"`
+	ClusteredCount int     `json:"clusteredFlights"`
+	NoiseCount     int     `json:"noiseFlights"`
+	DurationSec    float64 `json:"durationSec"`
+}
+
+// flightPoint точка вылета полета
+type flightPoint struct {
+	id       primitive.ObjectID
+	lat, lon float64
+	operator string
+	region   string
+	dateTime *time.Time
+}
+
+// Build кластеризует точки вылета из flightData, пересоздает коллекцию launchSites
+// и проставляет полетам поле launchSiteId
+func Build(flights, sites *mongo.Collection, cfg Config) (Summary, error) {
+	startTime := time.Now()
+	ctx := context.Background()
+
+	fmt.Printf("📍 Кластеризация мест запуска: epsilon %.0f м, minPoints %d\n", cfg.EpsilonMeters, cfg.MinPoints)
+
+	points, err := loadFlightPoints(ctx, flights)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	// Повторяющиеся координаты схлопываем в одну точку с весом
+	index := make(map[[2]float64]int)
+	var unique []weightedPoint
+	pointOf := make([]int, len(points))
+	for i, p := range points {
+		key := [2]float64{p.lat, p.lon}
+		u, ok := index[key]
+		if !ok {
+			u = len(unique)
+			index[key] = u
+			unique = append(unique, weightedPoint{lat: p.lat, lon: p.lon})
+		}
+		unique[u].weight++
+		pointOf[i] = u
+	}
+
+	labels, clusters := dbscan(unique, cfg.EpsilonMeters, cfg.MinPoints)
+
+	members := make([][]flightPoint, clusters+1)
+	summary := Summary{Flights: len(points), UniquePoints: len(unique), Sites: clusters}
+	for i, p := range points {
+		label := labels[pointOf[i]]
+		if label == Noise {
+			summary.NoiseCount++
+			continue
+		}
+		members[label] = append(members[label], p)
+		summary.ClusteredCount++
+	}
+
+	builtAt := time.Now()
+	var docs []interface{}
+	siteIDs := make([]primitive.ObjectID, clusters+1)
+	for label := 1; label <= clusters; label++ {
+		site := describeSite(members[label], cfg, builtAt)
+		siteIDs[label] = site.ID
+		docs = append(docs, site)
+	}
+
+	// Реестр собирается в промежуточной коллекции и подменяет рабочий целиком:
+	// при ошибке остается прежний реестр
+	if err := geoIndex.ReplaceCollection(ctx, sites, docs, createSiteIndexes); err != nil {
+		return summary, fmt.Errorf("ошибка пересоздания %s: %v", CollectionName, err)
+	}
+	if err := createFlightIndexes(ctx, flights); err != nil {
+		fmt.Printf("⚠️ Ошибка создания индекса launchSiteId: %v\n", err)
+	}
+
+	// Привязываем полеты к новым местам запуска, затем снимаем ссылки на прежние
+	for label := 1; label <= clusters; label++ {
+		ids := make([]primitive.ObjectID, 0, len(members[label]))
+		for _, p := range members[label] {
+			ids = append(ids, p.id)
+		}
+		for start := 0; start < len(ids); start += updateChunkSize {
+			end := min(start+updateChunkSize, len(ids))
+			if _, err := flights.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids[start:end]}},
+				bson.M{"$set": bson.M{"launchSiteId": siteIDs[label]}}); err != nil {
+				return summary, fmt.Errorf("ошибка привязки полетов к месту запуска: %v", err)
+			}
+		}
+	}
+	if _, err := flights.UpdateMany(ctx, bson.M{"launchSiteId": bson.M{"$exists": true, "$nin": siteIDs[1:]}},
+		bson.M{"$unset": bson.M{"launchSiteId": ""}}); err != nil {
+		return summary, fmt.Errorf("ошибка сброса launchSiteId: %v", err)
+	}
+
+	summary.DurationSec = math.Round(time.Since(startTime).Seconds()*10) / 10
+	fmt.Printf("✅ Мест запуска: %d, полетов в кластерах: %d, вне кластеров: %d (%.1f сек)\n",
+		summary.Sites, summary.ClusteredCount, summary.NoiseCount, summary.DurationSec)
+
+	return summary, nil
+}
+
+// loadFlightPoints читает точки вылета всех полетов: dep, иначе SHR
+func loadFlightPoints(ctx context.Context, flights *mongo.Collection) ([]flightPoint, error) {
+	filter := bson.M{"$or": []bson.M{
+		{"dep.coordinates": bson.M{"$exists": true}},
+		{"shr.coordinatesDep": bson.M{"$exists": true}},
+	}}
+	opts := options.Find().SetProjection(bson.M{
+		"dep.coordinates":       1,
+		"shr.coordinatesDep":    1,
+		"shr.operator":          1,
+		"region":                1,
+		"searchFields.dateTime": 1,
+	})
+
+	cursor, err := flights.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения полетов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	type coordinate struct {
+		Lat float64 `bson:"lat"`
+		Lon float64 `bson:"lon"`
+	}
+
+	var points []flightPoint
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			Region string             `bson:"region"`
+			Dep    struct {
+				Coordinates *coordinate `bson:"coordinates"`
+			} `bson:"dep"`
+			SHR struct {
+				CoordinatesDep *coordinate `bson:"coordinatesDep"`
+				Operator       string      `bson:"operator"`
+			} `bson:"shr"`
+			SearchFields struct {
+				DateTime *time.Time `bson:"dateTime"`
+			} `bson:"searchFields"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		// Точка 0,0 - заглушка вместо координат
+		valid := func(c *coordinate) bool { return c != nil && (c.Lat != 0 || c.Lon != 0) }
+		point := doc.Dep.Coordinates
+		if !valid(point) {
+			point = doc.SHR.CoordinatesDep
+		}
+		if !valid(point) {
+			continue
+		}
+
+		points = append(points, flightPoint{
+			id:       doc.ID,
+			lat:      point.Lat,
+			lon:      point.Lon,
+			operator: doc.SHR.Operator,
+			region:   doc.Region,
+			dateTime: doc.SearchFields.DateTime,
+		})
+	}
+
+	return points, cursor.Err()
+}
+
+// describeSite считает центр, радиус, период активности, операторов и регион кластера.
+// Долготы усредняются относительно первой точки, чтобы кластер у ±180° не сместился на другую сторону Земли
+func describeSite(points []flightPoint, cfg Config, builtAt time.Time) Site {
+	var sumLat, sumLon float64
+	for _, p := range points {
+		sumLat += p.lat
+		sumLon += unwrapLon(p.lon, points[0].lon)
+	}
+	lat := sumLat / float64(len(points))
+	lon := unwrapLon(sumLon/float64(len(points)), 0)
+
+	site := Site{
+		ID:            primitive.NewObjectID(),
+		Lat:           math.Round(lat*1e6) / 1e6,
+		Lon:           math.Round(lon*1e6) / 1e6,
+		Location:      bson.M{"type": "Point", "coordinates": []float64{lon, lat}},
+		FlightCount:   len(points),
+		EpsilonMeters: cfg.EpsilonMeters,
+		MinPoints:     cfg.MinPoints,
+		BuiltAt:       builtAt,
+	}
+
+	operators := make(map[string]int)
+	regions := make(map[string]int)
+	var radius float64
+	for _, p := range points {
+		radius = math.Max(radius, geoMath.HaversineMeters(lat, lon, p.lat, p.lon))
+
+		if p.dateTime != nil {
+			if site.DateFrom == nil || p.dateTime.Before(*site.DateFrom) {
+				site.DateFrom = p.dateTime
+			}
+			if site.DateTo == nil || p.dateTime.After(*site.DateTo) {
+				site.DateTo = p.dateTime
+			}
+		}
+		if p.operator != "" {
+			operators[p.operator]++
+		}
+		if p.region != "" {
+			regions[p.region]++
+		}
+	}
+	site.RadiusMeters = math.Round(radius)
+
+	for operator, count := range operators {
+		site.TopOperators = append(site.TopOperators, OperatorCount{Operator: operator, Count: count})
+	}
+	sort.Slice(site.TopOperators, func(i, j int) bool {
+		if site.TopOperators[i].Count != site.TopOperators[j].Count {
+			return site.TopOperators[i].Count > site.TopOperators[j].Count
+		}
+		return site.TopOperators[i].Operator < site.TopOperators[j].Operator
+	})
+	if len(site.TopOperators) > topOperatorsLimit {
+		site.TopOperators = site.TopOperators[:topOperatorsLimit]
+	}
+	if site.TopOperators == nil {
+		site.TopOperators = []OperatorCount{}
+	}
+
+	// Регион места запуска - самый частый среди его полетов
+	best := 0
+	for region, count := range regions {
+		if count > best || (count == best && region < site.Region) {
+			site.Region, best = region, count
+		}
+	}
+
+	return site
+}
+
+// unwrapLon долгота lon, сдвинутая на ±360° в пределы 180° от ref
+func unwrapLon(lon, ref float64) float64 {
+	for lon-ref > 180 {
+		lon -= 360
+	}
+	for lon-ref < -180 {
+		lon += 360
+	}
+	return lon
+}
+
+// createSiteIndexes индексы реестра
+func createSiteIndexes(ctx context.Context, sites *mongo.Collection) error {
+	_, err := sites.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "flightCount", Value: -1}}},
+		{Keys: bson.D{{Key: "region", Value: 1}}},
+	})
+	return err
+}
+
+// createFlightIndexes индекс поля launchSiteId в полетах
+func createFlightIndexes(ctx context.Context, flights *mongo.Collection) error {
+	_, err := flights.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "launchSiteId", Value: 1}},
+		Options: options.Index().SetName("launchSiteId_1").SetSparse(true),
+	})
+	return err
+}
@@ -1,14 +1,21 @@
 package parsing
 
 import (
+	"math"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"project/packages/parsing/aerodromes"
+	"project/packages/parsing/airspace"
 	coorinates "project/packages/parsing/coordinates"
 	"project/packages/parsing/datetime"
+	"project/packages/parsing/gazetteer"
+	"project/packages/parsing/geoMath"
+	"project/packages/parsing/plausibility"
 )
 
 // Предкомпилированные регулярки для часто используемых паттернов
@@ -16,28 +23,51 @@ var (
 	shrAircraftRegex = regexp.MustCompile(`SHR-([A-Z0-9]+)`)
 	typCountRegex    = regexp.MustCompile(`TYP/([0-9]+)`)
 	typTypeRegex     = regexp.MustCompile(`TYP/\d*([A-Z]+)`)
-	depCoordRegex    = regexp.MustCompile(`DEP/([0-9]+[NS][0-9]+[EW])`)
-	destCoordRegex   = regexp.MustCompile(`DEST/([0-9]+[NS][0-9]+[EW])`)
+	depCoordRegex    = regexp.MustCompile(`DEP/(` + coorinates.Pattern + `)`)
+	destCoordRegex   = regexp.MustCompile(`DEST/(` + coorinates.Pattern + `)`)
 	dofRegex         = regexp.MustCompile(`DOF/([0-9]+)`)
 	sidRegex         = regexp.MustCompile(`SID/([0-9]+)`)
 	fieldLineRegex   = regexp.MustCompile(`^-\w{4}(\d{4})`)
 	addRegex         = regexp.MustCompile(`-ADD ([0-9]+)`)
 	atdRegex         = regexp.MustCompile(`-ATD ([0-9]+)`)
-	//adepRegex        = regexp.MustCompile(`-ADEP ([A-Z0-9]+)`)
-	adepzRegex = regexp.MustCompile(`-ADEPZ ([0-9]+[NS][0-9]+[EW])`)
-	adaRegex   = regexp.MustCompile(`-ADA ([0-9]+)`)
-	ataRegex   = regexp.MustCompile(`-ATA ([0-9]+)`)
-	//adarrRegex       = regexp.MustCompile(`-ADARR ([A-Z0-9]+)`)
-	adarrzRegex = regexp.MustCompile(`-ADARRZ ([0-9]+[NS][0-9]+[EW])`)
+	adepzRegex       = regexp.MustCompile(`-ADEPZ (` + coorinates.Pattern + `)`)
+	adaRegex         = regexp.MustCompile(`-ADA ([0-9]+)`)
+	ataRegex         = regexp.MustCompile(`-ATA ([0-9]+)`)
+	adarrzRegex      = regexp.MustCompile(`-ADARRZ (` + coorinates.Pattern + `)`)
 )
 
 type FlightData struct {
-	ID           string        `bson:"_id,omitempty" json:"id"`
-	SHRData      SHRData       `bson:"shr" json:"shr"`
-	Departure    DepartureData `bson:"dep" json:"dep"`
-	Arrival      ArrivalData   `bson:"arr" json:"arr"`
-	SearchFields SearchField   `bson:"searchFields" json:"searchFields"`
-	Region       string        `bson:"region,omitempty" json:"region"`
+	ID                string             `bson:"_id,omitempty" json:"id"`
+	SHRData           SHRData            `bson:"shr" json:"shr"`
+	Departure         DepartureData      `bson:"dep" json:"dep"`
+	Arrival           ArrivalData        `bson:"arr" json:"arr"`
+	SearchFields      SearchField        `bson:"searchFields" json:"searchFields"`
+	Region            string             `bson:"region,omitempty" json:"region"`
+	District          string             `bson:"district,omitempty" json:"district"`
+	ArrRegion         string             `bson:"arrRegion,omitempty" json:"arrRegion"`
+	CrossRegion       bool               `bson:"crossRegion" json:"crossRegion"`
+	RegionMatch       *RegionMatch       `bson:"regionMatch,omitempty" json:"regionMatch,omitempty"`
+	QualityFlags      []string           `bson:"qualityFlags" json:"qualityFlags"` // Флаги качества ([] - проверен, без замечаний)
+	DepPoint          *GeoPoint          `bson:"depPoint,omitempty" json:"depPoint,omitempty"`
+	DistanceKm        *float64           `bson:"distanceKm,omitempty" json:"distanceKm"`                         // Расстояние вылет-посадка по большому кругу
+	AvgSpeedKmh       *float64           `bson:"avgSpeedKmh,omitempty" json:"avgSpeedKmh"`                       // Средняя путевая скорость
+	Airspace          []airspace.Hit     `bson:"airspace" json:"airspace"`                                       // Пересечения с зонами ограничения (nil - не проверялся)
+	NearestSettlement *gazetteer.Nearest `bson:"nearestSettlement,omitempty" json:"nearestSettlement,omitempty"` // Ближайший к точке вылета населенный пункт
+}
+
+// GeoPoint точка в формате GeoJSON для 2dsphere индекса
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"` // [lon, lat]
+}
+
+// NewGeoPoint создает GeoJSON точку. Для координат вне допустимого диапазона возвращает nil:
+// такую точку 2dsphere индекс не примет и вставка документа завершится ошибкой
+func NewGeoPoint(lat, lon float64) *GeoPoint {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+	return &GeoPoint{Type: "Point", Coordinates: []float64{lon, lat}}
 }
 
 // Остальные структуры остаются без изменений...
@@ -56,19 +86,24 @@ type SHRData struct {
 	Date             string                 `bson:"date" json:"date"`
 	Operator         string                 `bson:"operator" json:"operator"`
 	OperatorType     string                 `bson:"operatorType" json:"operatorType"`
+	Zone             *airspace.FlightZone   `bson:"zone,omitempty" json:"zone,omitempty"` // Район полетов из /ZONA
 	//Remarks          string                 `bson:"remarks" json:"remarks"`
 }
 
 type DepartureData struct {
-	RawText     string                 `bson:"rawText" json:"rawText"`
-	DateTime    *time.Time             `bson:"dateTime" json:"dateTime"`
-	Coordinates *coorinates.Coordinate `bson:"coordinates,omitempty" json:"coordinates"`
+	RawText           string                 `bson:"rawText" json:"rawText"`
+	DateTime          *time.Time             `bson:"dateTime" json:"dateTime"`
+	Airport           string                 `bson:"airport,omitempty" json:"airport,omitempty"` // Код аэродрома -ADEP
+	Coordinates       *coorinates.Coordinate `bson:"coordinates,omitempty" json:"coordinates"`
+	CoordinatesSource string                 `bson:"coordinatesSource,omitempty" json:"coordinatesSource,omitempty"` // aerodrome - из справочника
 }
 
 type ArrivalData struct {
-	RawText     string                 `bson:"rawText" json:"rawText"`
-	DateTime    *time.Time             `bson:"dateTime" json:"dateTime"`
-	Coordinates *coorinates.Coordinate `bson:"coordinates,omitempty" json:"coordinates"`
+	RawText           string                 `bson:"rawText" json:"rawText"`
+	DateTime          *time.Time             `bson:"dateTime" json:"dateTime"`
+	Airport           string                 `bson:"airport,omitempty" json:"airport,omitempty"` // Код аэродрома -ADARR
+	Coordinates       *coorinates.Coordinate `bson:"coordinates,omitempty" json:"coordinates"`
+	CoordinatesSource string                 `bson:"coordinatesSource,omitempty" json:"coordinatesSource,omitempty"` // aerodrome - из справочника
 }
 
 type SearchField struct {
@@ -94,9 +129,117 @@ func Coalesce[T any](values ...*T) *T {
 	return nil
 }
 
+// undefinedRegion значение региона для точек вне всех субъектов
+const undefinedRegion = "Регион не определен"
+
+// Способ привязки точки к региону
+const (
+	MatchInside  = "inside"  // Точка внутри полигона региона
+	MatchNearest = "nearest" // Точка вне регионов, взят ближайший в пределах RegionSnapDistanceMeters
+	MatchNone    = "none"    // Регион не определен
+)
+
+// Источник координат, по которым определен регион вылета
+const (
+	RegionSourceDeparture = "dep"       // Координаты из сообщения о вылете (-ADEPZ)
+	RegionSourceSHR       = "shr"       // Координаты вылета из плана SHR
+	RegionSourceAerodrome = "aerodrome" // Координаты аэродрома из справочника
+)
+
+// Уверенность в регионе вылета
+const (
+	ConfidenceHigh   = "high"   // Точка внутри региона, второй источник координат дает тот же регион
+	ConfidenceMedium = "medium" // Точка внутри региона по единственному источнику или источники совпали после привязки к ближайшему
+	ConfidenceLow    = "low"    // Источники расходятся, регион только ближайший или не определен
+)
+
+// RegionMatch способ, которым был определен регион вылета
+type RegionMatch struct {
+	Method         string  `bson:"method" json:"method"`
+	DistanceMeters float64 `bson:"distanceMeters,omitempty" json:"distanceMeters,omitempty"`
+	Source         string  `bson:"source,omitempty" json:"source,omitempty"`
+	AltSource      string  `bson:"altSource,omitempty" json:"altSource,omitempty"`
+	AltRegion      string  `bson:"altRegion,omitempty" json:"altRegion,omitempty"` // Регион по второму источнику координат
+	Conflict       bool    `bson:"conflict,omitempty" json:"conflict,omitempty"`   // Источники координат дают разные регионы
+	Confidence     string  `bson:"confidence,omitempty" json:"confidence,omitempty"`
+}
+
+// SetSources записывает источник координат и результат сверки со вторым источником
+// (alternative == nil, если второго источника нет) и вычисляет уверенность
+func (m *RegionMatch) SetSources(region string, source RegionPoint, alternative *RegionPoint, altRegion string) {
+	m.Source = source.Source
+	if alternative != nil {
+		m.AltSource = alternative.Source
+		m.AltRegion = altRegion
+		m.Conflict = altRegion != region
+	}
+
+	switch {
+	case m.Method == MatchNone || m.Conflict:
+		m.Confidence = ConfidenceLow
+	case m.Method == MatchInside && alternative != nil:
+		m.Confidence = ConfidenceHigh
+	case m.Method == MatchInside || alternative != nil:
+		m.Confidence = ConfidenceMedium
+	default:
+		m.Confidence = ConfidenceLow
+	}
+}
+
+// RegionPoint точка вылета для определения региона с указанием источника координат
+type RegionPoint struct {
+	Source string
+	Lat    float64
+	Lon    float64
+}
+
+// DeparturePoints точки вылета в порядке приоритета: координаты из сообщения о вылете
+// (или аэродрома, если depSource = aerodrome), затем из SHR. Нулевые координаты пропускаются
+func DeparturePoints(dep *coorinates.Coordinate, depSource string, shr *coorinates.Coordinate) []RegionPoint {
+	var points []RegionPoint
+	if dep != nil && (dep.Lat != 0 || dep.Lon != 0) {
+		source := RegionSourceDeparture
+		if depSource == aerodromes.CoordinatesSourceAerodrome {
+			source = RegionSourceAerodrome
+		}
+		points = append(points, RegionPoint{Source: source, Lat: dep.Lat, Lon: dep.Lon})
+	}
+	if shr != nil && (shr.Lat != 0 || shr.Lon != 0) {
+		points = append(points, RegionPoint{Source: RegionSourceSHR, Lat: shr.Lat, Lon: shr.Lon})
+	}
+	return points
+}
+
+// RegionSnapDistanceMeters максимальное расстояние до границы ближайшего региона
+// для точек, не попавших ни в один полигон (REGION_SNAP_DISTANCE_M, 0 - отключено)
+var RegionSnapDistanceMeters = snapDistanceFromEnv()
+
+func snapDistanceFromEnv() float64 {
+	if value := os.Getenv("REGION_SNAP_DISTANCE_M"); value != "" {
+		if meters, err := strconv.ParseFloat(value, 64); err == nil && meters >= 0 {
+			return meters
+		}
+	}
+	return 3000
+}
+
 // Добавляем метод для установки региона
 func (fd *FlightData) SetRegion(region string) {
 	fd.Region = region
+	fd.updateCrossRegion()
+}
+
+// SetArrRegion устанавливает регион посадки
+func (fd *FlightData) SetArrRegion(region string) {
+	fd.ArrRegion = region
+	fd.updateCrossRegion()
+}
+
+// updateCrossRegion межрегиональный полет: оба региона определены и различаются
+func (fd *FlightData) updateCrossRegion() {
+	fd.CrossRegion = fd.Region != "" && fd.ArrRegion != "" &&
+		fd.Region != undefinedRegion && fd.ArrRegion != undefinedRegion &&
+		fd.Region != fd.ArrRegion
 }
 
 // Создание структуры FlightData из строки Excel
@@ -111,6 +254,16 @@ func CreateFlightData(row []string) FlightData {
 	depData := parseDepartureData(depRaw)
 	arrData := parseArrivalData(arrRaw)
 
+	// Координаты аэродромов из справочника, если явных координат нет
+	if coordinates, ok := aerodromeCoordinates(depData.Airport, depData.Coordinates, shrData.CoordinatesDep); ok {
+		depData.Coordinates = coordinates
+		depData.CoordinatesSource = aerodromes.CoordinatesSourceAerodrome
+	}
+	if coordinates, ok := aerodromeCoordinates(arrData.Airport, arrData.Coordinates, shrData.CoordinatesArr); ok {
+		arrData.Coordinates = coordinates
+		arrData.CoordinatesSource = aerodromes.CoordinatesSourceAerodrome
+	}
+
 	// Оптимизированное создание SearchField
 	searchField := createSearchField(&shrData, &depData, &arrData)
 
@@ -122,12 +275,59 @@ func CreateFlightData(row []string) FlightData {
 		}
 	}
 
-	return FlightData{
+	flightData := FlightData{
 		SHRData:      shrData,
 		Departure:    depData,
 		Arrival:      arrData,
 		SearchFields: searchField,
 	}
+
+	// Проверка правдоподобности координат и длительности
+	plausibilityInput := plausibility.Input{
+		SHRDep:         shrData.CoordinatesDep,
+		SHRArr:         shrData.CoordinatesArr,
+		Dep:            depData.Coordinates,
+		Arr:            arrData.Coordinates,
+		FlightDuration: shrData.FlightDuration,
+		AircraftType:   shrData.AircraftType,
+	}
+	flightData.QualityFlags = plausibility.Check(plausibilityInput)
+
+	// Расстояние и средняя скорость для фильтров, сортировки и выгрузки
+	flightData.DistanceKm, flightData.AvgSpeedKmh = plausibility.Motion(plausibilityInput)
+
+	// Точка вылета для пространственных фильтров: та же, что для определения региона
+	if lat, lon := regionPoint(&flightData); lat != 0 || lon != 0 {
+		flightData.DepPoint = NewGeoPoint(lat, lon)
+	}
+
+	// Ближайший населенный пункт
+	if index := gazetteer.Shared(); index != nil && flightData.DepPoint != nil {
+		flightData.NearestSettlement = index.Nearest(flightData.DepPoint.Coordinates[1], flightData.DepPoint.Coordinates[0])
+	}
+
+	// Пересечения точки вылета и района полетов с зонами ограничения
+	if index := airspace.Shared(); index != nil {
+		var lat, lon float64
+		if flightData.DepPoint != nil {
+			lon, lat = flightData.DepPoint.Coordinates[0], flightData.DepPoint.Coordinates[1]
+		}
+		flightData.Airspace = index.Check(lat, lon, flightData.DepPoint != nil, shrData.Zone)
+	}
+
+	return flightData
+}
+
+// aerodromeCoordinates координаты аэродрома code из справочника, если ни одна из явных координат не задана
+func aerodromeCoordinates(code string, explicit ...*coorinates.Coordinate) (*coorinates.Coordinate, bool) {
+	if code == "" || Coalesce(explicit...) != nil {
+		return nil, false
+	}
+	aerodrome, ok := aerodromes.Lookup(code)
+	if !ok {
+		return nil, false
+	}
+	return aerodrome.Coordinate(), true
 }
 
 // Оптимизированная extractData с предкомпилированными regexp
@@ -222,6 +422,9 @@ func parseSHRData(rawText string) SHRData {
 	shr.Operator = operatorResult.Operator
 	shr.OperatorType = operatorResult.OperatorType
 
+	// Район полетов
+	shr.Zone = airspace.ParseZone(rawText)
+
 	return shr
 }
 
@@ -258,7 +461,7 @@ func parseDepartureData(rawText string) DepartureData {
 
 	dep.DateTime = datetime.ParseDate(extractData(addRegex, rawText), extractData(atdRegex, rawText))
 
-	//dep.Airport = extractData(adepRegex, rawText)
+	dep.Airport = aerodromes.DepartureCode(rawText)
 	dep.Coordinates, _ = coorinates.ParseAviationCoordinate(extractData(adepzRegex, rawText))
 
 	return dep
@@ -269,7 +472,7 @@ func parseArrivalData(rawText string) ArrivalData {
 
 	arr.DateTime = datetime.ParseDate(extractData(adaRegex, rawText), extractData(ataRegex, rawText))
 
-	//arr.Airport = extractData(adarrRegex, rawText)
+	arr.Airport = aerodromes.ArrivalCode(rawText)
 	arr.Coordinates, _ = coorinates.ParseAviationCoordinate(extractData(adarrzRegex, rawText))
 
 	return arr
@@ -285,6 +488,41 @@ type GeoServiceInterface interface {
 	FindRegionForPoint(lat, lon float64) (string, error)
 }
 
+// DistrictServiceInterface геосервис с поиском муниципальных районов
+type DistrictServiceInterface interface {
+	FindDistrictForPoint(lat, lon float64) (string, error)
+	FindDistrictsForPointsBatch(points []geoMath.Point) (map[string]string, error)
+}
+
+// NearestRegionInterface геосервис с поиском ближайшего региона.
+// Возвращает пустое название, если в пределах maxMeters регионов нет
+type NearestRegionInterface interface {
+	FindNearestRegion(lat, lon, maxMeters float64) (string, float64, error)
+}
+
+// MatchRegion дополняет результат поиска региона: если точка не попала ни в один полигон,
+// ищет ближайший регион в пределах RegionSnapDistanceMeters
+func MatchRegion(service GeoServiceInterface, region string, lat, lon float64) (string, RegionMatch) {
+	if region != "" && region != undefinedRegion {
+		return region, RegionMatch{Method: MatchInside}
+	}
+
+	if nearestService, ok := service.(NearestRegionInterface); ok && RegionSnapDistanceMeters > 0 {
+		name, distance, err := nearestService.FindNearestRegion(lat, lon, RegionSnapDistanceMeters)
+		if err == nil && name != "" {
+			return name, RegionMatch{Method: MatchNearest, DistanceMeters: math.Round(distance)}
+		}
+	}
+
+	return undefinedRegion, RegionMatch{Method: MatchNone}
+}
+
+// BatchGeoServiceInterface геосервис с пакетным поиском регионов
+type BatchGeoServiceInterface interface {
+	GeoServiceInterface
+	FindRegionsForPointsBatch(points []geoMath.Point) (map[string]string, error)
+}
+
 // NewGeoIntegratedParser создает новый парсер с интеграцией геопоиска
 func NewGeoIntegratedParser(geoService GeoServiceInterface) *GeoIntegratedParser {
 	return &GeoIntegratedParser{
@@ -297,29 +535,145 @@ func (p *GeoIntegratedParser) CreateFlightDataWithRegion(row []string) FlightDat
 	flightData := CreateFlightData(row)
 
 	// Определяем координаты для поиска региона
-	var lat, lon float64
-
-	// Приоритет: координаты вылета из Departure
-	if flightData.Departure.Coordinates != nil {
-		lat = flightData.Departure.Coordinates.Lat
-		lon = flightData.Departure.Coordinates.Lon
-	} else if flightData.SHRData.CoordinatesDep != nil {
-		lat = flightData.SHRData.CoordinatesDep.Lat
-		lon = flightData.SHRData.CoordinatesDep.Lon
-	}
+	points := departurePoints(&flightData)
 
-	// Если есть координаты - ищем регион
-	if lat != 0 || lon != 0 {
+	// Если есть координаты - ищем регион и сверяем его со вторым источником
+	if len(points) > 0 {
+		lat, lon := points[0].Lat, points[0].Lon
 		if region, err := p.geoService.FindRegionForPoint(lat, lon); err == nil {
+			region, match := MatchRegion(p.geoService, region, lat, lon)
+			var alternative *RegionPoint
+			var altRegion string
+			if len(points) > 1 {
+				if found, err := p.geoService.FindRegionForPoint(points[1].Lat, points[1].Lon); err == nil {
+					alternative = &points[1]
+					altRegion, _ = MatchRegion(p.geoService, found, alternative.Lat, alternative.Lon)
+				}
+			}
+			match.SetSources(region, points[0], alternative, altRegion)
 			flightData.SetRegion(region)
+			flightData.RegionMatch = &match
+		}
+		if districtService, ok := p.geoService.(DistrictServiceInterface); ok {
+			if district, err := districtService.FindDistrictForPoint(lat, lon); err == nil {
+				flightData.District = district
+			}
 		}
 	} else {
-		flightData.SetRegion("Регион не определен")
+		flightData.SetRegion(undefinedRegion)
+		flightData.RegionMatch = &RegionMatch{Method: MatchNone, Confidence: ConfidenceLow}
+	}
+
+	// Регион посадки
+	if arrLat, arrLon, ok := arrivalPoint(&flightData); ok {
+		if region, err := p.geoService.FindRegionForPoint(arrLat, arrLon); err == nil {
+			region, _ = MatchRegion(p.geoService, region, arrLat, arrLon)
+			flightData.SetArrRegion(region)
+		}
 	}
 
 	return flightData
 }
 
+// regionPoint возвращает координаты для поиска региона.
+// Приоритет: координаты вылета из Departure, затем из SHR
+func regionPoint(flightData *FlightData) (float64, float64) {
+	if points := departurePoints(flightData); len(points) > 0 {
+		return points[0].Lat, points[0].Lon
+	}
+	return 0, 0
+}
+
+// departurePoints точки вылета полета по источникам координат
+func departurePoints(flightData *FlightData) []RegionPoint {
+	return DeparturePoints(flightData.Departure.Coordinates, flightData.Departure.CoordinatesSource, flightData.SHRData.CoordinatesDep)
+}
+
+// arrivalPoint возвращает координаты посадки: из Arrival, затем DEST/ из SHR
+func arrivalPoint(flightData *FlightData) (float64, float64, bool) {
+	coords := Coalesce(flightData.Arrival.Coordinates, flightData.SHRData.CoordinatesArr)
+	if coords == nil || (coords.Lat == 0 && coords.Lon == 0) {
+		return 0, 0, false
+	}
+	return coords.Lat, coords.Lon, true
+}
+
+// createBatchWithRegion разбирает строки и определяет регионы одним пакетным запросом,
+// если геосервис это поддерживает
+func (p *GeoIntegratedParser) createBatchWithRegion(rows [][]string) []FlightData {
+	batchService, ok := p.geoService.(BatchGeoServiceInterface)
+	if !ok {
+		batchResults := make([]FlightData, 0, len(rows))
+		for _, row := range rows {
+			batchResults = append(batchResults, p.CreateFlightDataWithRegion(row))
+		}
+		return batchResults
+	}
+
+	batchResults := make([]FlightData, len(rows))
+	sources := make([][]RegionPoint, len(rows))
+	var points, altPoints, arrPoints []geoMath.Point
+	for i, row := range rows {
+		batchResults[i] = CreateFlightData(row)
+		sources[i] = departurePoints(&batchResults[i])
+		if len(sources[i]) > 0 {
+			points = append(points, geoMath.Point{ID: strconv.Itoa(i), Lat: sources[i][0].Lat, Lon: sources[i][0].Lon})
+		} else {
+			batchResults[i].SetRegion(undefinedRegion)
+			batchResults[i].RegionMatch = &RegionMatch{Method: MatchNone, Confidence: ConfidenceLow}
+		}
+		if len(sources[i]) > 1 {
+			altPoints = append(altPoints, geoMath.Point{ID: strconv.Itoa(i), Lat: sources[i][1].Lat, Lon: sources[i][1].Lon})
+		}
+		if lat, lon, ok := arrivalPoint(&batchResults[i]); ok {
+			arrPoints = append(arrPoints, geoMath.Point{ID: strconv.Itoa(i), Lat: lat, Lon: lon})
+		}
+	}
+
+	// Регионы по второму источнику координат для сверки
+	altRegions := make(map[string]string, len(altPoints))
+	if found, err := batchService.FindRegionsForPointsBatch(altPoints); err == nil {
+		for _, point := range altPoints {
+			altRegions[point.ID], _ = MatchRegion(p.geoService, found[point.ID], point.Lat, point.Lon)
+		}
+	}
+
+	regions, err := batchService.FindRegionsForPointsBatch(points)
+	if err == nil {
+		for _, point := range points {
+			i, _ := strconv.Atoi(point.ID)
+			region, match := MatchRegion(p.geoService, regions[point.ID], point.Lat, point.Lon)
+			var alternative *RegionPoint
+			altRegion, ok := altRegions[point.ID]
+			if ok {
+				alternative = &sources[i][1]
+			}
+			match.SetSources(region, sources[i][0], alternative, altRegion)
+			batchResults[i].SetRegion(region)
+			batchResults[i].RegionMatch = &match
+		}
+	}
+
+	if districtService, ok := p.geoService.(DistrictServiceInterface); ok {
+		districts, _ := districtService.FindDistrictsForPointsBatch(points)
+		for _, point := range points {
+			i, _ := strconv.Atoi(point.ID)
+			batchResults[i].District = districts[point.ID]
+		}
+	}
+
+	arrRegions, err := batchService.FindRegionsForPointsBatch(arrPoints)
+	if err == nil {
+		for _, point := range arrPoints {
+			i, _ := strconv.Atoi(point.ID)
+			region, _ := MatchRegion(p.geoService, arrRegions[point.ID], point.Lat, point.Lon)
+			batchResults[i].SetArrRegion(region)
+		}
+	}
+
+	return batchResults
+}
+
 // ProcessBatchWithRegion пакетная обработка с определением регионов
 func (p *GeoIntegratedParser) ProcessBatchWithRegion(rows [][]string) []FlightData {
 	var results []FlightData
@@ -343,11 +697,7 @@ func (p *GeoIntegratedParser) ProcessBatchWithRegion(rows [][]string) []FlightDa
 			semaphore <- struct{}{}        // Занимаем слот
 			defer func() { <-semaphore }() // Освобождаем слот
 
-			var batchResults []FlightData
-			for _, row := range batch {
-				flightData := p.CreateFlightDataWithRegion(row)
-				batchResults = append(batchResults, flightData)
-			}
+			batchResults := p.createBatchWithRegion(batch)
 
 			mu.Lock()
 			results = append(results, batchResults...)
@@ -0,0 +1,105 @@
+package plausibility
+
+import (
+	"context"
+	"fmt"
+
+	coorinates "project/packages/parsing/coordinates"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfillBatchSize размер пачки обновлений при досчете
+const backfillBatchSize = 1000
+
+// BackfillMotion досчитывает qualityFlags, distanceKm и avgSpeedKmh у полетов, загруженных
+// до появления проверок. Флаги проставляются всем непроверенным полетам, в том числе с одной
+// точкой; пустой список отмечает полет как проверенный, поэтому повторно он не выбирается.
+// Возвращает число обновленных полетов
+func BackfillMotion(flights *mongo.Collection) (int, error) {
+	ctx := context.Background()
+
+	filter := bson.M{"qualityFlags": bson.M{"$exists": false}}
+	opts := options.Find().SetProjection(bson.M{
+		"dep.coordinates":    1,
+		"arr.coordinates":    1,
+		"shr.coordinatesDep": 1,
+		"shr.coordinatesArr": 1,
+		"shr.flightDuration": 1,
+		"shr.aircraftType":   1,
+	})
+
+	cursor, err := flights.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения полетов: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		result, err := flights.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("ошибка обновления расстояний: %v", err)
+		}
+		updated += int(result.ModifiedCount)
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID  interface{} `bson:"_id"`
+			Dep struct {
+				Coordinates *coorinates.Coordinate `bson:"coordinates"`
+			} `bson:"dep"`
+			Arr struct {
+				Coordinates *coorinates.Coordinate `bson:"coordinates"`
+			} `bson:"arr"`
+			SHR struct {
+				CoordinatesDep *coorinates.Coordinate `bson:"coordinatesDep"`
+				CoordinatesArr *coorinates.Coordinate `bson:"coordinatesArr"`
+				FlightDuration *float64               `bson:"flightDuration"`
+				AircraftType   *string                `bson:"aircraftType"`
+			} `bson:"shr"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		in := Input{
+			SHRDep:         doc.SHR.CoordinatesDep,
+			SHRArr:         doc.SHR.CoordinatesArr,
+			Dep:            doc.Dep.Coordinates,
+			Arr:            doc.Arr.Coordinates,
+			FlightDuration: doc.SHR.FlightDuration,
+			AircraftType:   doc.SHR.AircraftType,
+		}
+		set := bson.M{"qualityFlags": Check(in)}
+		if distanceKm, speedKmh := Motion(in); distanceKm != nil {
+			set["distanceKm"] = *distanceKm
+			if speedKmh != nil {
+				set["avgSpeedKmh"] = *speedKmh
+			}
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID}).
+			SetUpdate(bson.M{"$set": set}))
+		if len(models) >= backfillBatchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return updated, err
+	}
+
+	return updated, cursor.Err()
+}
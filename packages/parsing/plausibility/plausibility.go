@@ -0,0 +1,181 @@
+package plausibility
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	coorinates "project/packages/parsing/coordinates"
+	"project/packages/parsing/geoMath"
+)
+
+// Флаги качества данных, сохраняемые в qualityFlags
+const (
+	FlagZeroCoordinates  = "zeroCoordinates"  // точка 0,0
+	FlagOutOfCountry     = "outOfCountry"     // точка вне охватывающей области РФ
+	FlagDepMismatch      = "depMismatch"      // DEP/ из SHR и ADEPZ далеко друг от друга
+	FlagImplausibleSpeed = "implausibleSpeed" // расстояние вылет-посадка не соответствует длительности
+)
+
+// AllFlags список всех флагов в порядке отображения
+var AllFlags = []string{
+	FlagZeroCoordinates,
+	FlagOutOfCountry,
+	FlagDepMismatch,
+	FlagImplausibleSpeed,
+}
+
+// Классы воздушных судов по типу из SHR (TYP/) для порога скорости
+const (
+	AircraftUAV     = "uav"     // БЛА: TYP/BLA, TYP/2BLA
+	AircraftBalloon = "balloon" // аэростаты и шары-зонды: TYP/AER, TYP/SHAR
+)
+
+// Пороговые значения проверок
+var (
+	// MaxDepMismatchMeters допустимое расхождение координат вылета SHR и DEP
+	MaxDepMismatchMeters = 50000.0
+	// MaxGroundSpeedKmh максимальная правдоподобная путевая скорость БВС неизвестного класса (MAX_GROUND_SPEED_KMH)
+	MaxGroundSpeedKmh = speedFromEnv("MAX_GROUND_SPEED_KMH", 250)
+	// MaxGroundSpeedByClass пороги скорости по классам (MAX_GROUND_SPEED_UAV_KMH, MAX_GROUND_SPEED_BALLOON_KMH).
+	// Аэростаты и шары-зонды летят по ветру, поэтому их порог ниже
+	MaxGroundSpeedByClass = map[string]float64{
+		AircraftUAV:     speedFromEnv("MAX_GROUND_SPEED_UAV_KMH", 250),
+		AircraftBalloon: speedFromEnv("MAX_GROUND_SPEED_BALLOON_KMH", 150),
+	}
+)
+
+func speedFromEnv(name string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// aircraftClasses класс по коду типа из SHR. При разборе из TYP/ сохраняются только буквы
+// (TYP/2BLA -> BLA), поэтому сравнивается код целиком
+var aircraftClasses = map[string]string{
+	"BLA":  AircraftUAV,
+	"BPLA": AircraftUAV,
+	"AER":  AircraftBalloon,
+	"SHAR": AircraftBalloon,
+}
+
+// AircraftClass класс по типу из SHR (shr.aircraftType или строка TYP/...); пустая строка - класс не определен
+func AircraftClass(aircraftType *string) string {
+	if aircraftType == nil {
+		return ""
+	}
+	code := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(*aircraftType)), "TYP/")
+	code = strings.TrimLeft(code, "0123456789")
+	return aircraftClasses[code]
+}
+
+// MaxGroundSpeedFor порог скорости для типа БВС; для неизвестного класса - MaxGroundSpeedKmh
+func MaxGroundSpeedFor(aircraftType *string) float64 {
+	if limit, ok := MaxGroundSpeedByClass[AircraftClass(aircraftType)]; ok {
+		return limit
+	}
+	return MaxGroundSpeedKmh
+}
+
+// bbox прямоугольная область
+type bbox struct {
+	minLat, maxLat, minLon, maxLon float64
+}
+
+// Охватывающая область РФ: основная часть и восточная Чукотка за антимеридианом
+var russiaBoxes = []bbox{
+	{minLat: 41.1, maxLat: 82.1, minLon: 19.6, maxLon: 180},
+	{minLat: 64.0, maxLat: 72.0, minLon: -180, maxLon: -168.9},
+}
+
+// Input координаты и длительность, по которым проверяется полет
+type Input struct {
+	SHRDep         *coorinates.Coordinate
+	SHRArr         *coorinates.Coordinate
+	Dep            *coorinates.Coordinate
+	Arr            *coorinates.Coordinate
+	FlightDuration *float64 // минуты
+	AircraftType   *string  // тип БВС из SHR для порога скорости
+}
+
+// Check возвращает список флагов правдоподобности для полета; пустой список (не nil) -
+// полет проверен и замечаний нет
+func Check(in Input) []string {
+	flags := []string{}
+	seen := make(map[string]bool)
+	add := func(flag string) {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+
+	for _, c := range []*coorinates.Coordinate{in.SHRDep, in.SHRArr, in.Dep, in.Arr} {
+		if c == nil {
+			continue
+		}
+		if c.Lat == 0 && c.Lon == 0 {
+			add(FlagZeroCoordinates)
+			continue
+		}
+		if !InRussia(c.Lat, c.Lon) {
+			add(FlagOutOfCountry)
+		}
+	}
+
+	if in.SHRDep != nil && in.Dep != nil {
+		if geoMath.HaversineMeters(in.SHRDep.Lat, in.SHRDep.Lon, in.Dep.Lat, in.Dep.Lon) > MaxDepMismatchMeters {
+			add(FlagDepMismatch)
+		}
+	}
+
+	if _, speedKmh := Motion(in); speedKmh != nil && *speedKmh > MaxGroundSpeedFor(in.AircraftType) {
+		add(FlagImplausibleSpeed)
+	}
+
+	return flags
+}
+
+// Motion расстояние по большому кругу между точками вылета и посадки (км)
+// и средняя путевая скорость (км/ч). Точки берутся из DEP/ARR, иначе из SHR;
+// точки 0,0 не учитываются. Скорость считается только при известной длительности
+func Motion(in Input) (distanceKm, speedKmh *float64) {
+	dep := firstNonZero(in.Dep, in.SHRDep)
+	arr := firstNonZero(in.Arr, in.SHRArr)
+	if dep == nil || arr == nil {
+		return nil, nil
+	}
+
+	meters := geoMath.HaversineMeters(dep.Lat, dep.Lon, arr.Lat, arr.Lon)
+	distance := math.Round(meters/10) / 100
+	distanceKm = &distance
+
+	if in.FlightDuration != nil && *in.FlightDuration > 0 {
+		speed := math.Round(meters/1000/(*in.FlightDuration/60)*10) / 10
+		speedKmh = &speed
+	}
+
+	return distanceKm, speedKmh
+}
+
+// InRussia проверяет попадание точки в охватывающую область РФ
+func InRussia(lat, lon float64) bool {
+	for _, b := range russiaBoxes {
+		if lat >= b.minLat && lat <= b.maxLat && lon >= b.minLon && lon <= b.maxLon {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonZero(values ...*coorinates.Coordinate) *coorinates.Coordinate {
+	for _, v := range values {
+		if v != nil && (v.Lat != 0 || v.Lon != 0) {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package plausibility
+
+import "testing"
+
+// TestMaxGroundSpeedFor порог скорости по типам из реальных SHR
+func TestMaxGroundSpeedFor(t *testing.T) {
+	tests := []struct {
+		aircraftType *string
+		class        string
+		limit        float64
+	}{
+		{strPtr("BLA"), AircraftUAV, MaxGroundSpeedByClass[AircraftUAV]},
+		{strPtr("TYP/BLA"), AircraftUAV, MaxGroundSpeedByClass[AircraftUAV]},
+		{strPtr("TYP/2BLA"), AircraftUAV, MaxGroundSpeedByClass[AircraftUAV]},
+		{strPtr("bla"), AircraftUAV, MaxGroundSpeedByClass[AircraftUAV]},
+		{strPtr("AER"), AircraftBalloon, MaxGroundSpeedByClass[AircraftBalloon]},
+		{strPtr("TYP/AER"), AircraftBalloon, MaxGroundSpeedByClass[AircraftBalloon]},
+		{strPtr("SHAR"), AircraftBalloon, MaxGroundSpeedByClass[AircraftBalloon]},
+		{strPtr("TYP/1SHAR"), AircraftBalloon, MaxGroundSpeedByClass[AircraftBalloon]},
+		{strPtr("ZZZZ"), "", MaxGroundSpeedKmh},
+		{strPtr("BLAH"), "", MaxGroundSpeedKmh},
+		{strPtr(""), "", MaxGroundSpeedKmh},
+		{nil, "", MaxGroundSpeedKmh},
+	}
+
+	for _, test := range tests {
+		name := "<nil>"
+		if test.aircraftType != nil {
+			name = *test.aircraftType
+		}
+		if class := AircraftClass(test.aircraftType); class != test.class {
+			t.Errorf("AircraftClass(%q) = %q, ожидалось %q", name, class, test.class)
+		}
+		if limit := MaxGroundSpeedFor(test.aircraftType); limit != test.limit {
+			t.Errorf("MaxGroundSpeedFor(%q) = %v, ожидалось %v", name, limit, test.limit)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
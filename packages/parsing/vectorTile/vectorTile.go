@@ -0,0 +1,412 @@
+package vectorTile
+
+import (
+	"fmt"
+	"math"
+
+	"project/packages/parsing/geoMath"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Extent размер тайла в единицах геометрии
+const Extent = 4096
+
+// Buffer запас вокруг тайла, чтобы линии на стыках тайлов не обрывались
+const Buffer = 64
+
+// MaxZoom максимальный поддерживаемый уровень масштаба
+const MaxZoom = 22
+
+// Типы геометрий Mapbox Vector Tile
+const (
+	geomPoint   = 1
+	geomPolygon = 3
+)
+
+// Команды кодирования геометрии
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// Tile координаты тайла в схеме XYZ (Web Mercator)
+type Tile struct {
+	Z, X, Y int
+}
+
+// NewTile проверяет координаты тайла
+func NewTile(z, x, y int) (Tile, error) {
+	if z < 0 || z > MaxZoom {
+		return Tile{}, fmt.Errorf("уровень масштаба вне диапазона 0..%d", MaxZoom)
+	}
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return Tile{}, fmt.Errorf("тайл %d/%d/%d вне сетки", z, x, y)
+	}
+	return Tile{Z: z, X: x, Y: y}, nil
+}
+
+// BBox границы тайла в градусах с учетом буфера
+func (t Tile) BBox() geoMath.BBox {
+	n := float64(int(1) << t.Z)
+	pad := float64(Buffer) / Extent
+
+	minLon := (float64(t.X)-pad)/n*360 - 180
+	maxLon := (float64(t.X)+1+pad)/n*360 - 180
+	maxLat := tileLat(float64(t.Y)-pad, n)
+	minLat := tileLat(float64(t.Y)+1+pad, n)
+
+	return geoMath.BBox{
+		MinX: math.Max(minLon, -180),
+		MinY: minLat,
+		MaxX: math.Min(maxLon, 180),
+		MaxY: maxLat,
+	}
+}
+
+// tileLat широта верхнего края строки тайлов y
+func tileLat(y, n float64) float64 {
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+	return math.Max(-85.05112878, math.Min(85.05112878, lat))
+}
+
+// project переводит долготу и широту в координаты внутри тайла
+func (t Tile) project(lon, lat float64) (float64, float64) {
+	n := float64(int(1) << t.Z)
+	lat = math.Max(-85.05112878, math.Min(85.05112878, lat))
+	latRad := lat * math.Pi / 180
+
+	x := (lon + 180) / 360 * n
+	y := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+
+	return (x - float64(t.X)) * Extent, (y - float64(t.Y)) * Extent
+}
+
+// feature объект слоя в закодированном виде
+type feature struct {
+	id       uint64
+	geomType uint64
+	geometry []uint32
+	tags     []uint32
+}
+
+// Layer слой тайла
+type Layer struct {
+	name     string
+	features []feature
+	keys     []string
+	keyIndex map[string]uint32
+	values   []interface{}
+	valIndex map[interface{}]uint32
+}
+
+// NewLayer создает пустой слой
+func NewLayer(name string) *Layer {
+	return &Layer{
+		name:     name,
+		keyIndex: make(map[string]uint32),
+		valIndex: make(map[interface{}]uint32),
+	}
+}
+
+// Len количество объектов в слое
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddPoint добавляет точку. Точки вне тайла (с буфером) пропускаются
+func (l *Layer) AddPoint(t Tile, lat, lon float64, id uint64, properties map[string]interface{}) bool {
+	x, y := t.project(lon, lat)
+	if x < -Buffer || x > Extent+Buffer || y < -Buffer || y > Extent+Buffer {
+		return false
+	}
+
+	geometry := []uint32{command(cmdMoveTo, 1), zigzag(int32(math.Round(x))), zigzag(int32(math.Round(y)))}
+	l.features = append(l.features, feature{
+		id:       id,
+		geomType: geomPoint,
+		geometry: geometry,
+		tags:     l.tags(properties),
+	})
+	return true
+}
+
+// AddPolygons добавляет мультиполигон в координатах [lon, lat], обрезанный по границам тайла
+func (l *Layer) AddPolygons(t Tile, polygons [][][][]float64, id uint64, properties map[string]interface{}) bool {
+	var geometry []uint32
+	cursorX, cursorY := int32(0), int32(0)
+
+	for _, polygon := range polygons {
+		for i, ring := range polygon {
+			projected := make([][2]float64, 0, len(ring))
+			for _, point := range ring {
+				x, y := t.project(point[0], point[1])
+				projected = append(projected, [2]float64{x, y})
+			}
+
+			points := snapRing(clipRing(projected, -Buffer, Extent+Buffer))
+			if len(points) < 3 {
+				if i == 0 {
+					break // Внешнее кольцо вне тайла - дыры не нужны
+				}
+				continue
+			}
+
+			// Внешнее кольцо - по часовой стрелке в экранных координатах (положительная площадь), дыры - против
+			area := signedArea(points)
+			if area == 0 {
+				if i == 0 {
+					break
+				}
+				continue
+			}
+			if (i == 0) != (area > 0) {
+				points = reverse(points)
+			}
+
+			geometry = append(geometry, command(cmdMoveTo, 1),
+				zigzag(points[0][0]-cursorX), zigzag(points[0][1]-cursorY))
+			cursorX, cursorY = points[0][0], points[0][1]
+
+			geometry = append(geometry, command(cmdLineTo, len(points)-1))
+			for _, point := range points[1:] {
+				geometry = append(geometry, zigzag(point[0]-cursorX), zigzag(point[1]-cursorY))
+				cursorX, cursorY = point[0], point[1]
+			}
+			geometry = append(geometry, command(cmdClosePath, 1))
+		}
+	}
+
+	if len(geometry) == 0 {
+		return false
+	}
+
+	l.features = append(l.features, feature{
+		id:       id,
+		geomType: geomPolygon,
+		geometry: geometry,
+		tags:     l.tags(properties),
+	})
+	return true
+}
+
+// tags кодирует свойства объекта индексами в словарях ключей и значений слоя
+func (l *Layer) tags(properties map[string]interface{}) []uint32 {
+	var tags []uint32
+	for key, value := range properties {
+		value = normalizeValue(value)
+		if value == nil {
+			continue
+		}
+
+		keyID, ok := l.keyIndex[key]
+		if !ok {
+			keyID = uint32(len(l.keys))
+			l.keys = append(l.keys, key)
+			l.keyIndex[key] = keyID
+		}
+
+		valueID, ok := l.valIndex[value]
+		if !ok {
+			valueID = uint32(len(l.values))
+			l.values = append(l.values, value)
+			l.valIndex[value] = valueID
+		}
+
+		tags = append(tags, keyID, valueID)
+	}
+	return tags
+}
+
+// normalizeValue приводит значение свойства к типам, поддерживаемым форматом
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return v
+	case bool, float64, int64:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return nil
+	}
+}
+
+// Encode кодирует слои в Mapbox Vector Tile (protobuf, спецификация 2.1).
+// Пустые слои не включаются
+func Encode(layers ...*Layer) []byte {
+	var tile []byte
+	for _, layer := range layers {
+		if layer == nil || len(layer.features) == 0 {
+			continue
+		}
+		tile = protowire.AppendTag(tile, 3, protowire.BytesType)
+		tile = protowire.AppendBytes(tile, layer.encode())
+	}
+	return tile
+}
+
+func (l *Layer) encode() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 15, protowire.VarintType)
+	b = protowire.AppendVarint(b, 2)
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, l.name)
+
+	for _, f := range l.features {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, f.encode())
+	}
+	for _, key := range l.keys {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+	}
+	for _, value := range l.values {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeValue(value))
+	}
+
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, Extent)
+	return b
+}
+
+func (f feature) encode() []byte {
+	var b []byte
+	if f.id != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, f.id)
+	}
+	if len(f.tags) > 0 {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, packed(f.tags))
+	}
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, f.geomType)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, packed(f.geometry))
+	return b
+}
+
+func encodeValue(value interface{}) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case string:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case float64:
+		b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	case int64:
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+	case bool:
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	}
+	return b
+}
+
+func packed(values []uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = protowire.AppendVarint(b, uint64(v))
+	}
+	return b
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+// clipRing обрезает кольцо квадратом [min, max] (алгоритм Сазерленда-Ходжмана)
+func clipRing(ring [][2]float64, min, max float64) [][2]float64 {
+	edges := []struct {
+		inside    func(p [2]float64) bool
+		intersect func(a, b [2]float64) [2]float64
+	}{
+		{func(p [2]float64) bool { return p[0] >= min }, func(a, b [2]float64) [2]float64 { return atX(a, b, min) }},
+		{func(p [2]float64) bool { return p[0] <= max }, func(a, b [2]float64) [2]float64 { return atX(a, b, max) }},
+		{func(p [2]float64) bool { return p[1] >= min }, func(a, b [2]float64) [2]float64 { return atY(a, b, min) }},
+		{func(p [2]float64) bool { return p[1] <= max }, func(a, b [2]float64) [2]float64 { return atY(a, b, max) }},
+	}
+
+	output := ring
+	for _, edge := range edges {
+		input := output
+		output = nil
+		if len(input) == 0 {
+			break
+		}
+		prev := input[len(input)-1]
+		for _, current := range input {
+			if edge.inside(current) {
+				if !edge.inside(prev) {
+					output = append(output, edge.intersect(prev, current))
+				}
+				output = append(output, current)
+			} else if edge.inside(prev) {
+				output = append(output, edge.intersect(prev, current))
+			}
+			prev = current
+		}
+	}
+	return output
+}
+
+func atX(a, b [2]float64, x float64) [2]float64 {
+	t := (x - a[0]) / (b[0] - a[0])
+	return [2]float64{x, a[1] + t*(b[1]-a[1])}
+}
+
+func atY(a, b [2]float64, y float64) [2]float64 {
+	t := (y - a[1]) / (b[1] - a[1])
+	return [2]float64{a[0] + t*(b[0]-a[0]), y}
+}
+
+// snapRing округляет координаты до целых и убирает повторы подряд и замыкающую точку
+func snapRing(ring [][2]float64) [][2]int32 {
+	var points [][2]int32
+	for _, p := range ring {
+		point := [2]int32{int32(math.Round(p[0])), int32(math.Round(p[1]))}
+		if len(points) > 0 && points[len(points)-1] == point {
+			continue
+		}
+		points = append(points, point)
+	}
+	for len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	return points
+}
+
+// signedArea удвоенная площадь кольца; положительная - по часовой стрелке при оси Y вниз
+func signedArea(points [][2]int32) int64 {
+	var area int64
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += int64(points[i][0])*int64(points[j][1]) - int64(points[j][0])*int64(points[i][1])
+	}
+	return area
+}
+
+func reverse(points [][2]int32) [][2]int32 {
+	result := make([][2]int32, len(points))
+	for i, point := range points {
+		result[len(points)-1-i] = point
+	}
+	return result
+}
@@ -0,0 +1,334 @@
+package vectorTile
+
+import (
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodedFeature объект слоя после разбора protobuf
+type decodedFeature struct {
+	id       uint64
+	geomType uint64
+	tags     []uint64
+	geometry []uint32
+}
+
+// decodedLayer слой тайла после разбора protobuf
+type decodedLayer struct {
+	version  uint64
+	name     string
+	extent   uint64
+	features []decodedFeature
+	keys     []string
+	values   []interface{}
+}
+
+// decodeTile разбирает тайл обратно по спецификации Mapbox Vector Tile 2.1
+func decodeTile(t *testing.T, data []byte) map[string]decodedLayer {
+	t.Helper()
+	layers := make(map[string]decodedLayer)
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 || num != 3 || typ != protowire.BytesType {
+			t.Fatalf("неожиданное поле тайла %d", num)
+		}
+		data = data[n:]
+		raw, n := protowire.ConsumeBytes(data)
+		data = data[n:]
+
+		layer := decodeLayer(t, raw)
+		layers[layer.name] = layer
+	}
+	return layers
+}
+
+func decodeLayer(t *testing.T, data []byte) decodedLayer {
+	var layer decodedLayer
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		data = data[n:]
+		switch {
+		case typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			data = data[n:]
+			switch num {
+			case 15:
+				layer.version = v
+			case 5:
+				layer.extent = v
+			}
+		case typ == protowire.BytesType:
+			raw, n := protowire.ConsumeBytes(data)
+			data = data[n:]
+			switch num {
+			case 1:
+				layer.name = string(raw)
+			case 2:
+				layer.features = append(layer.features, decodeFeature(t, raw))
+			case 3:
+				layer.keys = append(layer.keys, string(raw))
+			case 4:
+				layer.values = append(layer.values, decodeValue(t, raw))
+			}
+		default:
+			t.Fatalf("неожиданный тип поля слоя %d", typ)
+		}
+	}
+	return layer
+}
+
+func decodeFeature(t *testing.T, data []byte) decodedFeature {
+	var f decodedFeature
+	for len(data) > 0 {
+		num, _, n := protowire.ConsumeTag(data)
+		data = data[n:]
+		switch num {
+		case 1, 3:
+			v, n := protowire.ConsumeVarint(data)
+			data = data[n:]
+			if num == 1 {
+				f.id = v
+			} else {
+				f.geomType = v
+			}
+		case 2, 4:
+			raw, n := protowire.ConsumeBytes(data)
+			data = data[n:]
+			for len(raw) > 0 {
+				v, n := protowire.ConsumeVarint(raw)
+				raw = raw[n:]
+				if num == 2 {
+					f.tags = append(f.tags, v)
+				} else {
+					f.geometry = append(f.geometry, uint32(v))
+				}
+			}
+		default:
+			t.Fatalf("неожиданное поле объекта %d", num)
+		}
+	}
+	return f
+}
+
+func decodeValue(t *testing.T, data []byte) interface{} {
+	num, _, n := protowire.ConsumeTag(data)
+	data = data[n:]
+	switch num {
+	case 1:
+		v, _ := protowire.ConsumeBytes(data)
+		return string(v)
+	case 3:
+		v, _ := protowire.ConsumeFixed64(data)
+		return math.Float64frombits(v)
+	case 6:
+		v, _ := protowire.ConsumeVarint(data)
+		return protowire.DecodeZigZag(v)
+	case 7:
+		v, _ := protowire.ConsumeVarint(data)
+		return protowire.DecodeBool(v)
+	}
+	t.Fatalf("неожиданный тип значения %d", num)
+	return nil
+}
+
+// decodeRings раскодирует команды геометрии в кольца (для точки - одно кольцо из одной точки)
+func decodeRings(t *testing.T, geometry []uint32) [][][2]int32 {
+	t.Helper()
+	var rings [][][2]int32
+	x, y := int32(0), int32(0)
+	for i := 0; i < len(geometry); {
+		id, count := int(geometry[i]&0x7), int(geometry[i]>>3)
+		i++
+		switch id {
+		case cmdMoveTo, cmdLineTo:
+			if id == cmdMoveTo {
+				rings = append(rings, nil)
+			}
+			for k := 0; k < count; k++ {
+				x += unzigzag(geometry[i])
+				y += unzigzag(geometry[i+1])
+				i += 2
+				rings[len(rings)-1] = append(rings[len(rings)-1], [2]int32{x, y})
+			}
+		case cmdClosePath:
+		default:
+			t.Fatalf("неизвестная команда %d", id)
+		}
+	}
+	return rings
+}
+
+func unzigzag(v uint32) int32 {
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+func TestCommandAndZigzag(t *testing.T) {
+	commands := []struct {
+		id, count int
+		want      uint32
+	}{
+		{cmdMoveTo, 1, 9},
+		{cmdLineTo, 3, 26},
+		{cmdClosePath, 1, 15},
+	}
+	for _, c := range commands {
+		if got := command(c.id, c.count); got != c.want {
+			t.Errorf("command(%d, %d) = %d, ожидалось %d", c.id, c.count, got, c.want)
+		}
+	}
+
+	zigzags := []struct {
+		n    int32
+		want uint32
+	}{
+		{0, 0}, {-1, 1}, {1, 2}, {-2, 3}, {2, 4},
+		{math.MaxInt32, math.MaxUint32 - 1}, {math.MinInt32, math.MaxUint32},
+	}
+	for _, z := range zigzags {
+		if got := zigzag(z.n); got != z.want {
+			t.Errorf("zigzag(%d) = %d, ожидалось %d", z.n, got, z.want)
+		}
+		if back := unzigzag(zigzag(z.n)); back != z.n {
+			t.Errorf("обратное преобразование %d дало %d", z.n, back)
+		}
+	}
+}
+
+func TestEncodePointRoundTrip(t *testing.T) {
+	tile := mustTile(t, 0, 0, 0)
+
+	layer := NewLayer("flights")
+	if !layer.AddPoint(tile, 0, 0, 7, map[string]interface{}{"region": "A", "count": 3, "empty": ""}) {
+		t.Fatal("точка в центре тайла пропущена")
+	}
+	if layer.AddPoint(mustTile(t, 2, 0, 0), -60, 170, 8, nil) {
+		t.Error("точка вне тайла не пропущена")
+	}
+
+	layers := decodeTile(t, Encode(layer, NewLayer("empty")))
+	if len(layers) != 1 {
+		t.Fatalf("слоев %d, ожидался 1 (пустой слой не кодируется)", len(layers))
+	}
+	decoded := layers["flights"]
+	if decoded.version != 2 || decoded.extent != Extent {
+		t.Errorf("версия %d, extent %d", decoded.version, decoded.extent)
+	}
+	if len(decoded.features) != 1 {
+		t.Fatalf("объектов %d, ожидался 1", len(decoded.features))
+	}
+
+	f := decoded.features[0]
+	if f.id != 7 || f.geomType != geomPoint {
+		t.Errorf("id %d, тип %d", f.id, f.geomType)
+	}
+	rings := decodeRings(t, f.geometry)
+	if len(rings) != 1 || len(rings[0]) != 1 || rings[0][0] != [2]int32{Extent / 2, Extent / 2} {
+		t.Errorf("геометрия точки %v, ожидалась [[2048 2048]]", rings)
+	}
+
+	properties := make(map[string]interface{})
+	for i := 0; i+1 < len(f.tags); i += 2 {
+		properties[decoded.keys[f.tags[i]]] = decoded.values[f.tags[i+1]]
+	}
+	if len(properties) != 2 || properties["region"] != "A" || properties["count"] != int64(3) {
+		t.Errorf("свойства %v", properties)
+	}
+}
+
+func TestEncodePolygonRoundTrip(t *testing.T) {
+	tile := mustTile(t, 0, 0, 0)
+
+	outer := [][]float64{{-90, 0}, {90, 0}, {90, 45}, {-90, 45}, {-90, 0}}   // против часовой стрелки
+	hole := [][]float64{{-10, 10}, {-10, 20}, {10, 20}, {10, 10}, {-10, 10}} // по часовой стрелке
+
+	layer := NewLayer("regions")
+	if !layer.AddPolygons(tile, [][][][]float64{{outer, hole}}, 1, nil) {
+		t.Fatal("полигон пропущен")
+	}
+
+	f := decodeTile(t, Encode(layer))["regions"].features[0]
+	if f.geomType != geomPolygon {
+		t.Fatalf("тип %d", f.geomType)
+	}
+	rings := decodeRings(t, f.geometry)
+	if len(rings) != 2 || len(rings[0]) != 4 || len(rings[1]) != 4 {
+		t.Fatalf("кольца %v, ожидалось внешнее и дыра по 4 точки", rings)
+	}
+
+	_, top := tile.project(0, 45)
+	want := map[[2]int32]bool{
+		{1024, 2048}: true, {3072, 2048}: true,
+		{3072, int32(math.Round(top))}: true, {1024, int32(math.Round(top))}: true,
+	}
+	for _, point := range rings[0] {
+		if !want[point] {
+			t.Errorf("вершина %v внешнего кольца не ожидалась", point)
+		}
+	}
+}
+
+func TestRingWinding(t *testing.T) {
+	tile := mustTile(t, 0, 0, 0)
+	ccw := [][]float64{{-90, 0}, {90, 0}, {90, 45}, {-90, 45}, {-90, 0}}
+	cw := [][]float64{{-90, 0}, {-90, 45}, {90, 45}, {90, 0}, {-90, 0}}
+	holeCCW := [][]float64{{-10, 10}, {10, 10}, {10, 20}, {-10, 20}, {-10, 10}}
+
+	// Независимо от исходного обхода внешнее кольцо положительное, дыра отрицательная
+	for _, polygon := range [][][][]float64{{ccw, holeCCW}, {cw, holeCCW}} {
+		layer := NewLayer("regions")
+		layer.AddPolygons(tile, [][][][]float64{polygon}, 1, nil)
+		rings := decodeRings(t, decodeTile(t, Encode(layer))["regions"].features[0].geometry)
+		if len(rings) != 2 {
+			t.Fatalf("колец %d, ожидалось 2", len(rings))
+		}
+		if signedArea(rings[0]) <= 0 {
+			t.Errorf("внешнее кольцо с неположительной площадью %d", signedArea(rings[0]))
+		}
+		if signedArea(rings[1]) >= 0 {
+			t.Errorf("дыра с неотрицательной площадью %d", signedArea(rings[1]))
+		}
+	}
+}
+
+func TestClipAtTileEdge(t *testing.T) {
+	tile := mustTile(t, 1, 0, 0) // северо-западная четверть: lon -180..0, lat 0..85
+
+	// Полоса через меридиан 0 обрезается по правому краю тайла с буфером
+	band := [][]float64{{-90, 10}, {90, 10}, {90, 20}, {-90, 20}, {-90, 10}}
+	layer := NewLayer("regions")
+	if !layer.AddPolygons(tile, [][][][]float64{{band}}, 1, nil) {
+		t.Fatal("полигон, задевающий тайл, пропущен")
+	}
+
+	rings := decodeRings(t, decodeTile(t, Encode(layer))["regions"].features[0].geometry)
+	maxX := int32(math.MinInt32)
+	for _, point := range rings[0] {
+		if point[0] < -Buffer || point[0] > Extent+Buffer || point[1] < -Buffer || point[1] > Extent+Buffer {
+			t.Errorf("вершина %v вне тайла с буфером", point)
+		}
+		if point[0] > maxX {
+			maxX = point[0]
+		}
+	}
+	if maxX != Extent+Buffer {
+		t.Errorf("правый край %d, ожидался %d", maxX, Extent+Buffer)
+	}
+
+	// Полигон целиком в южном полушарии в тайл не попадает
+	south := [][]float64{{-90, -20}, {-10, -20}, {-10, -10}, {-90, -10}, {-90, -20}}
+	if layer.AddPolygons(tile, [][][][]float64{{south}}, 2, nil) {
+		t.Error("полигон вне тайла не пропущен")
+	}
+}
+
+// mustTile тайл с заведомо верными координатами
+func mustTile(t *testing.T, z, x, y int) Tile {
+	t.Helper()
+	tile, err := NewTile(z, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tile
+}